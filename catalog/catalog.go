@@ -0,0 +1,427 @@
+// Package catalog reads and writes product rows in the bulk import/export
+// formats, CSV and JSON Lines, and validates them before they reach the
+// database.
+package catalog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+var ErrFormatNotValid = errors.New("format must be csv or jsonl")
+
+// Columns are the fields of a row, in CSV header order.
+var Columns = []string{
+	"id", "sku", "name", "description", "category_id", "price", "quantity", "image",
+	"max_per_order", "low_stock_threshold", "stock_policy", "backorder_limit", "is_digital", "download_limit",
+	"status",
+}
+
+// Row is one product in an import or export. Rows are matched to existing
+// products by ID, then by SKU; fields left empty keep their current value
+// on update.
+type Row struct {
+	Line              int      `json:"-"`
+	ID                int64    `json:"id,omitempty"`
+	SKU               string   `json:"sku,omitempty"`
+	Name              *string  `json:"name,omitempty"`
+	Description       *string  `json:"description,omitempty"`
+	CategoryID        *int64   `json:"category_id,omitempty"`
+	Price             *float64 `json:"price,omitempty"`
+	Quantity          *int     `json:"quantity,omitempty"`
+	Image             *string  `json:"image,omitempty"`
+	MaxPerOrder       *int     `json:"max_per_order,omitempty"`
+	LowStockThreshold *int     `json:"low_stock_threshold,omitempty"`
+	StockPolicy       *string  `json:"stock_policy,omitempty"`
+	BackorderLimit    *int     `json:"backorder_limit,omitempty"`
+	IsDigital         *bool    `json:"is_digital,omitempty"`
+	DownloadLimit     *int     `json:"download_limit,omitempty"`
+	// Status is draft, published or archived; imported products are drafts
+	// unless it says otherwise. Scheduling is done through the API.
+	Status *string `json:"status,omitempty"`
+}
+
+// RowError reports why a row of an import could not be used.
+type RowError struct {
+	Line    int    `json:"line"`
+	SKU     string `json:"sku,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Validate checks the values of the row. New products also need a name,
+// description, category and price.
+func (r *Row) Validate(create bool) error {
+	var problems []string
+	if create {
+		if r.Name == nil || strings.TrimSpace(*r.Name) == "" {
+			problems = append(problems, "name is required")
+		}
+		if r.Description == nil {
+			problems = append(problems, "description is required")
+		}
+		if r.CategoryID == nil {
+			problems = append(problems, "category_id is required")
+		}
+		if r.Price == nil {
+			problems = append(problems, "price is required")
+		}
+	}
+	if r.Price != nil && *r.Price < 0 {
+		problems = append(problems, "price can't be negative")
+	}
+	for _, field := range []struct {
+		name  string
+		value *int
+	}{
+		{"quantity", r.Quantity},
+		{"max_per_order", r.MaxPerOrder},
+		{"low_stock_threshold", r.LowStockThreshold},
+		{"backorder_limit", r.BackorderLimit},
+		{"download_limit", r.DownloadLimit},
+	} {
+		if field.value != nil && *field.value < 0 {
+			problems = append(problems, field.name+" can't be negative")
+		}
+	}
+	if r.StockPolicy != nil {
+		switch *r.StockPolicy {
+		case models.StockPolicyDeny, models.StockPolicyBackorder, models.StockPolicyPreOrder:
+		default:
+			problems = append(problems, "stock_policy must be deny, backorder or preorder")
+		}
+	}
+	if r.Status != nil {
+		switch *r.Status {
+		case models.ProductStatusDraft, models.ProductStatusPublished, models.ProductStatusArchived:
+		default:
+			problems = append(problems, "status must be draft, published or archived")
+		}
+	}
+	if len(r.SKU) > 64 {
+		problems = append(problems, "sku is longer than 64 characters")
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &RowError{Line: r.Line, SKU: r.SKU, Message: strings.Join(problems, "; ")}
+}
+
+// FromProduct is the export row of a product.
+func FromProduct(product *models.Product) Row {
+	return Row{
+		ID:                product.ID,
+		SKU:               product.SKU,
+		Name:              &product.Name,
+		Description:       &product.Description,
+		CategoryID:        &product.CategoryID,
+		Price:             &product.Price,
+		Quantity:          &product.Quantity,
+		Image:             &product.Image,
+		MaxPerOrder:       &product.MaxPerOrder,
+		LowStockThreshold: &product.LowStockThreshold,
+		StockPolicy:       &product.StockPolicy,
+		BackorderLimit:    &product.BackorderLimit,
+		IsDigital:         &product.IsDigital,
+		DownloadLimit:     &product.DownloadLimit,
+		Status:            &product.Status,
+	}
+}
+
+// Reader reads rows one at a time. Next returns io.EOF after the last row,
+// and a *RowError for a row that can't be parsed, after which reading may
+// go on.
+type Reader interface {
+	Next() (Row, error)
+}
+
+// NewReader reads rows in the given format.
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		return &csvReader{reader: reader}, nil
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1<<20)
+		return &jsonlReader{scanner: scanner}, nil
+	}
+	return nil, ErrFormatNotValid
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func (r *csvReader) Next() (Row, error) {
+	if r.header == nil {
+		header, err := r.reader.Read()
+		if err != nil {
+			return Row{}, err
+		}
+		known := map[string]bool{}
+		for _, column := range Columns {
+			known[column] = true
+		}
+		for i, column := range header {
+			header[i] = strings.ToLower(strings.TrimSpace(column))
+			if !known[header[i]] {
+				return Row{}, fmt.Errorf("unknown column %q", column)
+			}
+		}
+		r.header = header
+	}
+	record, err := r.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return Row{}, &RowError{Line: parseErr.Line, Message: parseErr.Err.Error()}
+		}
+		return Row{}, err
+	}
+	line, _ := r.reader.FieldPos(0)
+	row := Row{Line: line}
+	var problems []string
+	for i, value := range record {
+		if i >= len(r.header) {
+			problems = append(problems, "more fields than columns")
+			break
+		}
+		if err := row.set(r.header[i], strings.TrimSpace(value)); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return row, &RowError{Line: line, SKU: row.SKU, Message: strings.Join(problems, "; ")}
+	}
+	return row, nil
+}
+
+// set assigns a CSV cell; empty cells leave the field unset.
+func (r *Row) set(column string, value string) error {
+	if value == "" {
+		return nil
+	}
+	invalid := func() error { return fmt.Errorf("%s %q is not valid", column, value) }
+	parseInt := func(target **int) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return invalid()
+		}
+		*target = &n
+		return nil
+	}
+	switch column {
+	case "id":
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return invalid()
+		}
+		r.ID = id
+	case "sku":
+		r.SKU = value
+	case "name":
+		r.Name = &value
+	case "description":
+		r.Description = &value
+	case "image":
+		r.Image = &value
+	case "stock_policy":
+		r.StockPolicy = &value
+	case "status":
+		r.Status = &value
+	case "category_id":
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return invalid()
+		}
+		r.CategoryID = &id
+	case "price":
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return invalid()
+		}
+		r.Price = &price
+	case "is_digital":
+		digital, err := strconv.ParseBool(value)
+		if err != nil {
+			return invalid()
+		}
+		r.IsDigital = &digital
+	case "quantity":
+		return parseInt(&r.Quantity)
+	case "max_per_order":
+		return parseInt(&r.MaxPerOrder)
+	case "low_stock_threshold":
+		return parseInt(&r.LowStockThreshold)
+	case "backorder_limit":
+		return parseInt(&r.BackorderLimit)
+	case "download_limit":
+		return parseInt(&r.DownloadLimit)
+	}
+	return nil
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *jsonlReader) Next() (Row, error) {
+	for r.scanner.Scan() {
+		r.line++
+		text := strings.TrimSpace(r.scanner.Text())
+		if text == "" {
+			continue
+		}
+		var row Row
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row); err != nil {
+			return Row{}, &RowError{Line: r.line, Message: err.Error()}
+		}
+		row.Line = r.line
+		return row, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Row{}, err
+	}
+	return Row{}, io.EOF
+}
+
+// ReadAll reads every row. Rows that can't be parsed are returned as row
+// errors; any other error, such as a bad CSV header, stops the read.
+func ReadAll(reader Reader) ([]Row, []RowError, error) {
+	var rows []Row
+	var rowErrors []RowError
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			return rows, rowErrors, nil
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			rowErrors = append(rowErrors, *rowErr)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// Writer writes rows in an export format.
+type Writer interface {
+	Write(row Row) error
+	Flush() error
+}
+
+// NewWriter writes rows in the given format. CSV output starts with the
+// header.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+	case FormatJSONL:
+		return &jsonlWriter{encoder: json.NewEncoder(w)}, nil
+	}
+	return nil, ErrFormatNotValid
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+	header bool
+}
+
+func (w *csvWriter) Write(row Row) error {
+	if !w.header {
+		if err := w.writer.Write(Columns); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	return w.writer.Write([]string{
+		formatInt64(&row.ID), row.SKU, formatString(row.Name), formatString(row.Description),
+		formatInt64(row.CategoryID), formatFloat(row.Price), formatInt(row.Quantity), formatString(row.Image),
+		formatInt(row.MaxPerOrder), formatInt(row.LowStockThreshold), formatString(row.StockPolicy),
+		formatInt(row.BackorderLimit), formatBool(row.IsDigital), formatInt(row.DownloadLimit),
+		formatString(row.Status),
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	if !w.header {
+		if err := w.writer.Write(Columns); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(row Row) error {
+	return w.encoder.Encode(row)
+}
+
+func (w *jsonlWriter) Flush() error {
+	return nil
+}
+
+func formatString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func formatInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+func formatInt64(value *int64) string {
+	if value == nil || *value == 0 {
+		return ""
+	}
+	return strconv.FormatInt(*value, 10)
+}
+
+func formatFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+func formatBool(value *bool) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*value)
+}
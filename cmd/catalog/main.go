@@ -0,0 +1,142 @@
+// Command catalog imports and exports products in bulk, the same way the
+// admin import and export endpoints do.
+//
+//	catalog import [-format csv|jsonl] [-dry-run] [-admin id] products.csv
+//	catalog export [-format csv|jsonl] [-o products.csv]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"githum.com/muhammadAslam/ecommerce/catalog"
+	"githum.com/muhammadAslam/ecommerce/config"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var run func(db *gorm.DB, args []string) error
+	switch os.Args[1] {
+	case "import":
+		run = runImport
+	case "export":
+		run = runExport
+	default:
+		usage()
+	}
+	// Settings come from CONFIG_FILE, .env and the environment, as the
+	// command line is taken by the subcommand.
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := database.DBSet(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := run(db, os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: catalog import [-format csv|jsonl] [-dry-run] [-admin id] FILE")
+	fmt.Fprintln(os.Stderr, "       catalog export [-format csv|jsonl] [-o FILE]")
+	os.Exit(2)
+}
+
+func runImport(db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "csv or jsonl, taken from the file extension by default")
+	dryRun := flags.Bool("dry-run", false, "validate every row and roll the changes back")
+	admin := flags.Int64("admin", 0, "user id recorded on stock movements")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		if *format == "ndjson" {
+			*format = catalog.FormatJSONL
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader, err := catalog.NewReader(*format, file)
+	if err != nil {
+		return err
+	}
+	rows, rowErrors, err := catalog.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("can't read %s: %w", path, err)
+	}
+
+	ctx := context.Background()
+	job := models.ImportJob{Format: *format, DryRun: *dryRun, CreatedBy: *admin}
+	if err := database.CreateImportJob(ctx, db, &job, rows, rowErrors); err != nil {
+		return err
+	}
+	if err := database.RunImport(ctx, db, &job, rows); err != nil {
+		return err
+	}
+	report, err := database.GetImportJob(ctx, db, job.ID)
+	if err != nil {
+		return err
+	}
+	for _, rowErr := range report.Errors {
+		fmt.Fprintf(os.Stderr, "line %d %s: %s\n", rowErr.Line, rowErr.SKU, rowErr.Message)
+	}
+	mode := ""
+	if report.DryRun {
+		mode = " (dry run, nothing saved)"
+	}
+	fmt.Printf("import job %d: %d rows, %d created, %d updated, %d failed%s\n",
+		report.ID, report.TotalRows, report.CreatedRows, report.UpdatedRows, report.FailedRows, mode)
+	if report.FailedRows > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runExport(db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", catalog.FormatCSV, "csv or jsonl")
+	output := flags.String("o", "", "file to write, standard output by default")
+	flags.Parse(args)
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	writer, err := catalog.NewWriter(*format, out)
+	if err != nil {
+		return err
+	}
+	err = database.ExportProducts(context.Background(), db, func(product *models.Product) error {
+		return writer.Write(catalog.FromProduct(product))
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
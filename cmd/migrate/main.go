@@ -0,0 +1,131 @@
+// Command migrate applies and reverts the versioned database migrations.
+// The server refuses to start until every migration has been applied.
+//
+//	migrate up
+//	migrate down [n]
+//	migrate status
+//	migrate redo
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/config"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/migrations"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var run func(m *migrations.Migrator, args []string) error
+	switch os.Args[1] {
+	case "up":
+		run = runUp
+	case "down":
+		run = runDown
+	case "status":
+		run = runStatus
+	case "redo":
+		run = runRedo
+	default:
+		usage()
+	}
+	// Settings come from CONFIG_FILE, .env and the environment, as the
+	// command line is taken by the subcommand.
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	migrator, err := migrations.New(sqlDB)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := run(migrator, os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate up")
+	fmt.Fprintln(os.Stderr, "       migrate down [N]")
+	fmt.Fprintln(os.Stderr, "       migrate status")
+	fmt.Fprintln(os.Stderr, "       migrate redo")
+	os.Exit(2)
+}
+
+func runUp(m *migrations.Migrator, args []string) error {
+	if len(args) != 0 {
+		usage()
+	}
+	applied, err := m.Up(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("database is up to date")
+	}
+	return nil
+}
+
+// runDown reverts one migration unless told how many.
+func runDown(m *migrations.Migrator, args []string) error {
+	steps := 1
+	switch len(args) {
+	case 0:
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			usage()
+		}
+		steps = n
+	default:
+		usage()
+	}
+	_, err := m.Down(context.Background(), steps)
+	return err
+}
+
+func runStatus(m *migrations.Migrator, args []string) error {
+	if len(args) != 0 {
+		usage()
+	}
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		return err
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "VERSION\tNAME\tAPPLIED")
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = status.AppliedAt.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(out, "%04d\t%s\t%s\n", status.Version, status.Name, applied)
+	}
+	return out.Flush()
+}
+
+func runRedo(m *migrations.Migrator, args []string) error {
+	if len(args) != 0 {
+		usage()
+	}
+	_, err := m.Redo(context.Background())
+	return err
+}
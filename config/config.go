@@ -0,0 +1,431 @@
+// Package config loads the application settings. Values come from, in
+// increasing order of precedence: built-in defaults, an optional YAML or
+// TOML file, a .env file, the environment and command line flags.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	App       App       `yaml:"app" toml:"app" json:"app"`
+	Database  Database  `yaml:"database" toml:"database" json:"database"`
+	Auth      Auth      `yaml:"auth" toml:"auth" json:"auth"`
+	Storage   Storage   `yaml:"storage" toml:"storage" json:"storage"`
+	Inventory Inventory `yaml:"inventory" toml:"inventory" json:"inventory"`
+	Reviews   Reviews   `yaml:"reviews" toml:"reviews" json:"reviews"`
+}
+
+type App struct {
+	Name string `yaml:"name" toml:"name" json:"name"`
+	Port int    `yaml:"port" toml:"port" json:"port"`
+	// The server timeouts follow net/http: zero means no timeout.
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" toml:"read_header_timeout" json:"read_header_timeout"`
+	ReadTimeout       Duration `yaml:"read_timeout" toml:"read_timeout" json:"read_timeout"`
+	WriteTimeout      Duration `yaml:"write_timeout" toml:"write_timeout" json:"write_timeout"`
+	IdleTimeout       Duration `yaml:"idle_timeout" toml:"idle_timeout" json:"idle_timeout"`
+	// ShutdownTimeout is how long in-flight requests and background jobs
+	// get to finish after SIGTERM.
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" json:"shutdown_timeout"`
+	// DrainDelay is how long the server keeps accepting requests after
+	// SIGTERM with the readiness probe failing, so load balancers notice
+	// before it stops listening.
+	DrainDelay Duration `yaml:"drain_delay" toml:"drain_delay" json:"drain_delay"`
+}
+
+type Database struct {
+	Host     string `yaml:"host" toml:"host" json:"host"`
+	Port     int    `yaml:"port" toml:"port" json:"port"`
+	User     string `yaml:"user" toml:"user" json:"user"`
+	Password Secret `yaml:"password" toml:"password" json:"password"`
+	Name     string `yaml:"name" toml:"name" json:"name"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode" json:"sslmode"`
+	TimeZone string `yaml:"timezone" toml:"timezone" json:"timezone"`
+	// Connection pool; zero MaxOpenConns or lifetimes mean no limit.
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns" json:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns" json:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" json:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time" json:"conn_max_idle_time"`
+}
+
+// DSN is the Postgres connection string. It contains the password, so
+// never log it.
+func (d Database) DSN() string {
+	quote := func(value string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s TimeZone=%s",
+		quote(d.Host), d.Port, quote(d.User), quote(d.Password.Value()), quote(d.Name), quote(d.SSLMode), quote(d.TimeZone))
+}
+
+type Auth struct {
+	// SecretKey signs access tokens, guest cart tokens and download links.
+	SecretKey       Secret   `yaml:"secret_key" toml:"secret_key" json:"secret_key"`
+	AccessTokenTTL  Duration `yaml:"access_token_ttl" toml:"access_token_ttl" json:"access_token_ttl"`
+	RefreshTokenTTL Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl" json:"refresh_token_ttl"`
+}
+
+type Storage struct {
+	Backend string `yaml:"backend" toml:"backend" json:"backend"` // only local for now
+	Dir     string `yaml:"dir" toml:"dir" json:"dir"`
+}
+
+type Inventory struct {
+	// AllocationStrategy is priority, single or most-stock.
+	AllocationStrategy string `yaml:"allocation_strategy" toml:"allocation_strategy" json:"allocation_strategy"`
+	// PreOrderPaymentWindow is how long after the release date a pre-order
+	// can still be paid before it is cancelled.
+	PreOrderPaymentWindow Duration `yaml:"preorder_payment_window" toml:"preorder_payment_window" json:"preorder_payment_window"`
+}
+
+type Reviews struct {
+	BannedWords     []string `yaml:"banned_words" toml:"banned_words" json:"banned_words"`
+	BlockLinks      bool     `yaml:"block_links" toml:"block_links" json:"block_links"`
+	AutoApprove     bool     `yaml:"auto_approve" toml:"auto_approve" json:"auto_approve"`
+	ReportThreshold int      `yaml:"report_threshold" toml:"report_threshold" json:"report_threshold"`
+}
+
+// Secret is a string that is redacted when printed or logged. Value
+// returns the real thing.
+type Secret string
+
+const redacted = "[redacted]"
+
+func (s Secret) Value() string { return string(s) }
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) GoString() string { return strconv.Quote(s.String()) }
+
+func (s Secret) MarshalJSON() ([]byte, error) { return json.Marshal(s.String()) }
+
+// Duration is a time.Duration written as "24h" or "90m" in files and the
+// environment.
+type Duration time.Duration
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	value, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(value)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+// Default is the configuration before any file, environment variable or
+// flag is applied.
+func Default() Config {
+	return Config{
+		App: App{
+			Name:              "ecommerce",
+			Port:              8080,
+			ReadHeaderTimeout: Duration(10 * time.Second),
+			ReadTimeout:       Duration(time.Minute),
+			WriteTimeout:      Duration(2 * time.Minute),
+			IdleTimeout:       Duration(2 * time.Minute),
+			ShutdownTimeout:   Duration(30 * time.Second),
+			DrainDelay:        Duration(5 * time.Second),
+		},
+		Database: Database{
+			Host:            "localhost",
+			Port:            5432,
+			User:            "postgres",
+			Name:            "ecommerce",
+			SSLMode:         "disable",
+			TimeZone:        "Asia/Karachi",
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+			ConnMaxIdleTime: Duration(5 * time.Minute),
+		},
+		Auth: Auth{
+			AccessTokenTTL:  Duration(24 * time.Hour),
+			RefreshTokenTTL: Duration(168 * time.Hour),
+		},
+		Storage:   Storage{Backend: "local", Dir: "data/files"},
+		Inventory: Inventory{AllocationStrategy: "single", PreOrderPaymentWindow: Duration(7 * 24 * time.Hour)},
+		Reviews:   Reviews{BlockLinks: true, AutoApprove: true, ReportThreshold: 3},
+	}
+}
+
+// setting is a value that can come from the environment or a flag. Secrets
+// have no flag, as command lines show up in process listings.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"APP_NAME", "app-name", "application name", setString(func(c *Config) *string { return &c.App.Name })},
+	{"PORT", "port", "HTTP port", setInt(func(c *Config) *int { return &c.App.Port })},
+	{"HTTP_READ_HEADER_TIMEOUT", "read-header-timeout", "time allowed to read request headers", setDuration(func(c *Config) *Duration { return &c.App.ReadHeaderTimeout })},
+	{"HTTP_READ_TIMEOUT", "read-timeout", "time allowed to read a whole request", setDuration(func(c *Config) *Duration { return &c.App.ReadTimeout })},
+	{"HTTP_WRITE_TIMEOUT", "write-timeout", "time allowed to write a response", setDuration(func(c *Config) *Duration { return &c.App.WriteTimeout })},
+	{"HTTP_IDLE_TIMEOUT", "idle-timeout", "how long idle keep-alive connections stay open", setDuration(func(c *Config) *Duration { return &c.App.IdleTimeout })},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "time in-flight requests get to finish on shutdown", setDuration(func(c *Config) *Duration { return &c.App.ShutdownTimeout })},
+	{"SHUTDOWN_DRAIN_DELAY", "drain-delay", "time load balancers get to stop sending requests on shutdown", setDuration(func(c *Config) *Duration { return &c.App.DrainDelay })},
+	{"DB_HOST", "db-host", "Postgres host", setString(func(c *Config) *string { return &c.Database.Host })},
+	{"DB_PORT", "db-port", "Postgres port", setInt(func(c *Config) *int { return &c.Database.Port })},
+	{"DB_USER", "db-user", "Postgres user", setString(func(c *Config) *string { return &c.Database.User })},
+	{"DB_PASSWORD", "", "", func(c *Config, value string) error { c.Database.Password = Secret(value); return nil }},
+	{"DB_NAME", "db-name", "Postgres database", setString(func(c *Config) *string { return &c.Database.Name })},
+	{"DB_SSLMODE", "db-sslmode", "Postgres sslmode", setString(func(c *Config) *string { return &c.Database.SSLMode })},
+	{"DB_TIMEZONE", "db-timezone", "Postgres session time zone", setString(func(c *Config) *string { return &c.Database.TimeZone })},
+	{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open database connections", setInt(func(c *Config) *int { return &c.Database.MaxOpenConns })},
+	{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle database connections", setInt(func(c *Config) *int { return &c.Database.MaxIdleConns })},
+	{"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "how long a database connection is reused", setDuration(func(c *Config) *Duration { return &c.Database.ConnMaxLifetime })},
+	{"DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "how long a database connection may sit idle", setDuration(func(c *Config) *Duration { return &c.Database.ConnMaxIdleTime })},
+	{"SECRET_KEY", "", "", func(c *Config, value string) error { c.Auth.SecretKey = Secret(value); return nil }},
+	{"ACCESS_TOKEN_TTL", "access-token-ttl", "lifetime of access tokens", setDuration(func(c *Config) *Duration { return &c.Auth.AccessTokenTTL })},
+	{"REFRESH_TOKEN_TTL", "refresh-token-ttl", "lifetime of refresh tokens", setDuration(func(c *Config) *Duration { return &c.Auth.RefreshTokenTTL })},
+	{"STORAGE_BACKEND", "storage-backend", "blob storage backend", setString(func(c *Config) *string { return &c.Storage.Backend })},
+	{"STORAGE_DIR", "storage-dir", "directory of the local blob store", setString(func(c *Config) *string { return &c.Storage.Dir })},
+	{"INVENTORY_ALLOCATION_STRATEGY", "allocation-strategy", "warehouse allocation strategy", setString(func(c *Config) *string { return &c.Inventory.AllocationStrategy })},
+	{"INVENTORY_PREORDER_PAYMENT_WINDOW", "preorder-payment-window", "time after the release date to pay for a pre-order", setDuration(func(c *Config) *Duration { return &c.Inventory.PreOrderPaymentWindow })},
+	{"REVIEW_BANNED_WORDS", "review-banned-words", "comma separated words that hold reviews for moderation", func(c *Config, value string) error {
+		c.Reviews.BannedWords = nil
+		for _, word := range strings.Split(value, ",") {
+			if word = strings.TrimSpace(word); word != "" {
+				c.Reviews.BannedWords = append(c.Reviews.BannedWords, word)
+			}
+		}
+		return nil
+	}},
+	{"REVIEW_BLOCK_LINKS", "review-block-links", "hold reviews containing links", setBool(func(c *Config) *bool { return &c.Reviews.BlockLinks })},
+	{"REVIEW_AUTO_APPROVE", "review-auto-approve", "approve reviews that pass the rules", setBool(func(c *Config) *bool { return &c.Reviews.AutoApprove })},
+	{"REVIEW_REPORT_THRESHOLD", "review-report-threshold", "reports that send a review back to moderation", setInt(func(c *Config) *int { return &c.Reviews.ReportThreshold })},
+}
+
+func setString(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setInt(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func setBool(field func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func setDuration(field func(c *Config) *Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		if err := field(c).UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("%q is not a duration such as 24h", value)
+		}
+		return nil
+	}
+}
+
+// Load builds the configuration from the defaults, the file named by the
+// -config flag or CONFIG_FILE, the .env file in the working directory if
+// there is one, the environment and the flags in args, then validates it.
+// Pass nil args to skip flags, e.g. from a tool with flags of its own.
+func Load(args []string) (*Config, error) {
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	path := flags.String("config", "", "YAML or TOML configuration file")
+	values := map[string]*string{}
+	for _, s := range settings {
+		if s.flag != "" {
+			values[s.flag] = flags.String(s.flag, "", s.usage+" ("+s.env+")")
+		}
+	}
+	if args != nil {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+	}
+
+	dotenv, err := godotenv.Read(".env")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading .env: %w", err)
+	}
+	lookup := func(name string) (string, bool) {
+		if value, ok := os.LookupEnv(name); ok {
+			return value, true
+		}
+		value, ok := dotenv[name]
+		return value, ok
+	}
+
+	cfg := Default()
+	if *path == "" {
+		*path, _ = lookup("CONFIG_FILE")
+	}
+	if *path != "" {
+		if err := loadFile(&cfg, *path); err != nil {
+			return nil, err
+		}
+	}
+	var problems []string
+	for _, s := range settings {
+		if value, ok := lookup(s.env); ok {
+			if err := s.set(&cfg, value); err != nil {
+				problems = append(problems, s.env+": "+err.Error())
+			}
+		}
+	}
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name {
+				if err := s.set(&cfg, *values[s.flag]); err != nil {
+					problems = append(problems, "-"+s.flag+": "+err.Error())
+				}
+			}
+		}
+	})
+	if len(problems) > 0 {
+		return nil, invalid(problems)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// loadFile reads a YAML (.yaml, .yml) or TOML (.toml) file over cfg.
+// Unknown keys are errors, so typos don't go unnoticed.
+func loadFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	defer file.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(file)
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+		if err == io.EOF {
+			err = nil
+		}
+	case ".toml":
+		err = toml.NewDecoder(file).DisallowUnknownFields().Decode(cfg)
+	default:
+		return fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every setting that is missing or out of range.
+func (c *Config) Validate() error {
+	var problems []string
+	validPort := func(port int) bool { return port > 0 && port < 65536 }
+	if !validPort(c.App.Port) {
+		problems = append(problems, fmt.Sprintf("app port %d is not between 1 and 65535", c.App.Port))
+	}
+	if !validPort(c.Database.Port) {
+		problems = append(problems, fmt.Sprintf("database port %d is not between 1 and 65535", c.Database.Port))
+	}
+	for _, field := range []struct{ name, value string }{
+		{"database host (DB_HOST)", c.Database.Host},
+		{"database user (DB_USER)", c.Database.User},
+		{"database name (DB_NAME)", c.Database.Name},
+		{"secret key (SECRET_KEY)", c.Auth.SecretKey.Value()},
+		{"storage dir (STORAGE_DIR)", c.Storage.Dir},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			problems = append(problems, field.name+" is required")
+		}
+	}
+	if key := c.Auth.SecretKey.Value(); key != "" && len(key) < 32 {
+		problems = append(problems, "secret key (SECRET_KEY) must be at least 32 characters")
+	}
+	if c.Auth.AccessTokenTTL <= 0 || c.Auth.RefreshTokenTTL <= 0 {
+		problems = append(problems, "token lifetimes must be positive")
+	}
+	for _, timeout := range []struct {
+		name  string
+		value Duration
+	}{
+		{"read header timeout", c.App.ReadHeaderTimeout},
+		{"read timeout", c.App.ReadTimeout},
+		{"write timeout", c.App.WriteTimeout},
+		{"idle timeout", c.App.IdleTimeout},
+		{"drain delay", c.App.DrainDelay},
+		{"database connection lifetime", c.Database.ConnMaxLifetime},
+		{"database connection idle time", c.Database.ConnMaxIdleTime},
+	} {
+		if timeout.value < 0 {
+			problems = append(problems, timeout.name+" can't be negative")
+		}
+	}
+	if c.App.ShutdownTimeout <= 0 {
+		problems = append(problems, "shutdown timeout must be positive")
+	}
+	if c.Inventory.PreOrderPaymentWindow <= 0 {
+		problems = append(problems, "pre-order payment window must be positive")
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		problems = append(problems, "database connection limits can't be negative")
+	} else if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		problems = append(problems, fmt.Sprintf("database max idle connections %d is above max open connections %d",
+			c.Database.MaxIdleConns, c.Database.MaxOpenConns))
+	}
+	if c.Reviews.ReportThreshold < 0 {
+		problems = append(problems, "review report threshold can't be negative")
+	}
+	if len(problems) > 0 {
+		return invalid(problems)
+	}
+	return nil
+}
+
+func invalid(problems []string) error {
+	return errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+}
+
+// String renders the configuration as JSON with secrets redacted, for
+// logging at startup.
+func (c Config) String() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("config: %v", err)
+	}
+	return string(data)
+}
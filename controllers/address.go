@@ -8,169 +8,198 @@ import (
 	"github.com/gin-gonic/gin"
 	"githum.com/muhammadAslam/ecommerce/database"
 	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/validation"
 	"golang.org/x/net/context"
-	"gorm.io/gorm"
 )
 
-func AddAddress() gin.HandlerFunc {
+// checkAddress normalizes the address and rejects it when it breaks the
+// country rules or the verifier reports it as undeliverable. A verifier
+// outage is logged and does not block the customer.
+func (app *Application) checkAddress(ctx context.Context, c *gin.Context, address *models.Address) bool {
+	validation.NormalizeAddress(address)
+	if err := validation.ValidateAddress(*address); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address", "fields": err})
+		return false
+	}
+	result, err := app.AddressVerifier.Verify(ctx, *address)
+	if err != nil {
+		log.Println("Address verification unavailable:", err)
+		return true
+	}
+	if !result.Deliverable {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Address could not be verified", "verification": result})
+		return false
+	}
+	return true
+}
+
+// addressError maps address lookup errors to an HTTP response.
+func addressError(c *gin.Context, err error, action string) {
+	switch err {
+	case database.ErrCantFindAddress, database.ErrAddressIdIsNotValid:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+	case database.ErrAddressKindIsNotValid:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Println("Failed to "+action+":", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+func (app *Application) AddAddress() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		var userId int64
-		if err := c.ShouldBindQuery(&userId); err != nil {
-			c.Header("Content-Type", "application/json")
-			log.Println("Failed to bind query parameter:", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to bind query parameter"})
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
 		address := models.Address{}
 		if err := c.ShouldBindJSON(&address); err != nil {
-			c.Header("Content-Type", "application/json")
 			log.Println("Failed to bind JSON:", err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to bind JSON"})
 			return
 		}
-		address.UserID = userId
-		db := database.Client
-		if err := db.WithContext(ctx).Create(&address).Error; err != nil {
-			c.Header("Content-Type", "application/json")
-			log.Println("Failed to create address:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create address"})
+		if !app.checkAddress(ctx, c, &address) {
+			return
+		}
+		if err := app.Addresses.Add(ctx, userId, &address); err != nil {
+			addressError(c, err, "create address")
 			return
 		}
-		c.Header("Content-Type", "application/json")
 		c.JSON(http.StatusCreated, gin.H{"data": address})
 	}
 }
 
-func GetAddresses() gin.HandlerFunc {
+func (app *Application) GetAddresses() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		var userId int64
-		if err := c.ShouldBindQuery(&userId); err != nil {
-			c.Header("Content-Type", "application/json")
-			log.Println("Failed to bind query parameter:", err)
-			c.JSON(http.StatusNotFound, nil)
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
-		var addresses []models.Address
-		db := database.Client
-		if err := db.WithContext(ctx).Where("user_id =?", userId).Find(&addresses).Error; err != nil {
-			c.Header("Content-Type", "application/json")
-			log.Println("Failed to fetch addresses:", err)
-			c.JSON(http.StatusInternalServerError, nil)
+		addresses, err := app.Addresses.List(ctx, userId)
+		if err != nil {
+			addressError(c, err, "fetch addresses")
 			return
 		}
-		c.Header("Content-Type", "application/json")
 		c.JSON(http.StatusOK, gin.H{"addresses": addresses})
 	}
 }
 
-func UpdateAddress(id int64) gin.HandlerFunc {
+func (app *Application) GetAddress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+			return
+		}
+		address, err := app.Addresses.Get(ctx, userId, id)
+		if err != nil {
+			addressError(c, err, "fetch address")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": address})
+	}
+}
+
+func (app *Application) UpdateAddress() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Create a timeout context
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		// Validate and bind userId from query parameters
-		var userId int64
-		if err := c.ShouldBindQuery(&userId); err != nil || userId == 0 {
-			c.Header("Content-Type", "application/json")
-			log.Println("Invalid or missing user ID:", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID must be provided and valid"})
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
-
-		// Retrieve the existing address based on ID
-		var existingAddress models.Address
-		db := database.Client
-		if err := db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userId).First(&existingAddress).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.Header("Content-Type", "application/json")
-				log.Println("Address not found")
-				c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
-			} else {
-				c.Header("Content-Type", "application/json")
-				log.Println("Failed to retrieve address:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve address"})
-			}
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
 			return
 		}
 
 		// Bind the incoming JSON payload to the Address struct
 		var updatedData models.Address
 		if err := c.ShouldBindJSON(&updatedData); err != nil {
-			c.Header("Content-Type", "application/json")
 			log.Println("Failed to bind JSON:", err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to bind JSON"})
 			return
 		}
+		if !app.checkAddress(ctx, c, &updatedData) {
+			return
+		}
 
-		// Update the fields of the existing address
-		existingAddress.Street = updatedData.Street
-		existingAddress.City = updatedData.City
-		existingAddress.State = updatedData.State
-		existingAddress.Country = updatedData.Country
-		existingAddress.UpdatedAt = time.Now()
-
-		// Save the updated address to the database
-		if err := db.WithContext(ctx).Save(&existingAddress).Error; err != nil {
-			c.Header("Content-Type", "application/json")
-			log.Println("Failed to update address:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update address"})
+		address, err := app.Addresses.Update(ctx, userId, id, updatedData)
+		if err != nil {
+			addressError(c, err, "update address")
 			return
 		}
 
 		// Respond with success
-		c.Header("Content-Type", "application/json")
-		c.JSON(http.StatusOK, gin.H{"message": "Address updated successfully", "data": existingAddress})
+		c.JSON(http.StatusOK, gin.H{"message": "Address updated successfully", "data": address})
 	}
 }
 
-func DeleteAddress(id int64) gin.HandlerFunc {
+// SetDefaultAddress makes the address the default for the :kind
+// (shipping or billing) used at checkout.
+func (app *Application) SetDefaultAddress() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Create a timeout context
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		// Validate userId query parameter
-		userId := c.Query("user_id")
-		if userId == "" {
-			c.Header("Content-Type", "application/json")
-			log.Println("Please provide a valid user ID")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Please provide a valid user ID"})
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
-
-		// Fetch addresses to validate if the user has addresses
-		var addresses []models.Address
-		db := database.Client
-		if err := db.WithContext(ctx).Where("user_id = ?", userId).Find(&addresses).Error; err != nil {
-			c.Header("Content-Type", "application/json")
-			log.Println("Failed to fetch addresses:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch addresses"})
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
 			return
 		}
-
-		// Check if any addresses exist for the user
-		if len(addresses) == 0 {
-			c.Header("Content-Type", "application/json")
-			log.Println("No addresses found for the given user ID")
-			c.JSON(http.StatusNotFound, gin.H{"error": "No addresses found for the given user ID"})
+		address, err := app.Addresses.SetDefault(ctx, userId, id, c.Param("kind"))
+		if err != nil {
+			addressError(c, err, "set default address")
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{"message": "Default " + c.Param("kind") + " address updated", "data": address})
+	}
+}
+
+func (app *Application) DeleteAddress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Create a timeout context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
 
-		// Delete the address by its ID
-		if err := db.WithContext(ctx).Where("id = ?", id).Delete(&models.Address{}).Error; err != nil {
-			c.Header("Content-Type", "application/json")
-			log.Println("Failed to delete address:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete address"})
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+			return
+		}
+		if err := app.Addresses.Delete(ctx, userId, id); err != nil {
+			addressError(c, err, "delete address")
 			return
 		}
 
 		// Respond with success
-		c.Header("Content-Type", "application/json")
 		c.JSON(http.StatusOK, gin.H{"message": "Address deleted successfully"})
 	}
 }
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/config"
+	"githum.com/muhammadAslam/ecommerce/inventory"
+	"githum.com/muhammadAslam/ecommerce/jobs"
+	"githum.com/muhammadAslam/ecommerce/moderation"
+	"githum.com/muhammadAslam/ecommerce/repository"
+	"githum.com/muhammadAslam/ecommerce/storage"
+	"githum.com/muhammadAslam/ecommerce/tokens"
+	"githum.com/muhammadAslam/ecommerce/validation"
+)
+
+// Application holds what the handlers depend on; every handler is a method
+// on it. main builds the one instance the server uses.
+type Application struct {
+	Products     repository.ProductRepository
+	Carts        repository.CartRepository
+	Orders       repository.OrderRepository
+	Users        repository.UserRepository
+	Addresses    repository.AddressRepository
+	Attributes   repository.AttributeRepository
+	Prices       repository.PriceRepository
+	Imports      repository.ImportRepository
+	ProductFiles repository.FileRepository
+	Images       repository.ImageRepository
+	Inventory    repository.InventoryRepository
+	Reviews      repository.ReviewRepository
+	Wishlists    repository.WishlistRepository
+	Idempotency  repository.IdempotencyRepository
+
+	// Jobs runs the work handlers start in the background, such as imports,
+	// so a shutdown waits for it.
+	Jobs *jobs.Group
+	// Tokens signs access tokens, guest cart tokens and download links.
+	Tokens *tokens.Manager
+	// Files stores uploads: the files of digital products and product images.
+	Files storage.BlobStore
+	// AllocationStrategy picks the warehouses that ship an order when its
+	// payment is captured.
+	AllocationStrategy inventory.Strategy
+	// PreOrderPaymentWindow is how long after the release date a pre-order
+	// can still be paid.
+	PreOrderPaymentWindow time.Duration
+	// ReviewRules decide whether reviews are published immediately or
+	// queued for an admin.
+	ReviewRules moderation.Rules
+	// AddressVerifier checks new and edited addresses for deliverability. It
+	// can be replaced with a client for an external verification provider.
+	AddressVerifier validation.Verifier
+	// Ready tells the readiness probe whether the dependencies can serve
+	// traffic. Nil means always ready.
+	Ready func(ctx context.Context) error
+
+	draining atomic.Bool
+}
+
+// NewApplication wires the handlers to the repositories and the token
+// manager. The other dependencies start with defaults that the caller may
+// replace: single warehouse allocation, the default pre-order payment window,
+// offline address verification, the default review rules, no file store and
+// background jobs nobody waits for.
+func NewApplication(repos repository.Repositories, tokenManager *tokens.Manager) *Application {
+	return &Application{
+		Products:              repos.Products,
+		Carts:                 repos.Carts,
+		Orders:                repos.Orders,
+		Users:                 repos.Users,
+		Addresses:             repos.Addresses,
+		Attributes:            repos.Attributes,
+		Prices:                repos.Prices,
+		Imports:               repos.Imports,
+		ProductFiles:          repos.ProductFiles,
+		Images:                repos.Images,
+		Inventory:             repos.Inventory,
+		Reviews:               repos.Reviews,
+		Wishlists:             repos.Wishlists,
+		Idempotency:           repos.Idempotency,
+		Jobs:                  jobs.NewGroup(context.Background()),
+		Tokens:                tokenManager,
+		AllocationStrategy:    inventory.SingleWarehouse{},
+		PreOrderPaymentWindow: time.Duration(config.Default().Inventory.PreOrderPaymentWindow),
+		ReviewRules:           moderation.NewRules(config.Default().Reviews),
+		AddressVerifier:       validation.OfflineVerifier{},
+	}
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+// attributeError maps attribute failures to an HTTP response.
+func attributeError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, database.ErrCantFindAttribute), errors.Is(err, database.ErrCantFindCategory):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrAttributeCodeTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrAttributeDefinitionNotValid), errors.Is(err, database.ErrAttributeIdIsNotValid),
+		errors.Is(err, database.ErrAttributeValueNotValid), errors.Is(err, database.ErrAttributeFilterNotValid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Println("Failed to "+action+":", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// productQuery reads the listing parameters ?category_id= and any number
+// of ?filter= attribute conditions, such as
+// filter=ram_gb>=16&filter=material=cotton.
+func productQuery(c *gin.Context) (database.ProductQuery, error) {
+	var query database.ProductQuery
+	if value := c.Query("category_id"); value != "" {
+		categoryId, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return query, database.ErrCantFindCategory
+		}
+		query.CategoryID = categoryId
+	}
+	for _, expression := range c.QueryArray("filter") {
+		filter, err := database.ParseAttributeFilter(expression)
+		if err != nil {
+			return query, err
+		}
+		query.Filters = append(query.Filters, filter)
+	}
+	return query, nil
+}
+
+// attributeRequest is the body of the attribute definition endpoints.
+type attributeRequest struct {
+	Code       string   `json:"code"`
+	Name       string   `json:"name" binding:"required"`
+	Type       string   `json:"type"`
+	Unit       string   `json:"unit"`
+	Options    []string `json:"options"`
+	Required   bool     `json:"required"`
+	Filterable *bool    `json:"filterable"` // defaults to true
+}
+
+func (r *attributeRequest) definition() models.AttributeDefinition {
+	definition := models.AttributeDefinition{
+		Code:       r.Code,
+		Name:       r.Name,
+		Type:       r.Type,
+		Unit:       r.Unit,
+		Options:    r.Options,
+		Required:   r.Required,
+		Filterable: r.Filterable == nil || *r.Filterable,
+	}
+	return definition
+}
+
+// GetCategoryAttributes lists the attribute definitions of the category :id.
+func (app *Application) GetCategoryAttributes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+			return
+		}
+		definitions, err := app.Attributes.List(ctx, id)
+		if err != nil {
+			attributeError(c, err, "get attributes")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": definitions})
+	}
+}
+
+// CreateCategoryAttribute defines a new attribute for the products of the
+// category :id.
+func (app *Application) CreateCategoryAttribute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+			return
+		}
+		var request attributeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		definition := request.definition()
+		definition.CategoryID = id
+		if err := app.Attributes.Create(ctx, &definition); err != nil {
+			attributeError(c, err, "create attribute")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": definition})
+	}
+}
+
+// UpdateAttribute changes an attribute definition. Its code and type can't
+// change, as product values were validated against them.
+func (app *Application) UpdateAttribute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attribute ID"})
+			return
+		}
+		var request attributeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		definition, err := app.Attributes.Update(ctx, id, request.definition())
+		if err != nil {
+			attributeError(c, err, "update attribute")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": definition})
+	}
+}
+
+func (app *Application) DeleteAttribute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attribute ID"})
+			return
+		}
+		if err := app.Attributes.Delete(ctx, id); err != nil {
+			attributeError(c, err, "delete attribute")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Attribute deleted"})
+	}
+}
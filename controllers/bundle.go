@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+type bundleRequest struct {
+	Pricing    string  `json:"pricing"`
+	Discount   float64 `json:"discount"`
+	Components []struct {
+		ProductID int64 `json:"product_id" binding:"required"`
+		Quantity  int   `json:"quantity" binding:"required,min=1"`
+	} `json:"components" binding:"dive"`
+}
+
+// SetBundleComponents makes the product :id a bundle of the given component
+// products. Pricing is "fixed" (the bundle's own price) or "discount" (the
+// components' total minus Discount percent). An empty component list turns
+// the bundle back into a plain product.
+func (app *Application) SetBundleComponents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		var request bundleRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		components := make([]models.BundleComponent, len(request.Components))
+		for i, component := range request.Components {
+			components[i] = models.BundleComponent{ComponentID: component.ProductID, Quantity: component.Quantity}
+		}
+		bundle, err := app.Products.SetBundle(ctx, id, request.Pricing, request.Discount, components)
+		if err != nil {
+			inventoryError(c, err, "update bundle")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": bundle})
+	}
+}
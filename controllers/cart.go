@@ -10,18 +10,23 @@ import (
 	"githum.com/muhammadAslam/ecommerce/database"
 )
 
-type Application struct {
-	ProductData *database.ProductData
-	UserData    *database.UserData
-}
-
-func NewApplication(productData *database.ProductData, userData *database.UserData) *Application {
-	return &Application{
-		ProductData: productData,
-		UserData:    userData,
+// cartError maps cart failures to an HTTP response.
+func cartError(c *gin.Context, err error, action string) {
+	switch err {
+	case database.ErrCanNotFindProduct, database.ErrCantRemoveItemCart, database.ErrCantUpdateProductQuantity:
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case database.ErrQuantityMustBePositive, database.ErrProductIdIsNotValid:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case database.ErrInsufficientStock, database.ErrExceedsMaxPerOrder:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Println("Failed to "+action+":", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
 	}
 }
 
+// AddToCart adds the product ?id= to the logged-in user's cart, one unit
+// unless ?quantity= says otherwise.
 func (app *Application) AddToCart() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Add product to cart
@@ -31,33 +36,28 @@ func (app *Application) AddToCart() gin.HandlerFunc {
 			_ = c.AbortWithError(http.StatusBadRequest, errors.New("product not found"))
 			return
 		}
-		userQueryId := c.Query("id")
-		if userQueryId == "" {
-			log.Println("User not found")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("User not found"))
-			return
-		}
 		productId, err := strconv.Atoi(productQueryById)
 		if err != nil {
 			log.Println("Invalid product ID")
 			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Invalid product ID"))
 			return
 		}
-		userId, err := strconv.Atoi(userQueryId)
-		if err != nil {
-			log.Println("Invalid user ID")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Invalid user ID"))
-			return
+		quantity := 1
+		if value := c.Query("quantity"); value != "" {
+			if quantity, err = strconv.Atoi(value); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid quantity"})
+				return
+			}
 		}
-		if _, err := app.ProductData.GetById(int64(productId)); err != nil {
-			log.Println("Product not found")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Product not found"))
+		userId, ok := currentUserID(c)
+		if !ok {
+			log.Println("User not found")
+			_ = c.AbortWithError(http.StatusUnauthorized, errors.New("User not found"))
 			return
 		}
-		err = database.AddProductToCart(c.Request.Context(), app.ProductData.DB, int64(userId), int64(productId))
+		err = app.Carts.Add(c.Request.Context(), userId, int64(productId), quantity)
 		if err != nil {
-			log.Println("Failed to add product to cart")
-			_ = c.AbortWithError(http.StatusInternalServerError, errors.New("Failed to add product to cart"))
+			cartError(c, err, "add product to cart")
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "Product added to cart"})
@@ -74,28 +74,21 @@ func (app *Application) RemoveFromCart() gin.HandlerFunc {
 			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Product not found"))
 			return
 		}
-		userQueryId := c.Query("id")
-		if userQueryId == "" {
-			log.Println("User not found")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("User not found"))
-			return
-		}
 		productId, err := strconv.Atoi(productQueryId)
 		if err != nil {
 			log.Println("Invalid product ID")
 			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Invalid product ID"))
 			return
 		}
-		userId, err := strconv.Atoi(userQueryId)
-		if err != nil {
-			log.Println("Invalid user ID")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Invalid user ID"))
+		userId, ok := currentUserID(c)
+		if !ok {
+			log.Println("User not found")
+			_ = c.AbortWithError(http.StatusUnauthorized, errors.New("User not found"))
 			return
 		}
-		err = database.RemoveProductFromCart(c.Request.Context(), app.ProductData.DB, int64(userId), int64(productId))
+		err = app.Carts.Remove(c.Request.Context(), userId, int64(productId))
 		if err != nil {
-			log.Println("Failed to remove product from cart")
-			_ = c.AbortWithError(http.StatusInternalServerError, errors.New("Failed to remove product from cart"))
+			cartError(c, err, "remove product from cart")
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "Product removed from cart"})
@@ -103,58 +96,117 @@ func (app *Application) RemoveFromCart() gin.HandlerFunc {
 	}
 }
 
+// GetCart returns the logged-in user's cart priced from current products,
+// with warnings for lines whose price, stock or availability changed.
 func (app *Application) GetCart() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get cart list of products for a user
-		userQueryId := c.Query("id")
-		if userQueryId == "" {
+		userId, ok := currentUserID(c)
+		if !ok {
 			log.Println("User not found")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("User not found"))
+			_ = c.AbortWithError(http.StatusUnauthorized, errors.New("User not found"))
 			return
 		}
-		userId, err := strconv.Atoi(userQueryId)
+		cart, err := app.Carts.View(c.Request.Context(), userId)
 		if err != nil {
-			log.Println("Invalid user ID")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Invalid user ID"))
+			cartError(c, err, "get cart items")
 			return
 		}
-		cartItems, err := database.GetCartItems(c.Request.Context(), app.ProductData.DB, int64(userId))
+		c.JSON(http.StatusOK, gin.H{"cartItems": cart.Items, "warnings": cart.Warnings, "totals": cart.Totals, "price": cart.Totals.Total})
+	}
+}
+
+// UpdateCartItem sets the quantity of the product :id in the user's cart.
+func (app *Application) UpdateCartItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := currentUserID(c)
+		if !ok {
+			log.Println("User not found")
+			_ = c.AbortWithError(http.StatusUnauthorized, errors.New("User not found"))
+			return
+		}
+		productId, err := paramID(c, "id")
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		var request struct {
+			Quantity int `json:"quantity" binding:"required,min=1"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx := c.Request.Context()
+		if err := app.Carts.SetQuantity(ctx, userId, productId, request.Quantity); err != nil {
+			cartError(c, err, "update product quantity")
+			return
+		}
+		cart, err := app.Carts.View(ctx, userId)
 		if err != nil {
-			log.Println("Failed to get cart items")
-			_ = c.AbortWithError(http.StatusInternalServerError, errors.New("Failed to get cart items"))
+			cartError(c, err, "get cart items")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"cartItems": cartItems})
+		c.JSON(http.StatusOK, gin.H{"message": "Cart updated", "cartItems": cart.Items, "warnings": cart.Warnings, "totals": cart.Totals})
+	}
+}
+
+// addressQuery reads an optional address id from the query string; zero
+// means "use the user's default".
+func addressQuery(c *gin.Context, name string) (int64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// checkoutError maps checkout failures to an HTTP response.
+func checkoutError(c *gin.Context, err error) {
+	switch err {
+	case database.ErrCantFindUserAddress, database.ErrCantFindAddress, database.ErrAddressIdIsNotValid:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Shipping address not found, add an address or choose a default"})
+	case database.ErrCantCheckoutCart:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+	case database.ErrPreOrderMixedCart:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case database.ErrCanNotFindProduct, database.ErrCantFindProductInCart:
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case database.ErrInsufficientStock, database.ErrExceedsMaxPerOrder:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error() + ", review your cart"})
+	default:
+		log.Println("Failed to checkout:", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to checkout"})
 	}
 }
 
 func (app *Application) Checkout() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Checkout cart items for a user
-		userQueryId := c.Query("id")
-		if userQueryId == "" {
+		// Checkout cart items for the logged-in user
+		userId, ok := currentUserID(c)
+		if !ok {
 			log.Println("User not found")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("User not found"))
+			_ = c.AbortWithError(http.StatusUnauthorized, errors.New("User not found"))
 			return
 		}
-		userId, err := strconv.Atoi(userQueryId)
+		shippingId, err := addressQuery(c, "address_id")
 		if err != nil {
-			log.Println("Invalid user ID")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Invalid user ID"))
+			log.Println("Invalid address ID")
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Invalid address ID"))
 			return
 		}
-		cartItems, err := database.GetCartItems(c.Request.Context(), app.ProductData.DB, int64(userId))
+		billingId, err := addressQuery(c, "billing_address_id")
 		if err != nil {
-			log.Println("Failed to get cart items")
-			_ = c.AbortWithError(http.StatusInternalServerError, errors.New("Failed to get cart items"))
+			log.Println("Invalid billing address ID")
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Invalid billing address ID"))
 			return
 		}
-		totalPrice := 0.0
-		for _, item := range cartItems {
-			totalPrice += item.Price * float64(item.Quantity)
+		order, err := app.Carts.Checkout(c.Request.Context(), userId, shippingId, billingId)
+		if err != nil {
+			checkoutError(c, err)
+			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Cart checked out", "price": totalPrice, "data": cartItems})
+		c.JSON(http.StatusOK, gin.H{"message": "Cart checked out, capture the payment to confirm the order", "price": order.TotalPrice, "data": order})
 	}
 }
 func (app *Application) GetInstantBuy() gin.HandlerFunc {
@@ -173,24 +225,29 @@ func (app *Application) GetInstantBuy() gin.HandlerFunc {
 			return
 		}
 
-		userQueryId := c.Query("id")
-		if userQueryId == "" {
+		userId, ok := currentUserID(c)
+		if !ok {
 			log.Println("User not found")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("User not found"))
+			_ = c.AbortWithError(http.StatusUnauthorized, errors.New("User not found"))
+			return
+		}
+		shippingId, err := addressQuery(c, "address_id")
+		if err != nil {
+			log.Println("Invalid address ID")
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Invalid address ID"))
 			return
 		}
-		userId, err := strconv.Atoi(userQueryId)
+		billingId, err := addressQuery(c, "billing_address_id")
 		if err != nil {
-			log.Println("Invalid user ID")
-			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Invalid user ID"))
+			log.Println("Invalid billing address ID")
+			_ = c.AbortWithError(http.StatusBadRequest, errors.New("Invalid billing address ID"))
 			return
 		}
-		product := database.GetInstantBuyProduct(c.Request.Context(), app.ProductData.DB, int64(userId), int64(productId))
+		order, err := app.Carts.InstantBuy(c.Request.Context(), userId, int64(productId), shippingId, billingId)
 		if err != nil {
-			log.Println("Failed to get instant buy product")
-			_ = c.AbortWithError(http.StatusInternalServerError, errors.New("Failed to get instant buy product"))
+			checkoutError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"product": product})
+		c.JSON(http.StatusOK, gin.H{"data": order})
 	}
 }
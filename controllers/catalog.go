@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/catalog"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+// maxImportSize bounds the size of an uploaded import file.
+const maxImportSize = 32 << 20
+
+// importFormat takes the format from ?format=, or else from the file name.
+func importFormat(c *gin.Context, filename string) string {
+	if format := c.Query("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		return catalog.FormatCSV
+	case ".jsonl", ".ndjson":
+		return catalog.FormatJSONL
+	}
+	return ""
+}
+
+// ImportProducts queues an import of the multipart "file", in CSV or JSON
+// Lines, and answers right away with the job to poll. Rows are upserted by
+// ID or SKU; ?dry_run=true only reports what would fail.
+func (app *Application) ImportProducts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A file is required"})
+			return
+		}
+		format := importFormat(c, header.Filename)
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer file.Close()
+		reader, err := catalog.NewReader(format, file)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rows, rowErrors, err := catalog.ReadAll(reader)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Can't read import file: " + err.Error()})
+			return
+		}
+		job := models.ImportJob{Format: format, DryRun: dryRun, CreatedBy: c.GetInt64("uid")}
+		if err := app.Imports.Create(ctx, &job, rows, rowErrors); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create import job"})
+			return
+		}
+		running := job
+		app.Jobs.Go("import-"+strconv.FormatInt(job.ID, 10), func(ctx context.Context) error {
+			return app.Imports.Run(ctx, &running, rows)
+		})
+		c.JSON(http.StatusAccepted, gin.H{"data": job})
+	}
+}
+
+// GetImportJob reports the progress of an import and the rows it rejected.
+func (app *Application) GetImportJob() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import job ID"})
+			return
+		}
+		job, err := app.Imports.Get(ctx, id)
+		switch err {
+		case nil:
+			c.JSON(http.StatusOK, gin.H{"data": job})
+		case database.ErrCantFindImportJob:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			log.Println("Failed to get import job:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get import job"})
+		}
+	}
+}
+
+// ExportProducts streams the whole catalog as CSV or JSON Lines, in the
+// same layout ImportProducts reads.
+func (app *Application) ExportProducts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", catalog.FormatCSV)
+		writer, err := catalog.NewWriter(format, c.Writer)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		contentType := "text/csv"
+		if format == catalog.FormatJSONL {
+			contentType = "application/x-ndjson"
+		}
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", "attachment; filename=products."+format)
+		c.Status(http.StatusOK)
+		err = app.Imports.Export(c.Request.Context(), func(product *models.Product) error {
+			return writer.Write(catalog.FromProduct(product))
+		})
+		if err == nil {
+			err = writer.Flush()
+		}
+		if err != nil {
+			// The response has started, so the error can only be logged.
+			log.Println("Failed to export products:", err)
+		}
+	}
+}
@@ -2,22 +2,32 @@ package controllers
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"githum.com/muhammadAslam/ecommerce/database"
 	"githum.com/muhammadAslam/ecommerce/models"
-	"githum.com/muhammadAslam/ecommerce/tokens"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var UserData *database.UserData = database.NewUserData(database.DBSet(), "users")
-var ProductData *database.ProductData = database.NewProductData(database.DBSet(), "products")
 var validate = validator.New()
 
+// currentUserID returns the id of the user authenticated by middleware.Authentication.
+func currentUserID(c *gin.Context) (int64, bool) {
+	userId := c.GetInt64("uid")
+	return userId, userId > 0
+}
+
+// paramID parses a numeric path parameter such as /addresses/:id.
+func paramID(c *gin.Context, name string) (int64, error) {
+	return strconv.ParseInt(c.Param(name), 10, 64)
+}
+
 func HashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -35,7 +45,7 @@ func VerifyPassword(userPassword string, givenPassword string) (bool, string, er
 	}
 	return true, "", nil
 }
-func Signup() gin.HandlerFunc {
+func (app *Application) Signup() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -56,17 +66,24 @@ func Signup() gin.HandlerFunc {
 		}
 
 		// Check if the user already exists in the database
-		var existUser models.User
-		db := database.Client // Assuming database.Client is the initialized gorm.DB instance
-		if err := db.Where("email = ?", user.Email).First(&existUser).Error; err == nil {
+		if exists, err := app.Users.Exists(ctx, "email", user.Email); err != nil || exists {
+			if err != nil {
+				log.Println("Failed to look up user:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+				return
+			}
 			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
 			return
 		}
-		if err := db.Where("phone = ?", user.Phone).First(&existUser).Error; err == nil {
+		if exists, err := app.Users.Exists(ctx, "phone", user.Phone); err != nil || exists {
+			if err != nil {
+				log.Println("Failed to look up user:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+				return
+			}
 			c.JSON(http.StatusBadRequest, gin.H{"error": "User already exits with this phone"})
 			return
 		}
-		defer cancel()
 
 		// Hash the user's password
 		hashedPassword, err := HashPassword(user.Password)
@@ -78,22 +95,28 @@ func Signup() gin.HandlerFunc {
 		user.Password = hashedPassword
 		user.CreatedAt = time.Now()
 		user.UpdatedAt = time.Now()
-		token, refreshToken, _ := tokens.GenerateAllTokens(db, user.Email, user.Name)
-		user.Token = token
-		user.RefreshToken = refreshToken
 		user.Roles = "user"
 
 		// Save the new user to the database
-		if err := db.WithContext(ctx).Create(&user).Error; err != nil {
+		if err := app.Users.Create(ctx, &user); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 			return
 		}
 
+		// Tokens carry the user id, so they can only be issued once the user exists
+		token, refreshToken, _ := app.Tokens.GenerateAllTokens(user.Email, user.Name, user.ID, user.Roles)
+		user.Token = token
+		user.RefreshToken = refreshToken
+		if err := app.Users.UpdateTokens(ctx, user.ID, token, refreshToken); err != nil {
+			log.Println("Failed to store user tokens:", err)
+		}
+		app.mergeGuestCart(ctx, c, user.ID)
+
 		// Return success response
 		c.JSON(http.StatusCreated, gin.H{"data": user})
 	}
 }
-func Login() gin.HandlerFunc {
+func (app *Application) Login() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		var loginUser models.User
@@ -103,10 +126,8 @@ func Login() gin.HandlerFunc {
 		}
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		db := database.Client
-		fmt.Println("emails:", loginUser.Email)
-		var storedUser models.User
-		if err := db.WithContext(ctx).Where("email = ?", loginUser.Email).First(&storedUser).Error; err != nil {
+		storedUser, err := app.Users.GetByEmail(ctx, loginUser.Email)
+		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found", "message": err.Error()})
 			return
 		}
@@ -117,31 +138,57 @@ func Login() gin.HandlerFunc {
 		}
 		if !passwordIsValid {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
-			fmt.Println("password", loginUser.Password)
 			return
 		}
-		token, refreshToken, _ := tokens.GenerateAllTokens(db, storedUser.Email, storedUser.Name)
+		token, refreshToken, _ := app.Tokens.GenerateAllTokens(storedUser.Email, storedUser.Name, storedUser.ID, storedUser.Roles)
 		storedUser.Token = token
 		storedUser.RefreshToken = refreshToken
-		tokens.UpdateAllTokens(db, token, refreshToken, storedUser.ID)
+		if err := app.Users.UpdateTokens(ctx, storedUser.ID, token, refreshToken); err != nil {
+			log.Println("Failed to store user tokens:", err)
+		}
+		app.mergeGuestCart(ctx, c, storedUser.ID)
 		c.JSON(http.StatusOK, gin.H{"data": storedUser})
 
 	}
 }
 
-func AddProduct() gin.HandlerFunc {
+func (app *Application) AddProduct() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		var product models.Product
-		if err := c.ShouldBindJSON(&product); err != nil {
+		var request struct {
+			models.Product
+			Attributes map[string]interface{} // by attribute code
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		product := request.Product
+		if product.Price < 0 || product.CompareAtPrice != nil && *product.CompareAtPrice < 0 ||
+			product.Quantity < 0 || product.LowStockThreshold < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Price, CompareAtPrice, Quantity and LowStockThreshold can't be negative"})
+			return
+		}
+		if !validStockPolicy(product.StockPolicy) || product.BackorderLimit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "StockPolicy must be deny, backorder or preorder and BackorderLimit can't be negative"})
+			return
+		}
 		product.CreatedAt = time.Now()
 		product.UpdatedAt = time.Now()
-		db := database.Client
-		if err := db.WithContext(ctx).Create(&product).Error; err != nil {
+		// New products start as drafts unless another status is asked for,
+		// and their stock enters the ledger as a receipt.
+		err := app.Products.Create(ctx, &product, request.Attributes, c.GetInt64("uid"))
+		if errors.Is(err, database.ErrAttributeValueNotValid) || err == database.ErrProductStatusNotValid || err == database.ErrProductScheduleNotValid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, database.ErrInitialStockNotBooked) {
+			log.Println("Failed to book initial stock:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Product created but its stock could not be booked", "data": product})
+			return
+		}
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
 			return
 		}
@@ -149,13 +196,16 @@ func AddProduct() gin.HandlerFunc {
 	}
 }
 
-func GetProducts() gin.HandlerFunc {
+func (app *Application) GetProducts() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		var productList []models.Product
-		db := database.Client
-		db.WithContext(ctx).Find(&productList)
+		productList, err := app.Products.List(ctx)
+		if err != nil {
+			log.Println("Failed to list products:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list products"})
+			return
+		}
 		if len(productList) == 0 {
 			c.JSON(http.StatusNoContent, gin.H{"error": "No products found"})
 			return
@@ -165,79 +215,254 @@ func GetProducts() gin.HandlerFunc {
 	}
 }
 
-func GetProductByID() gin.HandlerFunc {
+// GetProductByID shows a published product with its bundle components,
+// attributes and images.
+func (app *Application) GetProductByID() gin.HandlerFunc {
+	return app.productByID(true)
+}
+
+// GetAdminProductByID is GetProductByID for products in any status.
+func (app *Application) GetAdminProductByID() gin.HandlerFunc {
+	return app.productByID(false)
+}
+
+func (app *Application) productByID(publishedOnly bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		id := c.Param(":id")
-		var product models.Product
-		db := database.Client
-		db.WithContext(ctx).Where("id = ?", id).Find(&product)
-		if product.ID == 0 {
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		product, err := app.Products.Get(ctx, id, publishedOnly)
+		if err == database.ErrCanNotFindProduct {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 			return
 		}
+		if err != nil {
+			log.Println("Failed to fetch product:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product"})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"product": product})
 	}
 }
 
-func UpdateProduct() gin.HandlerFunc {
+// productUpdate holds the fields an admin may change; omitted fields keep
+// their current value.
+type productUpdate struct {
+	CategoryID        *int64
+	Name              *string
+	SKU               *string
+	Description       *string
+	Price             *float64
+	CompareAtPrice    *float64 // 0 removes the "was" price
+	Quantity          *int
+	Image             *string
+	LowStockThreshold *int
+	StockPolicy       *string
+	BackorderLimit    *int
+	RestockDate       *time.Time
+	ReleaseDate       *time.Time
+	IsDigital         *bool
+	DownloadLimit     *int
+	Attributes        map[string]interface{} // by attribute code; null removes a value
+}
+
+// validStockPolicy reports whether policy is one of the product stock
+// policies; empty means the default, deny.
+func validStockPolicy(policy string) bool {
+	switch policy {
+	case "", models.StockPolicyDeny, models.StockPolicyBackorder, models.StockPolicyPreOrder:
+		return true
+	}
+	return false
+}
+
+func (app *Application) UpdateProduct() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		return
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		var update productUpdate
+		if err := c.ShouldBindJSON(&update); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if update.Price != nil && *update.Price < 0 || update.CompareAtPrice != nil && *update.CompareAtPrice < 0 ||
+			update.Quantity != nil && *update.Quantity < 0 || update.LowStockThreshold != nil && *update.LowStockThreshold < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Price, CompareAtPrice, Quantity and LowStockThreshold can't be negative"})
+			return
+		}
+		if update.StockPolicy != nil && !validStockPolicy(*update.StockPolicy) || update.BackorderLimit != nil && *update.BackorderLimit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "StockPolicy must be deny, backorder or preorder and BackorderLimit can't be negative"})
+			return
+		}
+		changes := map[string]interface{}{}
+		if update.CategoryID != nil {
+			changes["category_id"] = *update.CategoryID
+		}
+		if update.Name != nil {
+			changes["name"] = *update.Name
+		}
+		if update.SKU != nil {
+			changes["sku"] = *update.SKU
+		}
+		if update.Description != nil {
+			changes["description"] = *update.Description
+		}
+		if update.CompareAtPrice != nil {
+			if *update.CompareAtPrice == 0 {
+				changes["compare_at_price"] = nil
+			} else {
+				changes["compare_at_price"] = *update.CompareAtPrice
+			}
+		}
+		if update.Image != nil {
+			changes["image"] = *update.Image
+		}
+		if update.LowStockThreshold != nil {
+			changes["low_stock_threshold"] = *update.LowStockThreshold
+		}
+		if update.StockPolicy != nil {
+			changes["stock_policy"] = *update.StockPolicy
+		}
+		if update.BackorderLimit != nil {
+			changes["backorder_limit"] = *update.BackorderLimit
+		}
+		if update.RestockDate != nil {
+			changes["restock_date"] = *update.RestockDate
+		}
+		if update.ReleaseDate != nil {
+			changes["release_date"] = *update.ReleaseDate
+		}
+		if update.IsDigital != nil {
+			changes["is_digital"] = *update.IsDigital
+		}
+		if update.DownloadLimit != nil {
+			if *update.DownloadLimit < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "DownloadLimit can't be negative"})
+				return
+			}
+			changes["download_limit"] = *update.DownloadLimit
+		}
+		// Attribute values must fit the product's category, which may have changed.
+		product, err := app.Products.Update(ctx, id, database.ProductChanges{
+			Columns:       changes,
+			Price:         update.Price,
+			Quantity:      update.Quantity,
+			Attributes:    update.Attributes,
+			SetAttributes: update.Attributes != nil || update.CategoryID != nil,
+		}, c.GetInt64("uid"))
+		if err == database.ErrCanNotFindProduct {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
+		if errors.Is(err, database.ErrAttributeValueNotValid) || err == database.ErrBundleHasNoStock {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == database.ErrStockWouldGoNegative {
+			c.JSON(http.StatusConflict, gin.H{"error": "Not enough stock at the default warehouse, use a stock adjustment instead"})
+			return
+		}
+		if err != nil {
+			log.Println("Failed to update product:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": product})
 	}
 }
 
-func DeleteProduct() gin.HandlerFunc {
+func (app *Application) DeleteProduct() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		id := c.Param("id")
-		var product models.Product
-		db := database.Client
-		db.WithContext(ctx).Where("id =?", id).Delete(&product)
-		if product.ID == 0 {
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		// Deleted products go to the trash until PurgeDeletedProducts removes them.
+		err = app.Products.Delete(ctx, id)
+		if err == database.ErrCanNotFindProduct {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Product moved to trash"})
 	}
 }
 
-func GetProds() gin.HandlerFunc {
+// GetProds lists products, optionally narrowed by category and attribute
+// filters (see productQuery), with facet counts of the matches.
+func (app *Application) GetProds() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		var productList []models.Product
-		db := database.Client
-		db.WithContext(ctx).Find(&productList)
-		c.JSON(http.StatusOK, gin.H{"data": productList})
+		query, err := productQuery(c)
+		if err != nil {
+			attributeError(c, err, "list products")
+			return
+		}
+		productList, facets, err := app.Products.Search(ctx, query)
+		if err != nil {
+			attributeError(c, err, "list products")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": productList, "facets": facets})
 	}
 }
 
-func GetProdById() gin.HandlerFunc {
+func (app *Application) GetProdById() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		id := c.Param("id")
-		var product models.Product
-		db := database.Client
-		db.WithContext(ctx).Where("id =?", id).First(&product)
-		if product.ID == 0 {
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		product, err := app.Products.Get(ctx, id, false)
+		if err == database.ErrCanNotFindProduct {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 			return
 		}
+		if err != nil {
+			log.Println("Failed to fetch product:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product"})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"data": product})
 
 	}
 }
 
-func SearchProduct() gin.HandlerFunc {
+// SearchProduct finds products by name, taking the same filters as GetProds.
+func (app *Application) SearchProduct() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var productList []models.Product
-		product := c.Query("product")
-		db := database.Client
-		db.Where("name LIKE?", "%"+product+"%").Find(&productList)
-		c.JSON(http.StatusOK, gin.H{"data": productList})
-
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		query, err := productQuery(c)
+		if err != nil {
+			attributeError(c, err, "search products")
+			return
+		}
+		query.Name = c.Query("product")
+		productList, facets, err := app.Products.Search(ctx, query)
+		if err != nil {
+			attributeError(c, err, "search products")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": productList, "facets": facets})
 	}
 }
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/storage"
+	"githum.com/muhammadAslam/ecommerce/tokens"
+)
+
+// DownloadLinkLifetime is how long a signed download link stays valid.
+const DownloadLinkLifetime = 24 * time.Hour
+
+// maxProductFileSize bounds the size of an uploaded file of a digital product.
+const maxProductFileSize = 1 << 30
+
+// digitalError maps digital product and download failures to an HTTP response.
+func digitalError(c *gin.Context, err error, action string) {
+	switch err {
+	case database.ErrCanNotFindProduct, database.ErrCantFindProductFile, database.ErrCantFindOrder, storage.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case database.ErrProductNotDigital, database.ErrOrderIdIsNotValid, database.ErrOrderItemIdIsNotValid, storage.ErrInvalidKey:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case database.ErrDownloadNotAvailable, database.ErrDownloadLimitReached:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		log.Println("Failed to "+action+":", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+func (app *Application) GetProductFiles() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		files, err := app.ProductFiles.List(ctx, id)
+		if err != nil {
+			digitalError(c, err, "get product files")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": files})
+	}
+}
+
+// UploadProductFile stores the multipart "file" as a file of the digital
+// product :id.
+func (app *Application) UploadProductFile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxProductFileSize+1<<20)
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A file is required"})
+			return
+		}
+		upload, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer upload.Close()
+		// Uploads can be large, so only the database work is time boxed.
+		ctx := c.Request.Context()
+		suffix := make([]byte, 8)
+		if _, err := rand.Read(suffix); err != nil {
+			digitalError(c, err, "upload product file")
+			return
+		}
+		name := path.Base(header.Filename)
+		key := fmt.Sprintf("products/%d/%s-%s", id, hex.EncodeToString(suffix), name)
+		size, err := app.Files.Put(ctx, key, upload)
+		if err != nil {
+			digitalError(c, err, "upload product file")
+			return
+		}
+		file := models.ProductFile{
+			ProductID:   id,
+			Name:        name,
+			StorageKey:  key,
+			ContentType: header.Header.Get("Content-Type"),
+			Size:        size,
+		}
+		dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := app.ProductFiles.Add(dbCtx, &file); err != nil {
+			if err := app.Files.Delete(ctx, key); err != nil {
+				log.Println("Failed to delete orphaned upload:", err)
+			}
+			digitalError(c, err, "upload product file")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": file})
+	}
+}
+
+func (app *Application) DeleteProductFile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		fileId, err := paramID(c, "fileId")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+			return
+		}
+		file, err := app.ProductFiles.Delete(ctx, id, fileId)
+		if err != nil {
+			digitalError(c, err, "delete product file")
+			return
+		}
+		if err := app.Files.Delete(ctx, file.StorageKey); err != nil {
+			log.Println("Failed to delete stored file:", err)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "File deleted"})
+	}
+}
+
+// GetDownloads lists the files of a paid order's digital items, each with
+// a signed link valid for DownloadLinkLifetime.
+func (app *Application) GetDownloads() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+		downloads, err := app.ProductFiles.Downloads(ctx, userId, id)
+		if err != nil {
+			digitalError(c, err, "get downloads")
+			return
+		}
+		expires := time.Now().Add(DownloadLinkLifetime)
+		links := make([]gin.H, len(downloads))
+		for i, download := range downloads {
+			signature := app.Tokens.SignDownload(download.OrderItemID, download.FileID, expires)
+			links[i] = gin.H{
+				"download":   download,
+				"url":        fmt.Sprintf("/downloads/%d/%d?expires=%d&signature=%s", download.OrderItemID, download.FileID, expires.Unix(), signature),
+				"expires_at": expires,
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"data": links})
+	}
+}
+
+// Download serves a file through a signed link from GetDownloads. The link
+// is the only credential, so it works without logging in until it expires
+// or the item's download limit is used up.
+func (app *Application) Download() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		itemId, err := paramID(c, "item")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid download link"})
+			return
+		}
+		fileId, err := paramID(c, "file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid download link"})
+			return
+		}
+		expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid download link"})
+			return
+		}
+		switch err := app.Tokens.ValidateDownload(itemId, fileId, expires, c.Query("signature")); err {
+		case nil:
+		case tokens.ErrDownloadLinkExpired:
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx := c.Request.Context()
+		var content io.ReadCloser
+		file, err := app.ProductFiles.ClaimDownload(ctx, itemId, fileId, func(file *models.ProductFile) error {
+			var err error
+			content, err = app.Files.Open(ctx, file.StorageKey)
+			return err
+		})
+		if content != nil {
+			defer content.Close()
+		}
+		if err != nil {
+			digitalError(c, err, "download file")
+			return
+		}
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Name))
+		c.DataFromReader(http.StatusOK, file.Size, contentType, content, nil)
+	}
+}
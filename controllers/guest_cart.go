@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/database"
+)
+
+const (
+	// CartCookie carries the signed guest cart token for browsers.
+	CartCookie = "cart_token"
+	// CartHeader carries the same token for API clients without cookies.
+	CartHeader = "X-Cart-Token"
+)
+
+// guestCartToken returns the cart id and the signed cart token sent as a
+// cookie or header, if the token is valid.
+func (app *Application) guestCartToken(c *gin.Context) (cartId string, signed string, ok bool) {
+	signed = c.GetHeader(CartHeader)
+	if signed == "" {
+		signed, _ = c.Cookie(CartCookie)
+	}
+	if signed == "" {
+		return "", "", false
+	}
+	cartId, err := app.Tokens.ValidateCartToken(signed)
+	if err != nil {
+		return "", "", false
+	}
+	return cartId, signed, true
+}
+
+// guestCartID returns the cart id from a valid signed cart token sent as a
+// cookie or header.
+func (app *Application) guestCartID(c *gin.Context) (string, bool) {
+	cartId, _, ok := app.guestCartToken(c)
+	return cartId, ok
+}
+
+// touchGuestCartID is guestCartID for the cart handlers. Every access keeps
+// the cart alive for another GuestCartLifetime, so the cookie is sent back
+// with a fresh max-age to match.
+func (app *Application) touchGuestCartID(c *gin.Context) (string, bool) {
+	cartId, signed, ok := app.guestCartToken(c)
+	if ok {
+		setCartCookie(c, signed)
+	}
+	return cartId, ok
+}
+
+// ensureGuestCartID returns the visitor's cart id, issuing a new signed
+// token (cookie and response header) if they don't have a valid one yet.
+func (app *Application) ensureGuestCartID(c *gin.Context) (string, error) {
+	if cartId, ok := app.touchGuestCartID(c); ok {
+		return cartId, nil
+	}
+	cartId, signed, err := app.Tokens.GenerateCartToken()
+	if err != nil {
+		return "", err
+	}
+	setCartCookie(c, signed)
+	c.Header(CartHeader, signed)
+	return cartId, nil
+}
+
+// setCartCookie stores the signed cart token in the browser for as long as
+// the cart lives.
+func setCartCookie(c *gin.Context, signed string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(CartCookie, signed, int(database.GuestCartLifetime.Seconds()), "/", "", false, true)
+}
+
+// mergeGuestCart folds the visitor's guest cart into the user's cart after
+// login or signup and forgets the cart token. Failures are logged; they must
+// not stop the user from logging in.
+func (app *Application) mergeGuestCart(ctx context.Context, c *gin.Context, userId int64) {
+	cartId, ok := app.guestCartID(c)
+	if !ok {
+		return
+	}
+	if err := app.Carts.MergeGuest(ctx, cartId, userId); err != nil {
+		log.Println("Failed to merge guest cart:", err)
+		return
+	}
+	c.SetCookie(CartCookie, "", -1, "/", "", false, true)
+}
+
+type guestCartItemRequest struct {
+	ProductID int64 `json:"product_id" binding:"required"`
+	Quantity  int   `json:"quantity" binding:"omitempty,min=1"`
+}
+
+// guestCartError maps guest cart failures to an HTTP response.
+func guestCartError(c *gin.Context, err error, action string) {
+	switch err {
+	case database.ErrCanNotFindProduct, database.ErrCantRemoveItemCart, database.ErrCantUpdateProductQuantity:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case database.ErrQuantityMustBePositive:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case database.ErrInsufficientStock, database.ErrExceedsMaxPerOrder:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Println("Failed to "+action+":", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+func (app *Application) GetGuestCart() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		cartId, _ := app.touchGuestCartID(c)
+		cart, err := app.Carts.ViewGuest(ctx, cartId)
+		if err != nil {
+			guestCartError(c, err, "get cart items")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"cartItems": cart.Items, "warnings": cart.Warnings, "totals": cart.Totals, "price": cart.Totals.Total})
+	}
+}
+
+func (app *Application) AddToGuestCart() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var request guestCartItemRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if request.Quantity == 0 {
+			request.Quantity = 1
+		}
+		cartId, err := app.ensureGuestCartID(c)
+		if err != nil {
+			guestCartError(c, err, "create cart")
+			return
+		}
+		if err := app.Carts.AddGuest(ctx, cartId, request.ProductID, request.Quantity); err != nil {
+			guestCartError(c, err, "add product to cart")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Product added to cart"})
+	}
+}
+
+func (app *Application) UpdateGuestCartItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		productId, err := paramID(c, "productId")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		var request struct {
+			Quantity int `json:"quantity" binding:"required,min=1"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		cartId, _ := app.touchGuestCartID(c)
+		if err := app.Carts.SetGuestQuantity(ctx, cartId, productId, request.Quantity); err != nil {
+			guestCartError(c, err, "update product quantity")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Cart updated"})
+	}
+}
+
+func (app *Application) RemoveFromGuestCart() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		productId, err := paramID(c, "productId")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		cartId, _ := app.touchGuestCartID(c)
+		if err := app.Carts.RemoveGuest(ctx, cartId, productId); err != nil {
+			guestCartError(c, err, "remove product from cart")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Product removed from cart"})
+	}
+}
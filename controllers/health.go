@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Drain makes the readiness probe fail so load balancers stop sending new
+// requests while the server finishes the ones in flight.
+func (app *Application) Drain() {
+	app.draining.Store(true)
+}
+
+// Healthz is the liveness probe: it only says the process is serving.
+func (app *Application) Healthz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// Readyz is the readiness probe. It fails while the server is draining and
+// when Ready reports a problem, such as an unreachable database or pending
+// migrations.
+func (app *Application) Readyz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if app.draining.Load() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down"})
+			return
+		}
+		if app.Ready != nil {
+			var ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if err := app.Ready(ctx); err != nil {
+				log.Println("Readiness check failed:", err)
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is not ready"})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	}
+}
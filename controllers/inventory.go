@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+// inventoryError maps warehouse and stock failures to an HTTP response.
+func inventoryError(c *gin.Context, err error, action string) {
+	switch err {
+	case database.ErrCantFindWarehouse, database.ErrWarehouseIdIsNotValid, database.ErrCanNotFindProduct:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case database.ErrMovementTypeNotValid, database.ErrMovementReasonMissing, database.ErrQuantityMustBePositive, database.ErrTransferToSameSource,
+		database.ErrBundleHasNoStock, database.ErrBundleComponentNotValid, database.ErrBundlePricingNotValid:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case database.ErrStockWouldGoNegative:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Println("Failed to "+action+":", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+func (app *Application) GetWarehouses() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		warehouses, err := app.Inventory.Warehouses(ctx)
+		if err != nil {
+			inventoryError(c, err, "fetch warehouses")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"warehouses": warehouses})
+	}
+}
+
+type warehouseRequest struct {
+	Code     string `json:"code"`
+	Name     string `json:"name" binding:"required"`
+	Priority int    `json:"priority"`
+	Active   *bool  `json:"active"`
+}
+
+func (app *Application) CreateWarehouse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var request warehouseRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if request.Code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Warehouse code is required"})
+			return
+		}
+		warehouse := models.Warehouse{Code: request.Code, Name: request.Name, Priority: request.Priority, Active: request.Active == nil || *request.Active}
+		if err := app.Inventory.CreateWarehouse(ctx, &warehouse); err != nil {
+			inventoryError(c, err, "create warehouse")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": warehouse})
+	}
+}
+
+func (app *Application) UpdateWarehouse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid warehouse ID"})
+			return
+		}
+		var request warehouseRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		changes := models.Warehouse{Name: request.Name, Priority: request.Priority, Active: request.Active == nil || *request.Active}
+		warehouse, err := app.Inventory.UpdateWarehouse(ctx, id, changes)
+		if err != nil {
+			inventoryError(c, err, "update warehouse")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": warehouse})
+	}
+}
+
+// GetProductStock shows a product's stock per warehouse. The total counts the
+// active warehouses only, matching what can be sold; stock at inactive ones
+// is totalled separately.
+func (app *Application) GetProductStock() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		levels, err := app.Inventory.StockLevels(ctx, id)
+		if err != nil {
+			inventoryError(c, err, "fetch stock levels")
+			return
+		}
+		total, inactive := 0, 0
+		for _, level := range levels {
+			if level.Warehouse.Active {
+				total += level.Quantity
+			} else {
+				inactive += level.Quantity
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"levels": levels, "total": total, "inactive_total": inactive})
+	}
+}
+
+// GetStockMovements lists the ledger, newest first, filtered by the optional
+// product_id and warehouse_id query parameters.
+func (app *Application) GetStockMovements() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		productId, _ := strconv.ParseInt(c.Query("product_id"), 10, 64)
+		warehouseId, _ := strconv.ParseInt(c.Query("warehouse_id"), 10, 64)
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit <= 0 || limit > 1000 {
+			limit = 100
+		}
+		movements, err := app.Inventory.Movements(ctx, productId, warehouseId, limit)
+		if err != nil {
+			inventoryError(c, err, "fetch stock movements")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"movements": movements})
+	}
+}
+
+// AdjustStock books a receipt, return or manual adjustment. Quantity is a
+// signed delta; receipts and returns must be positive.
+func (app *Application) AdjustStock() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var request struct {
+			WarehouseID int64  `json:"warehouse_id" binding:"required"`
+			ProductID   int64  `json:"product_id" binding:"required"`
+			Quantity    int    `json:"quantity" binding:"required"`
+			Type        string `json:"type"`
+			Reason      string `json:"reason" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if request.Type == "" {
+			request.Type = models.StockMovementAdjustment
+		}
+		movement, err := app.Inventory.Adjust(ctx, c.GetInt64("uid"), request.WarehouseID, request.ProductID, request.Quantity, request.Type, request.Reason)
+		if err != nil {
+			inventoryError(c, err, "adjust stock")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": movement})
+	}
+}
+
+func (app *Application) TransferStock() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var request struct {
+			FromWarehouseID int64  `json:"from_warehouse_id" binding:"required"`
+			ToWarehouseID   int64  `json:"to_warehouse_id" binding:"required"`
+			ProductID       int64  `json:"product_id" binding:"required"`
+			Quantity        int    `json:"quantity" binding:"required,min=1"`
+			Reason          string `json:"reason" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		movements, err := app.Inventory.Transfer(ctx, c.GetInt64("uid"), request.FromWarehouseID, request.ToWarehouseID, request.ProductID, request.Quantity, request.Reason)
+		if err != nil {
+			inventoryError(c, err, "transfer stock")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": movements})
+	}
+}
+
+// RebuildStockLevels recomputes all stock levels from the ledger.
+func (app *Application) RebuildStockLevels() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		if err := app.Inventory.Rebuild(ctx); err != nil {
+			inventoryError(c, err, "rebuild stock levels")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Stock levels rebuilt from the ledger"})
+	}
+}
+
+// GetReorderReport suggests reorder quantities from recent sales velocity.
+// The days, lead_days and cover_days query parameters set the sales window,
+// supplier lead time and the period reordered stock should cover.
+func (app *Application) GetReorderReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		days := func(name string, fallback int) (time.Duration, bool) {
+			value, err := strconv.Atoi(c.DefaultQuery(name, strconv.Itoa(fallback)))
+			if err != nil || value < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+				return 0, false
+			}
+			return time.Duration(value) * 24 * time.Hour, true
+		}
+		window, ok := days("days", 30)
+		if !ok {
+			return
+		}
+		leadTime, ok := days("lead_days", 7)
+		if !ok {
+			return
+		}
+		cover, ok := days("cover_days", 30)
+		if !ok {
+			return
+		}
+		report, err := app.Inventory.ReorderReport(ctx, database.ReorderOptions{Window: window, LeadTime: leadTime, Cover: cover})
+		if err != nil {
+			inventoryError(c, err, "build reorder report")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"suggestions": report})
+	}
+}
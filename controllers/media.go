@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/media"
+	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/storage"
+)
+
+// maxImagesPerUpload bounds how many images one upload request may carry.
+const maxImagesPerUpload = 10
+
+// mediaError maps product image failures to an HTTP response.
+func mediaError(c *gin.Context, err error, action string) {
+	switch err {
+	case database.ErrCanNotFindProduct, database.ErrCantFindProductImage, storage.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case database.ErrImageOrderNotValid, database.ErrImageVariantIsNotValid:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case media.ErrImageTooLarge, media.ErrImageDimensionLarge:
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case media.ErrUnsupportedImage:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	default:
+		log.Println("Failed to "+action+":", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// productImageResponse adds the variant URLs to a product image.
+func productImageResponse(image *models.ProductImage) gin.H {
+	return gin.H{
+		"image": image,
+		"urls": gin.H{
+			"original":  database.ProductImageURL(image, "original"),
+			"medium":    database.ProductImageURL(image, "medium"),
+			"thumbnail": database.ProductImageURL(image, "thumbnail"),
+		},
+	}
+}
+
+// storeProductImage checks one upload, renders its variants and stores them
+// all, returning the image row to save. Stored keys are cleaned up when a
+// later variant fails.
+func (app *Application) storeProductImage(ctx context.Context, productId int64, data []byte, altText string) (*models.ProductImage, error) {
+	processed, err := media.Process(data)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := media.KeyPrefix(productId)
+	if err != nil {
+		return nil, err
+	}
+	image := models.ProductImage{
+		ProductID:   productId,
+		AltText:     altText,
+		ContentType: processed.ContentType,
+		Width:       processed.Width,
+		Height:      processed.Height,
+	}
+	var stored []string
+	for _, rendition := range processed.Renditions {
+		key := rendition.Key(prefix)
+		if _, err := app.Files.Put(ctx, key, bytes.NewReader(rendition.Data)); err != nil {
+			app.deleteStoredFiles(ctx, stored...)
+			return nil, err
+		}
+		stored = append(stored, key)
+		switch rendition.Variant {
+		case "original":
+			image.OriginalKey = key
+		case "medium":
+			image.MediumKey = key
+		case "thumbnail":
+			image.ThumbnailKey = key
+		}
+	}
+	return &image, nil
+}
+
+func (app *Application) deleteStoredFiles(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := app.Files.Delete(ctx, key); err != nil {
+			log.Println("Failed to delete stored file:", err)
+		}
+	}
+}
+
+// UploadProductImages stores the multipart "images" files as new images of
+// the product :id, after its existing ones. An optional "alt" value
+// describes them.
+func (app *Application) UploadProductImages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImagesPerUpload*media.MaxImageSize+1<<20)
+		form, err := c.MultipartForm()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		headers := form.File["images"]
+		if len(headers) == 0 || len(headers) > maxImagesPerUpload {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Upload between 1 and %d images", maxImagesPerUpload)})
+			return
+		}
+		ctx := c.Request.Context()
+		var images []models.ProductImage
+		cleanup := func() {
+			for _, image := range images {
+				app.deleteStoredFiles(ctx, image.OriginalKey, image.MediumKey, image.ThumbnailKey)
+			}
+		}
+		for _, header := range headers {
+			if header.Size > media.MaxImageSize {
+				cleanup()
+				mediaError(c, media.ErrImageTooLarge, "upload product images")
+				return
+			}
+			file, err := header.Open()
+			if err != nil {
+				cleanup()
+				mediaError(c, err, "upload product images")
+				return
+			}
+			data, err := io.ReadAll(io.LimitReader(file, media.MaxImageSize+1))
+			file.Close()
+			if err != nil {
+				cleanup()
+				mediaError(c, err, "upload product images")
+				return
+			}
+			image, err := app.storeProductImage(ctx, id, data, c.PostForm("alt"))
+			if err != nil {
+				cleanup()
+				mediaError(c, err, "upload product images")
+				return
+			}
+			images = append(images, *image)
+		}
+		dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := app.Images.Add(dbCtx, id, images); err != nil {
+			cleanup()
+			mediaError(c, err, "upload product images")
+			return
+		}
+		response := make([]gin.H, len(images))
+		for i := range images {
+			response[i] = productImageResponse(&images[i])
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": response})
+	}
+}
+
+// GetProductImages lists the images of the product :id in display order.
+func (app *Application) GetProductImages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		images, err := app.Images.List(ctx, id)
+		if err != nil {
+			mediaError(c, err, "get product images")
+			return
+		}
+		response := make([]gin.H, len(images))
+		for i := range images {
+			response[i] = productImageResponse(&images[i])
+		}
+		c.JSON(http.StatusOK, gin.H{"data": response})
+	}
+}
+
+// ServeProductImage streams a variant of a product image. Stored images
+// never change, a new upload gets a new id, so responses may be cached for
+// good.
+func (app *Application) ServeProductImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		imageId, err := paramID(c, "imageId")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+			return
+		}
+		ctx := c.Request.Context()
+		image, err := app.Images.Get(ctx, id, imageId)
+		if err != nil {
+			mediaError(c, err, "get product image")
+			return
+		}
+		key, err := database.ProductImageKey(image, c.Param("variant"))
+		if err != nil {
+			mediaError(c, err, "get product image")
+			return
+		}
+		// Variants never change once stored, so only successful responses
+		// may be cached for good.
+		etag := fmt.Sprintf(`"%d-%s"`, image.ID, c.Param("variant"))
+		cacheable := func() {
+			c.Header("Cache-Control", "public, max-age=31536000, immutable")
+			c.Header("ETag", etag)
+		}
+		if c.GetHeader("If-None-Match") == etag {
+			cacheable()
+			c.Status(http.StatusNotModified)
+			return
+		}
+		content, err := app.Files.Open(ctx, key)
+		if err != nil {
+			c.Header("Cache-Control", "no-store")
+			mediaError(c, err, "get product image")
+			return
+		}
+		defer content.Close()
+		contentType := mime.TypeByExtension(path.Ext(key))
+		if contentType == "" {
+			contentType = image.ContentType
+		}
+		cacheable()
+		c.DataFromReader(http.StatusOK, -1, contentType, content, nil)
+	}
+}
+
+// ReorderProductImages sets the display order of the product's images; the
+// first one becomes the product's main image.
+func (app *Application) ReorderProductImages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		var request struct {
+			ImageIDs []int64 `json:"image_ids" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		images, err := app.Images.Reorder(ctx, id, request.ImageIDs)
+		if err != nil {
+			mediaError(c, err, "reorder product images")
+			return
+		}
+		response := make([]gin.H, len(images))
+		for i := range images {
+			response[i] = productImageResponse(&images[i])
+		}
+		c.JSON(http.StatusOK, gin.H{"data": response})
+	}
+}
+
+func (app *Application) DeleteProductImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		imageId, err := paramID(c, "imageId")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+			return
+		}
+		image, err := app.Images.Delete(ctx, id, imageId)
+		if err != nil {
+			mediaError(c, err, "delete product image")
+			return
+		}
+		app.deleteStoredFiles(ctx, image.OriginalKey, image.MediumKey, image.ThumbnailKey)
+		c.JSON(http.StatusOK, gin.H{"message": "Image deleted"})
+	}
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/database"
+)
+
+// GetOrders lists the logged-in user's orders as they were placed.
+func (app *Application) GetOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		orders, err := app.Orders.List(ctx, userId)
+		if err != nil {
+			log.Println("Failed to fetch orders:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"orders": orders})
+	}
+}
+
+func (app *Application) GetOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+		order, err := app.Orders.Get(ctx, userId, id)
+		if err != nil {
+			if err == database.ErrCantFindOrder {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+				return
+			}
+			log.Println("Failed to fetch order:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": order})
+	}
+}
+
+// UpdateOrderStatus lets admins move an order along, e.g. to "delivered",
+// which is what unlocks reviews for its products.
+func (app *Application) UpdateOrderStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+		var request struct {
+			Status string `json:"status" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		order, err := app.Orders.UpdateStatus(ctx, id, request.Status)
+		switch err {
+		case nil:
+			c.JSON(http.StatusOK, gin.H{"data": order})
+		case database.ErrCantFindOrder:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		case database.ErrOrderStatusNotValid:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case database.ErrOrderNotPaid, database.ErrOrderNotShippable, database.ErrOrderTransitionNotValid:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Println("Failed to update order status:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
+		}
+	}
+}
+
+// CapturePayment confirms payment for an order placed at checkout, turning
+// its stock reservations into deductions.
+func (app *Application) CapturePayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+		order, err := app.Orders.CapturePayment(ctx, app.AllocationStrategy, app.PreOrderPaymentWindow, userId, id)
+		switch err {
+		case nil:
+			c.JSON(http.StatusOK, gin.H{"message": "Payment captured", "data": order})
+		case database.ErrCantFindOrder, database.ErrOrderIdIsNotValid:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		case database.ErrOrderNotAwaitingPayment, database.ErrReservationExpired, database.ErrInsufficientStock, database.ErrPreOrderNotReleased:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Println("Failed to capture payment:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to capture payment"})
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+// pricingError maps price history and sale price failures to an HTTP
+// response.
+func pricingError(c *gin.Context, err error, action string) {
+	switch err {
+	case database.ErrCanNotFindProduct, database.ErrCantFindPriceSchedule:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case database.ErrPriceScheduleNotValid, database.ErrBundlePriceIsComputed:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case database.ErrPriceScheduleOverlaps, database.ErrPriceScheduleFinished:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Println("Failed to "+action+":", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// GetPriceTimeline shows a product's price history, its sale prices and the
+// lowest price of the last 30 days before the current one.
+func (app *Application) GetPriceTimeline() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		timeline, err := app.Prices.Timeline(ctx, id)
+		if err != nil {
+			pricingError(c, err, "fetch price timeline")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": timeline})
+	}
+}
+
+// CreatePriceSchedule plans a sale price for the product :id. While it runs
+// the compare-at price is the lowest price of the 30 days before the sale,
+// or none when the sale isn't below it.
+func (app *Application) CreatePriceSchedule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		var request struct {
+			Price    *float64  `json:"price" binding:"required"`
+			StartsAt time.Time `json:"starts_at" binding:"required"`
+			EndsAt   time.Time `json:"ends_at" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		schedule := models.PriceSchedule{
+			ProductID: id,
+			Price:     *request.Price,
+			StartsAt:  request.StartsAt,
+			EndsAt:    request.EndsAt,
+			CreatedBy: c.GetInt64("uid"),
+		}
+		if err := app.Prices.CreateSchedule(ctx, &schedule); err != nil {
+			pricingError(c, err, "create price schedule")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": schedule})
+	}
+}
+
+// CancelPriceSchedule drops a planned sale price or ends a running one,
+// restoring the regular price.
+func (app *Application) CancelPriceSchedule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid price schedule ID"})
+			return
+		}
+		schedule, err := app.Prices.CancelSchedule(ctx, id, c.GetInt64("uid"))
+		if err != nil {
+			pricingError(c, err, "cancel price schedule")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": schedule})
+	}
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/database"
+)
+
+// SetProductStatus publishes, schedules, unpublishes or archives the
+// product :id.
+func (app *Application) SetProductStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		var request struct {
+			Status      string     `json:"status" binding:"required"`
+			PublishAt   *time.Time `json:"publish_at"`
+			UnpublishAt *time.Time `json:"unpublish_at"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		product, err := app.Products.SetStatus(ctx, id, request.Status, request.PublishAt, request.UnpublishAt)
+		switch err {
+		case nil:
+			c.JSON(http.StatusOK, gin.H{"data": product})
+		case database.ErrCanNotFindProduct:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case database.ErrProductStatusNotValid, database.ErrProductScheduleNotValid:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Println("Failed to update product status:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product status"})
+		}
+	}
+}
+
+// GetDeletedProducts lists the products in the trash with when they will
+// be purged.
+func (app *Application) GetDeletedProducts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		products, err := app.Products.Deleted(ctx)
+		if err != nil {
+			log.Println("Failed to get deleted products:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get deleted products"})
+			return
+		}
+		trash := make([]gin.H, len(products))
+		for i := range products {
+			trash[i] = gin.H{
+				"product":  products[i],
+				"purge_at": products[i].DeletedAt.Time.Add(database.ProductTrashRetention),
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"data": trash})
+	}
+}
+
+func (app *Application) RestoreProduct() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		id, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		product, err := app.Products.Restore(ctx, id)
+		switch err {
+		case nil:
+			c.JSON(http.StatusOK, gin.H{"data": product})
+		case database.ErrCantFindDeletedProduct:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			log.Println("Failed to restore product:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore product"})
+		}
+	}
+}
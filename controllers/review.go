@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+type reviewRequest struct {
+	Rating  int    `json:"rating" binding:"required,min=1,max=5"`
+	Title   string `json:"title" binding:"max=200"`
+	Comment string `json:"comment" binding:"required"`
+}
+
+// reviewError maps review failures to an HTTP response.
+func reviewError(c *gin.Context, err error, action string) {
+	switch err {
+	case database.ErrCantFindReview, database.ErrReviewIdIsNotValid, database.ErrCanNotFindProduct, database.ErrProductIdIsNotValid:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case database.ErrNotVerifiedPurchaser:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case database.ErrReviewAlreadyExists, database.ErrAlreadyVotedReview, database.ErrAlreadyReportedReview:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case database.ErrRatingOutOfRange, database.ErrCantVoteOwnReview, database.ErrCantReportOwnReview,
+		database.ErrModerationActionIsNotValid, database.ErrModerationReasonRequired:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Println("Failed to "+action+":", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// GetProductReviews lists a product's reviews. Supports ?limit= and ?offset=.
+func (app *Application) GetProductReviews() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		productId, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil || limit <= 0 || limit > 100 {
+			limit = 20
+		}
+		offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if err != nil || offset < 0 {
+			offset = 0
+		}
+		reviews, err := app.Reviews.List(ctx, productId, limit, offset)
+		if err != nil {
+			reviewError(c, err, "fetch reviews")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"reviews": reviews})
+	}
+}
+
+func (app *Application) AddReview() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		productId, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		var request reviewRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		decision := app.ReviewRules.Evaluate(request.Title, request.Comment)
+		review := models.Review{
+			Rating:           request.Rating,
+			Title:            request.Title,
+			Comment:          request.Comment,
+			Status:           decision.Status,
+			ModerationReason: decision.Reason,
+		}
+		if err := app.Reviews.Create(ctx, userId, productId, &review); err != nil {
+			reviewError(c, err, "create review")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": review})
+	}
+}
+
+func (app *Application) UpdateReview() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		reviewId, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+			return
+		}
+		var request reviewRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		decision := app.ReviewRules.Evaluate(request.Title, request.Comment)
+		review, err := app.Reviews.Update(ctx, userId, reviewId, models.Review{
+			Rating:           request.Rating,
+			Title:            request.Title,
+			Comment:          request.Comment,
+			Status:           decision.Status,
+			ModerationReason: decision.Reason,
+		})
+		if err != nil {
+			reviewError(c, err, "update review")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Review updated successfully", "data": review})
+	}
+}
+
+func (app *Application) DeleteReview() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		reviewId, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+			return
+		}
+		if err := app.Reviews.Delete(ctx, userId, reviewId); err != nil {
+			reviewError(c, err, "delete review")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Review deleted successfully"})
+	}
+}
+
+// VoteReviewHelpful counts the logged-in user's "helpful" vote on a review.
+func (app *Application) VoteReviewHelpful() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		reviewId, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+			return
+		}
+		review, err := app.Reviews.VoteHelpful(ctx, userId, reviewId)
+		if err != nil {
+			reviewError(c, err, "vote on review")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"helpful_count": review.HelpfulCount})
+	}
+}
+
+// ReportReview lets a customer flag a published review as abusive.
+func (app *Application) ReportReview() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		reviewId, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+			return
+		}
+		var request struct {
+			Reason string `json:"reason" binding:"required,max=500"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := app.Reviews.Report(ctx, userId, reviewId, request.Reason, app.ReviewRules); err != nil {
+			reviewError(c, err, "report review")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Review reported"})
+	}
+}
+
+// GetModerationQueue lists reviews waiting for an admin. ?status= selects
+// another status (approved, rejected, hidden); the default is pending.
+func (app *Application) GetModerationQueue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		status := c.DefaultQuery("status", models.ReviewStatusPending)
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit <= 0 || limit > 200 {
+			limit = 50
+		}
+		offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if err != nil || offset < 0 {
+			offset = 0
+		}
+		reviews, err := app.Reviews.ModerationQueue(ctx, status, limit, offset)
+		if err != nil {
+			reviewError(c, err, "fetch moderation queue")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"reviews": reviews})
+	}
+}
+
+// ModerateReview approves, rejects or hides a review.
+func (app *Application) ModerateReview() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		adminId, _ := currentUserID(c)
+		reviewId, err := paramID(c, "id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+			return
+		}
+		var request struct {
+			Action string `json:"action" binding:"required"`
+			Reason string `json:"reason" binding:"max=500"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		review, err := app.Reviews.Moderate(ctx, adminId, reviewId, request.Action, request.Reason)
+		if err != nil {
+			reviewError(c, err, "moderate review")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": review})
+	}
+}
@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+type wishlistRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type wishlistItemRequest struct {
+	ProductID         int64 `json:"product_id" binding:"required"`
+	NotifyBackInStock bool  `json:"notify_back_in_stock"`
+	NotifyPriceDrop   bool  `json:"notify_price_drop"`
+}
+
+// wishlistError maps wishlist failures to an HTTP response.
+func wishlistError(c *gin.Context, err error, action string) {
+	switch err {
+	case database.ErrCantFindWishlist, database.ErrWishlistIdIsNotValid, database.ErrCantFindWishlistItem,
+		database.ErrCanNotFindProduct, database.ErrProductIdIsNotValid:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case database.ErrProductAlreadyInWishlist:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Println("Failed to "+action+":", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// wishlistParams reads the user and the :id wishlist from the request,
+// answering the request itself when either is missing.
+func wishlistParams(c *gin.Context) (int64, int64, bool) {
+	userId, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, 0, false
+	}
+	wishlistId, err := paramID(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wishlist ID"})
+		return 0, 0, false
+	}
+	return userId, wishlistId, true
+}
+
+func (app *Application) GetWishlists() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		wishlists, err := app.Wishlists.List(ctx, userId)
+		if err != nil {
+			wishlistError(c, err, "fetch wishlists")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"wishlists": wishlists})
+	}
+}
+
+func (app *Application) GetWishlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, wishlistId, ok := wishlistParams(c)
+		if !ok {
+			return
+		}
+		wishlist, err := app.Wishlists.Get(ctx, userId, wishlistId)
+		if err != nil {
+			wishlistError(c, err, "fetch wishlist")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": wishlist})
+	}
+}
+
+func (app *Application) CreateWishlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, ok := currentUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		var request wishlistRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		wishlist, err := app.Wishlists.Create(ctx, userId, request.Name)
+		if err != nil {
+			wishlistError(c, err, "create wishlist")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": wishlist})
+	}
+}
+
+func (app *Application) RenameWishlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, wishlistId, ok := wishlistParams(c)
+		if !ok {
+			return
+		}
+		var request wishlistRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		wishlist, err := app.Wishlists.Rename(ctx, userId, wishlistId, request.Name)
+		if err != nil {
+			wishlistError(c, err, "rename wishlist")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": wishlist})
+	}
+}
+
+func (app *Application) DeleteWishlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, wishlistId, ok := wishlistParams(c)
+		if !ok {
+			return
+		}
+		if err := app.Wishlists.Delete(ctx, userId, wishlistId); err != nil {
+			wishlistError(c, err, "delete wishlist")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Wishlist deleted successfully"})
+	}
+}
+
+// AddWishlistItem adds a product, optionally opting in to back-in-stock and
+// price-drop alerts.
+func (app *Application) AddWishlistItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, wishlistId, ok := wishlistParams(c)
+		if !ok {
+			return
+		}
+		var request wishlistItemRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		item, err := app.Wishlists.AddItem(ctx, userId, wishlistId, models.WishlistItem{
+			ProductID:         request.ProductID,
+			NotifyBackInStock: request.NotifyBackInStock,
+			NotifyPriceDrop:   request.NotifyPriceDrop,
+		})
+		if err != nil {
+			wishlistError(c, err, "add product to wishlist")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": item})
+	}
+}
+
+// UpdateWishlistItem changes the alert opt-ins for a wishlisted product.
+func (app *Application) UpdateWishlistItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, wishlistId, ok := wishlistParams(c)
+		if !ok {
+			return
+		}
+		productId, err := paramID(c, "productId")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		var request struct {
+			NotifyBackInStock bool `json:"notify_back_in_stock"`
+			NotifyPriceDrop   bool `json:"notify_price_drop"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		item, err := app.Wishlists.SetItemAlerts(ctx, userId, wishlistId, productId, request.NotifyBackInStock, request.NotifyPriceDrop)
+		if err != nil {
+			wishlistError(c, err, "update wishlist item")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": item})
+	}
+}
+
+func (app *Application) RemoveWishlistItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, wishlistId, ok := wishlistParams(c)
+		if !ok {
+			return
+		}
+		productId, err := paramID(c, "productId")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		if err := app.Wishlists.RemoveItem(ctx, userId, wishlistId, productId); err != nil {
+			wishlistError(c, err, "remove product from wishlist")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Product removed from wishlist"})
+	}
+}
+
+func (app *Application) MoveWishlistItemToCart() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, wishlistId, ok := wishlistParams(c)
+		if !ok {
+			return
+		}
+		productId, err := paramID(c, "productId")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		if err := app.Wishlists.MoveItemToCart(ctx, userId, wishlistId, productId); err != nil {
+			wishlistError(c, err, "move product to cart")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Product moved to cart"})
+	}
+}
+
+// ShareWishlist creates (or rotates) the wishlist's public share link.
+func (app *Application) ShareWishlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, wishlistId, ok := wishlistParams(c)
+		if !ok {
+			return
+		}
+		wishlist, err := app.Wishlists.Share(ctx, userId, wishlistId)
+		if err != nil {
+			wishlistError(c, err, "share wishlist")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"share_token": *wishlist.ShareToken, "url": "/shared/wishlists/" + *wishlist.ShareToken})
+	}
+}
+
+func (app *Application) UnshareWishlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId, wishlistId, ok := wishlistParams(c)
+		if !ok {
+			return
+		}
+		if err := app.Wishlists.Unshare(ctx, userId, wishlistId); err != nil {
+			wishlistError(c, err, "unshare wishlist")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Wishlist is no longer shared"})
+	}
+}
+
+// GetSharedWishlist is the public view of a shared wishlist. It shows the
+// products only, not who owns the list or their alert settings.
+func (app *Application) GetSharedWishlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		wishlist, err := app.Wishlists.GetShared(ctx, c.Param("token"))
+		if err != nil {
+			wishlistError(c, err, "fetch wishlist")
+			return
+		}
+		products := make([]models.Product, 0, len(wishlist.Items))
+		for _, item := range wishlist.Items {
+			products = append(products, item.Product)
+		}
+		c.JSON(http.StatusOK, gin.H{"name": wishlist.Name, "products": products})
+	}
+}
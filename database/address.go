@@ -0,0 +1,240 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	AddressKindShipping = "shipping"
+	AddressKindBilling  = "billing"
+)
+
+var (
+	ErrAddressIdIsNotValid   = errors.New("address id is not valid")
+	ErrCantFindAddress       = errors.New("can't find address")
+	ErrAddressKindIsNotValid = errors.New("address kind must be shipping or billing")
+)
+
+// defaultColumn maps an address kind to the flag column marking the default.
+func defaultColumn(kind string) (string, error) {
+	switch kind {
+	case AddressKindShipping:
+		return "is_default_shipping", nil
+	case AddressKindBilling:
+		return "is_default_billing", nil
+	}
+	return "", ErrAddressKindIsNotValid
+}
+
+func GetAddresses(ctx context.Context, db *gorm.DB, userId int64) ([]models.Address, error) {
+	if userId <= 0 {
+		return nil, ErrUserIdIsNotValid
+	}
+	var addresses []models.Address
+	if err := db.WithContext(ctx).Where("user_id = ?", userId).Order("id").Find(&addresses).Error; err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// GetAddress loads an address only if it belongs to the given user.
+func GetAddress(ctx context.Context, db *gorm.DB, userId int64, addressId int64) (*models.Address, error) {
+	if userId <= 0 {
+		return nil, ErrUserIdIsNotValid
+	}
+	if addressId <= 0 {
+		return nil, ErrAddressIdIsNotValid
+	}
+	var address models.Address
+	if err := db.WithContext(ctx).First(&address, "id = ? AND user_id = ?", addressId, userId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindAddress
+		}
+		return nil, err
+	}
+	return &address, nil
+}
+
+// GetDefaultAddress returns the user's default shipping or billing address.
+func GetDefaultAddress(ctx context.Context, db *gorm.DB, userId int64, kind string) (*models.Address, error) {
+	column, err := defaultColumn(kind)
+	if err != nil {
+		return nil, err
+	}
+	var address models.Address
+	if err := db.WithContext(ctx).First(&address, "user_id = ? AND "+column+" = ?", userId, true).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindUserAddress
+		}
+		return nil, err
+	}
+	return &address, nil
+}
+
+// AddAddress stores a new address for the user. The user's first address
+// becomes both the default shipping and billing address.
+func AddAddress(ctx context.Context, db *gorm.DB, userId int64, address *models.Address) error {
+	if userId <= 0 {
+		return ErrUserIdIsNotValid
+	}
+	address.ID = 0
+	address.UserID = userId
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Address{}).Where("user_id = ?", userId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			address.IsDefaultShipping = true
+			address.IsDefaultBilling = true
+		}
+		if err := clearDefaults(tx, userId, address); err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Create(address).Error; err != nil {
+			log.Println("Failed to create address:", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// UpdateAddress replaces the editable fields of an existing address.
+func UpdateAddress(ctx context.Context, db *gorm.DB, userId int64, addressId int64, data models.Address) (*models.Address, error) {
+	var updated *models.Address
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		existing, err := GetAddress(ctx, tx, userId, addressId)
+		if err != nil {
+			return err
+		}
+		existing.RecipientName = data.RecipientName
+		existing.Phone = data.Phone
+		existing.Street = data.Street
+		existing.City = data.City
+		existing.State = data.State
+		existing.PostalCode = data.PostalCode
+		existing.Country = data.Country
+		// An address can be promoted to default here, but only demoted by
+		// choosing another default, so the user never ends up without one.
+		existing.IsDefaultShipping = existing.IsDefaultShipping || data.IsDefaultShipping
+		existing.IsDefaultBilling = existing.IsDefaultBilling || data.IsDefaultBilling
+		if err := clearDefaults(tx, userId, existing); err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(existing).Error; err != nil {
+			log.Println("Failed to update address:", err)
+			return err
+		}
+		updated = existing
+		return nil
+	})
+	return updated, err
+}
+
+// SetDefaultAddress marks the address as the user's default of the given kind.
+func SetDefaultAddress(ctx context.Context, db *gorm.DB, userId int64, addressId int64, kind string) (*models.Address, error) {
+	column, err := defaultColumn(kind)
+	if err != nil {
+		return nil, err
+	}
+	var address *models.Address
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		address, err = GetAddress(ctx, tx, userId, addressId)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Address{}).Where("user_id = ? AND id <> ?", userId, addressId).Update(column, false).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(address).Update(column, true).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+	return address, err
+}
+
+// DeleteAddress removes the address. If it was a default, the most recently
+// added remaining address takes over that role.
+func DeleteAddress(ctx context.Context, db *gorm.DB, userId int64, addressId int64) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		address, err := GetAddress(ctx, tx, userId, addressId)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(address).Error; err != nil {
+			log.Println("Failed to delete address:", err)
+			return err
+		}
+		if !address.IsDefaultShipping && !address.IsDefaultBilling {
+			return nil
+		}
+		var replacement models.Address
+		if err := tx.Last(&replacement, "user_id = ?", userId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil
+			}
+			return err
+		}
+		if address.IsDefaultShipping {
+			replacement.IsDefaultShipping = true
+		}
+		if address.IsDefaultBilling {
+			replacement.IsDefaultBilling = true
+		}
+		return tx.Omit(clause.Associations).Save(&replacement).Error
+	})
+}
+
+// ResolveCheckoutAddresses picks the shipping and billing addresses for an
+// order. Explicit ids win, then the user's defaults; billing falls back to
+// the shipping address.
+func ResolveCheckoutAddresses(ctx context.Context, db *gorm.DB, userId int64, shippingId int64, billingId int64) (*models.Address, *models.Address, error) {
+	var shipping, billing *models.Address
+	var err error
+	if shippingId > 0 {
+		shipping, err = GetAddress(ctx, db, userId, shippingId)
+	} else {
+		shipping, err = GetDefaultAddress(ctx, db, userId, AddressKindShipping)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if billingId > 0 {
+		billing, err = GetAddress(ctx, db, userId, billingId)
+	} else {
+		billing, err = GetDefaultAddress(ctx, db, userId, AddressKindBilling)
+		if err == ErrCantFindUserAddress {
+			billing, err = shipping, nil
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return shipping, billing, nil
+}
+
+// clearDefaults unsets the default flags on the user's other addresses for
+// every kind the given address is about to become the default of.
+func clearDefaults(tx *gorm.DB, userId int64, address *models.Address) error {
+	others := func() *gorm.DB {
+		return tx.Model(&models.Address{}).Where("user_id = ? AND id <> ?", userId, address.ID)
+	}
+	if address.IsDefaultShipping {
+		if err := others().Update("is_default_shipping", false).Error; err != nil {
+			return err
+		}
+	}
+	if address.IsDefaultBilling {
+		if err := others().Update("is_default_billing", false).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
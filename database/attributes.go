@@ -0,0 +1,463 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAttributeIdIsNotValid       = errors.New("attribute id is not valid")
+	ErrCantFindAttribute           = errors.New("can't find attribute")
+	ErrCantFindCategory            = errors.New("can't find category")
+	ErrAttributeDefinitionNotValid = errors.New("attribute needs a code of lowercase letters, digits and underscores, a name, and a type of text, number, boolean or enum with options")
+	ErrAttributeCodeTaken          = errors.New("attribute code already used in this category")
+	ErrAttributeValueNotValid      = errors.New("attribute value is not valid")
+	ErrAttributeFilterNotValid     = errors.New("attribute filter is not valid")
+)
+
+var attributeCode = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// validateAttributeDefinition checks a definition before it is saved.
+func validateAttributeDefinition(definition *models.AttributeDefinition) error {
+	definition.Name = strings.TrimSpace(definition.Name)
+	if !attributeCode.MatchString(definition.Code) || definition.Name == "" {
+		return ErrAttributeDefinitionNotValid
+	}
+	switch definition.Type {
+	case models.AttributeTypeText, models.AttributeTypeNumber, models.AttributeTypeBoolean:
+		definition.Options = nil
+	case models.AttributeTypeEnum:
+		if len(definition.Options) == 0 {
+			return ErrAttributeDefinitionNotValid
+		}
+	default:
+		return ErrAttributeDefinitionNotValid
+	}
+	return nil
+}
+
+// GetAttributeDefinitions lists the attributes of a category.
+func GetAttributeDefinitions(ctx context.Context, db *gorm.DB, categoryId int64) ([]models.AttributeDefinition, error) {
+	var definitions []models.AttributeDefinition
+	err := db.WithContext(ctx).Where("category_id = ?", categoryId).Order("id").Find(&definitions).Error
+	return definitions, err
+}
+
+func CreateAttributeDefinition(ctx context.Context, db *gorm.DB, definition *models.AttributeDefinition) error {
+	if err := validateAttributeDefinition(definition); err != nil {
+		return err
+	}
+	var category models.Category
+	if err := db.WithContext(ctx).First(&category, definition.CategoryID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrCantFindCategory
+		}
+		return err
+	}
+	var count int64
+	if err := db.WithContext(ctx).Model(&models.AttributeDefinition{}).
+		Where("category_id = ? AND code = ?", definition.CategoryID, definition.Code).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrAttributeCodeTaken
+	}
+	if err := db.WithContext(ctx).Create(definition).Error; err != nil {
+		log.Println("Failed to create attribute:", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateAttributeDefinition changes an attribute's name, unit, options and
+// flags. Its code and type are fixed once products may carry values.
+func UpdateAttributeDefinition(ctx context.Context, db *gorm.DB, definitionId int64, changes models.AttributeDefinition) (*models.AttributeDefinition, error) {
+	definition, err := getAttributeDefinition(ctx, db, definitionId)
+	if err != nil {
+		return nil, err
+	}
+	definition.Name = changes.Name
+	definition.Unit = changes.Unit
+	definition.Options = changes.Options
+	definition.Required = changes.Required
+	definition.Filterable = changes.Filterable
+	if err := validateAttributeDefinition(definition); err != nil {
+		return nil, err
+	}
+	if err := db.WithContext(ctx).Model(definition).Select("Name", "Unit", "Options", "Required", "Filterable").Updates(definition).Error; err != nil {
+		return nil, err
+	}
+	return definition, nil
+}
+
+// DeleteAttributeDefinition removes an attribute and every product's value
+// for it.
+func DeleteAttributeDefinition(ctx context.Context, db *gorm.DB, definitionId int64) error {
+	definition, err := getAttributeDefinition(ctx, db, definitionId)
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("definition_id = ?", definition.ID).Delete(&models.ProductAttribute{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(definition).Error
+	})
+}
+
+func getAttributeDefinition(ctx context.Context, db *gorm.DB, definitionId int64) (*models.AttributeDefinition, error) {
+	if definitionId <= 0 {
+		return nil, ErrAttributeIdIsNotValid
+	}
+	var definition models.AttributeDefinition
+	if err := db.WithContext(ctx).First(&definition, definitionId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindAttribute
+		}
+		return nil, err
+	}
+	return &definition, nil
+}
+
+// attributeValue converts a JSON value to the attribute's type. Numbers and
+// booleans may also be given as strings, as they come from CSV or forms.
+func attributeValue(definition *models.AttributeDefinition, value interface{}) (models.ProductAttribute, error) {
+	invalid := func(expected string) error {
+		return fmt.Errorf("%w: %s must be %s", ErrAttributeValueNotValid, definition.Code, expected)
+	}
+	attribute := models.ProductAttribute{DefinitionID: definition.ID}
+	switch definition.Type {
+	case models.AttributeTypeNumber:
+		var number float64
+		switch v := value.(type) {
+		case float64:
+			number = v
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return attribute, invalid("a number")
+			}
+			number = parsed
+		default:
+			return attribute, invalid("a number")
+		}
+		attribute.NumberValue = &number
+	case models.AttributeTypeBoolean:
+		var flag bool
+		switch v := value.(type) {
+		case bool:
+			flag = v
+		case string:
+			parsed, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return attribute, invalid("true or false")
+			}
+			flag = parsed
+		default:
+			return attribute, invalid("true or false")
+		}
+		attribute.BoolValue = &flag
+	default:
+		text, ok := value.(string)
+		text = strings.TrimSpace(text)
+		if !ok || text == "" {
+			return attribute, invalid("a text")
+		}
+		if definition.Type == models.AttributeTypeEnum && !containsString(definition.Options, text) {
+			return attribute, invalid("one of " + strings.Join(definition.Options, ", "))
+		}
+		attribute.TextValue = &text
+	}
+	return attribute, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SetProductAttributes stores attribute values of a product, keyed by
+// attribute code. A nil value removes the product's value. Codes must be
+// attributes of the product's category, and its required attributes must
+// end up with a value; values of other categories' attributes are dropped.
+func SetProductAttributes(ctx context.Context, db *gorm.DB, productId int64, values map[string]interface{}) ([]models.ProductAttribute, error) {
+	var attributes []models.ProductAttribute
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.First(&product, productId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCanNotFindProduct
+			}
+			return err
+		}
+		definitions, err := GetAttributeDefinitions(ctx, tx, product.CategoryID)
+		if err != nil {
+			return err
+		}
+		byCode := make(map[string]*models.AttributeDefinition, len(definitions))
+		definitionIds := []int64{0}
+		for i := range definitions {
+			byCode[definitions[i].Code] = &definitions[i]
+			definitionIds = append(definitionIds, definitions[i].ID)
+		}
+		// Values left over from a previous category no longer apply.
+		if err := tx.Where("product_id = ? AND definition_id NOT IN ?", productId, definitionIds).
+			Delete(&models.ProductAttribute{}).Error; err != nil {
+			return err
+		}
+		for code, value := range values {
+			definition, ok := byCode[code]
+			if !ok {
+				return fmt.Errorf("%w: %s is not an attribute of this category", ErrAttributeValueNotValid, code)
+			}
+			if value == nil {
+				if err := tx.Where("product_id = ? AND definition_id = ?", productId, definition.ID).
+					Delete(&models.ProductAttribute{}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			attribute, err := attributeValue(definition, value)
+			if err != nil {
+				return err
+			}
+			attribute.ProductID = productId
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "product_id"}, {Name: "definition_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"text_value", "number_value", "bool_value"}),
+			}).Omit("Definition").Create(&attribute).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Preload("Definition").Where("product_id = ?", productId).Order("definition_id").Find(&attributes).Error; err != nil {
+			return err
+		}
+		return checkRequiredAttributes(definitions, attributes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+// checkRequiredAttributes fails when a required attribute has no value.
+func checkRequiredAttributes(definitions []models.AttributeDefinition, attributes []models.ProductAttribute) error {
+	set := make(map[int64]bool, len(attributes))
+	for _, attribute := range attributes {
+		set[attribute.DefinitionID] = true
+	}
+	var missing []string
+	for _, definition := range definitions {
+		if definition.Required && !set[definition.ID] {
+			missing = append(missing, definition.Code)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s required", ErrAttributeValueNotValid, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// AttributeFilter is a condition on an attribute value, parsed from a
+// filter such as "ram_gb>=16" or "material=cotton,linen".
+type AttributeFilter struct {
+	Code     string
+	Operator string // =, !=, >, >=, <, <=
+	Values   []string
+}
+
+var attributeFilter = regexp.MustCompile(`^([a-z][a-z0-9_]*)\s*(>=|<=|!=|=|>|<)\s*(.+)$`)
+
+// ParseAttributeFilter parses a filter expression. "=" and "!=" accept a
+// comma separated list of values.
+func ParseAttributeFilter(expression string) (AttributeFilter, error) {
+	match := attributeFilter.FindStringSubmatch(strings.TrimSpace(expression))
+	if match == nil {
+		return AttributeFilter{}, fmt.Errorf("%w: %q", ErrAttributeFilterNotValid, expression)
+	}
+	filter := AttributeFilter{Code: match[1], Operator: match[2]}
+	for _, value := range strings.Split(match[3], ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			filter.Values = append(filter.Values, value)
+		}
+	}
+	if len(filter.Values) == 0 || len(filter.Values) > 1 && filter.Operator != "=" && filter.Operator != "!=" {
+		return AttributeFilter{}, fmt.Errorf("%w: %q", ErrAttributeFilterNotValid, expression)
+	}
+	return filter, nil
+}
+
+// FilterByAttributes narrows a products query to those matching every
+// filter. The definitions of the filtered codes decide which value column is
+// compared; a code defined by several categories matches any of them.
+func FilterByAttributes(ctx context.Context, db *gorm.DB, query *gorm.DB, filters []AttributeFilter) (*gorm.DB, error) {
+	if len(filters) == 0 {
+		return query, nil
+	}
+	codes := make([]string, len(filters))
+	for i, filter := range filters {
+		codes[i] = filter.Code
+	}
+	var definitions []models.AttributeDefinition
+	if err := db.WithContext(ctx).Where("code IN ? AND filterable", codes).Find(&definitions).Error; err != nil {
+		return nil, err
+	}
+	for _, filter := range filters {
+		var conditions []string
+		var args []interface{}
+		for _, definition := range definitions {
+			if definition.Code != filter.Code {
+				continue
+			}
+			condition, conditionArgs, err := attributeCondition(&definition, filter)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, "(product_attributes.definition_id = ? AND "+condition+")")
+			args = append(append(args, definition.ID), conditionArgs...)
+		}
+		if len(conditions) == 0 {
+			return nil, fmt.Errorf("%w: there is no filterable attribute %s", ErrAttributeFilterNotValid, filter.Code)
+		}
+		query = query.Where("EXISTS (SELECT 1 FROM product_attributes WHERE product_attributes.product_id = products.id AND ("+
+			strings.Join(conditions, " OR ")+"))", args...)
+	}
+	return query, nil
+}
+
+// attributeCondition is the SQL comparing the definition's value column.
+func attributeCondition(definition *models.AttributeDefinition, filter AttributeFilter) (string, []interface{}, error) {
+	invalid := fmt.Errorf("%w: %s can't be compared with %s%s", ErrAttributeFilterNotValid, filter.Code, filter.Operator, strings.Join(filter.Values, ","))
+	switch definition.Type {
+	case models.AttributeTypeNumber:
+		numbers := make([]float64, len(filter.Values))
+		for i, value := range filter.Values {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return "", nil, invalid
+			}
+			numbers[i] = number
+		}
+		switch filter.Operator {
+		case "=":
+			return "product_attributes.number_value IN ?", []interface{}{numbers}, nil
+		case "!=":
+			return "product_attributes.number_value NOT IN ?", []interface{}{numbers}, nil
+		}
+		return "product_attributes.number_value " + filter.Operator + " ?", []interface{}{numbers[0]}, nil
+	case models.AttributeTypeBoolean:
+		flag, err := strconv.ParseBool(filter.Values[0])
+		if err != nil || len(filter.Values) > 1 || filter.Operator != "=" && filter.Operator != "!=" {
+			return "", nil, invalid
+		}
+		return "product_attributes.bool_value " + filter.Operator + " ?", []interface{}{flag}, nil
+	}
+	switch filter.Operator {
+	case "=":
+		return "product_attributes.text_value IN ?", []interface{}{filter.Values}, nil
+	case "!=":
+		return "product_attributes.text_value NOT IN ?", []interface{}{filter.Values}, nil
+	}
+	return "", nil, invalid
+}
+
+// AttributeFacet summarizes the values of an attribute among a set of
+// products: counts per value, or the range of a number.
+type AttributeFacet struct {
+	Code   string       `json:"code"`
+	Name   string       `json:"name"`
+	Type   string       `json:"type"`
+	Unit   string       `json:"unit,omitempty"`
+	Values []FacetValue `json:"values,omitempty"`
+	Min    *float64     `json:"min,omitempty"`
+	Max    *float64     `json:"max,omitempty"`
+}
+
+// FacetValue is how many of the products have an attribute value.
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// AttributeFacets computes the facets of the filterable attributes over the
+// products the query selects.
+func AttributeFacets(ctx context.Context, db *gorm.DB, products *gorm.DB) ([]AttributeFacet, error) {
+	var rows []struct {
+		DefinitionID int64
+		TextValue    *string
+		BoolValue    *bool
+		MinNumber    *float64
+		MaxNumber    *float64
+		Count        int
+	}
+	err := db.WithContext(ctx).Table("product_attributes").
+		Select("product_attributes.definition_id, product_attributes.text_value, product_attributes.bool_value, "+
+			"MIN(product_attributes.number_value) AS min_number, MAX(product_attributes.number_value) AS max_number, "+
+			"COUNT(DISTINCT product_attributes.product_id) AS count").
+		Where("product_attributes.product_id IN (?)", products.Session(&gorm.Session{}).Select("products.id")).
+		Group("product_attributes.definition_id, product_attributes.text_value, product_attributes.bool_value").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	var definitionIds []int64
+	for _, row := range rows {
+		definitionIds = append(definitionIds, row.DefinitionID)
+	}
+	var definitions []models.AttributeDefinition
+	if len(definitionIds) > 0 {
+		if err := db.WithContext(ctx).Where("id IN ? AND filterable", definitionIds).Order("id").Find(&definitions).Error; err != nil {
+			return nil, err
+		}
+	}
+	facets := make([]AttributeFacet, len(definitions))
+	position := make(map[int64]int, len(definitions))
+	for i, definition := range definitions {
+		facets[i] = AttributeFacet{Code: definition.Code, Name: definition.Name, Type: definition.Type, Unit: definition.Unit}
+		position[definition.ID] = i
+	}
+	for _, row := range rows {
+		i, ok := position[row.DefinitionID]
+		if !ok {
+			continue
+		}
+		facet := &facets[i]
+		switch {
+		case row.TextValue != nil:
+			facet.Values = append(facet.Values, FacetValue{Value: *row.TextValue, Count: row.Count})
+		case row.BoolValue != nil:
+			facet.Values = append(facet.Values, FacetValue{Value: strconv.FormatBool(*row.BoolValue), Count: row.Count})
+		case row.MinNumber != nil:
+			if facet.Min == nil || *row.MinNumber < *facet.Min {
+				facet.Min = row.MinNumber
+			}
+			if facet.Max == nil || *row.MaxNumber > *facet.Max {
+				facet.Max = row.MaxNumber
+			}
+		}
+	}
+	for i := range facets {
+		sort.Slice(facets[i].Values, func(a, b int) bool {
+			values := facets[i].Values
+			if values[a].Count != values[b].Count {
+				return values[a].Count > values[b].Count
+			}
+			return values[a].Value < values[b].Value
+		})
+	}
+	return facets, nil
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPreOrderMixedCart   = errors.New("pre-order items must be checked out separately from other items")
+	ErrPreOrderNotReleased = errors.New("pre-order can't be paid before its release date")
+)
+
+// isPreOrder reports whether the product currently takes pre-orders.
+func isPreOrder(product *models.Product, now time.Time) bool {
+	return product.StockPolicy == models.StockPolicyPreOrder && product.ReleaseDate != nil && now.Before(*product.ReleaseDate)
+}
+
+// sellableQuantity is how many units can be sold given the available stock
+// and the units already waiting on backorders, according to the product's
+// stock policy.
+func sellableQuantity(product *models.Product, available int, outstanding int, now time.Time) int {
+	switch {
+	case isPreOrder(product, now):
+		if product.BackorderLimit == 0 {
+			return math.MaxInt32
+		}
+		return product.BackorderLimit - outstanding
+	case product.StockPolicy == models.StockPolicyBackorder:
+		return max(available, 0) + max(product.BackorderLimit-outstanding, 0)
+	}
+	return available
+}
+
+// OutstandingBackorders sums the units of each product that open orders are
+// still waiting for.
+func OutstandingBackorders(ctx context.Context, db *gorm.DB, productIds []int64) (map[int64]int, error) {
+	outstanding := make(map[int64]int, len(productIds))
+	if len(productIds) == 0 {
+		return outstanding, nil
+	}
+	var rows []struct {
+		ProductID int64
+		Units     int
+	}
+	err := db.WithContext(ctx).Model(&models.OrderItem{}).
+		Select("order_items.product_id, SUM(order_items.backordered) AS units").
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Where("order_items.product_id IN ? AND order_items.backordered > 0 AND orders.order_status IN ?",
+			productIds, []string{models.OrderStatusPendingPayment, models.OrderStatusOrdered}).
+		Group("order_items.product_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		outstanding[row.ProductID] = row.Units
+	}
+	return outstanding, nil
+}
+
+// SellableQuantity is AvailableQuantity widened by the product's backorder
+// or pre-order policy. Carts are checked against it.
+func SellableQuantity(ctx context.Context, db *gorm.DB, product *models.Product) (int, error) {
+	available, err := AvailableQuantity(ctx, db, product)
+	if err != nil {
+		return 0, err
+	}
+	if product.StockPolicy == models.StockPolicyDeny || product.StockPolicy == "" {
+		return available, nil
+	}
+	outstanding, err := OutstandingBackorders(ctx, db, []int64{product.ID})
+	if err != nil {
+		return 0, err
+	}
+	return sellableQuantity(product, available, outstanding[product.ID], time.Now()), nil
+}
+
+// fillBackorders ships free stock of the product to paid orders waiting for
+// it, oldest purchase first. With a warehouse id only that warehouse's stock
+// is used, otherwise warehouses are drawn from by priority. It must run
+// inside a transaction.
+func fillBackorders(tx *gorm.DB, productId int64, warehouseId int64) error {
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productId).Error; err != nil {
+		return err
+	}
+	reserved, err := ReservedQuantities(context.Background(), tx, []int64{productId})
+	if err != nil {
+		return err
+	}
+	free := product.Quantity - reserved[productId]
+	if free <= 0 {
+		return nil
+	}
+	var items []models.OrderItem
+	if err := tx.Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Where("order_items.product_id = ? AND order_items.backordered > 0 AND orders.order_status = ?", productId, models.OrderStatusOrdered).
+		Order("orders.created_at, orders.id, order_items.id").
+		Find(&items).Error; err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	levels := tx.Joins("JOIN warehouses ON warehouses.id = stock_levels.warehouse_id AND warehouses.deleted_at IS NULL").
+		Where("stock_levels.product_id = ? AND stock_levels.quantity > 0", productId)
+	if warehouseId > 0 {
+		levels = levels.Where("stock_levels.warehouse_id = ?", warehouseId)
+	} else {
+		levels = levels.Where("warehouses.active").Order("warehouses.priority, warehouses.id")
+	}
+	var stock []models.StockLevel
+	if err := levels.Find(&stock).Error; err != nil {
+		return err
+	}
+
+	filled := map[int64]bool{}
+	for _, item := range items {
+		waiting := item.Backordered
+		for i := range stock {
+			if free == 0 || item.Backordered == 0 {
+				break
+			}
+			quantity := min(item.Backordered, stock[i].Quantity, free)
+			if quantity <= 0 {
+				continue
+			}
+			orderId := item.OrderID
+			if err := recordMovement(tx, &models.StockMovement{
+				WarehouseID: stock[i].WarehouseID,
+				ProductID:   productId,
+				Quantity:    -quantity,
+				Type:        models.StockMovementSale,
+				Reason:      "backorder",
+				OrderID:     &orderId,
+			}); err != nil {
+				return err
+			}
+			stock[i].Quantity -= quantity
+			item.Backordered -= quantity
+			free -= quantity
+		}
+		if item.Backordered == waiting {
+			continue
+		}
+		if err := tx.Model(&item).Update("backordered", item.Backordered).Error; err != nil {
+			return err
+		}
+		filled[item.OrderID] = true
+		if free == 0 {
+			break
+		}
+	}
+	for orderId := range filled {
+		if err := tx.Model(&models.Order{}).
+			Where("id = ? AND NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id AND order_items.backordered > 0 AND order_items.deleted_at IS NULL)", orderId).
+			Update("backordered", false).Error; err != nil {
+			return err
+		}
+		log.Printf("Allocated incoming stock of product %d to backordered order %d", productId, orderId)
+	}
+	return nil
+}
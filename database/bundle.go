@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBundleComponentNotValid = errors.New("bundle components must be other, non-bundle products with a positive quantity")
+	ErrBundlePricingNotValid   = errors.New("bundle pricing must be fixed, or discount with a percentage between 0 and 100")
+	ErrBundleHasNoStock        = errors.New("bundles have no stock of their own, adjust their components instead")
+)
+
+// bundlePrice is what a bundle sells for: its own price when fixed, or the
+// sum of its components minus the discount percentage.
+func bundlePrice(bundle *models.Product, components []models.BundleComponent) float64 {
+	if bundle.BundlePricing != models.BundlePricingDiscount {
+		return bundle.Price
+	}
+	var total float64
+	for _, component := range components {
+		total += component.Component.Price * float64(component.Quantity)
+	}
+	return roundMoney(total * (100 - bundle.BundleDiscount) / 100)
+}
+
+// bundleComponents loads the components of the given bundles, keyed by
+// bundle id.
+func bundleComponents(db *gorm.DB, bundleIds []int64) (map[int64][]models.BundleComponent, error) {
+	byBundle := map[int64][]models.BundleComponent{}
+	if len(bundleIds) == 0 {
+		return byBundle, nil
+	}
+	var components []models.BundleComponent
+	if err := db.Preload("Component").Where("bundle_id IN ?", bundleIds).Order("id").Find(&components).Error; err != nil {
+		return nil, err
+	}
+	for _, component := range components {
+		byBundle[component.BundleID] = append(byBundle[component.BundleID], component)
+	}
+	return byBundle, nil
+}
+
+// bundleAvailability is how many of each bundle can be assembled from the
+// available stock of its components.
+func bundleAvailability(ctx context.Context, db *gorm.DB, bundleIds []int64) (map[int64]int, error) {
+	byBundle, err := bundleComponents(db.WithContext(ctx), bundleIds)
+	if err != nil {
+		return nil, err
+	}
+	var componentIds []int64
+	for _, components := range byBundle {
+		for _, component := range components {
+			componentIds = append(componentIds, component.ComponentID)
+		}
+	}
+	reserved, err := ReservedQuantities(ctx, db, componentIds)
+	if err != nil {
+		return nil, err
+	}
+	available := make(map[int64]int, len(bundleIds))
+	for _, bundleId := range bundleIds {
+		components := byBundle[bundleId]
+		if len(components) == 0 {
+			available[bundleId] = 0
+			continue
+		}
+		count := math.MaxInt32
+		for _, component := range components {
+			if component.Component.IsDigital {
+				continue
+			}
+			free := component.Component.Quantity - reserved[component.ComponentID]
+			count = min(count, max(free, 0)/component.Quantity)
+		}
+		available[bundleId] = count
+	}
+	return available, nil
+}
+
+// SetBundleComponents turns the product into a bundle of the given
+// components, or back into a plain product when there are none, and prices
+// it according to pricing.
+func SetBundleComponents(ctx context.Context, db *gorm.DB, bundleId int64, pricing string, discount float64, components []models.BundleComponent) (*models.Product, error) {
+	if len(components) > 0 && pricing != models.BundlePricingFixed && pricing != models.BundlePricingDiscount ||
+		discount < 0 || discount > 100 {
+		return nil, ErrBundlePricingNotValid
+	}
+	var bundle models.Product
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&bundle, bundleId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCanNotFindProduct
+			}
+			return err
+		}
+		if len(components) > 0 && bundle.Quantity != 0 {
+			return ErrBundleHasNoStock
+		}
+		seen := map[int64]bool{}
+		for i := range components {
+			component := &components[i]
+			if component.ComponentID == bundleId || component.Quantity <= 0 || seen[component.ComponentID] {
+				return ErrBundleComponentNotValid
+			}
+			seen[component.ComponentID] = true
+			if err := tx.First(&component.Component, component.ComponentID).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return ErrCanNotFindProduct
+				}
+				return err
+			}
+			if component.Component.IsBundle {
+				return ErrBundleComponentNotValid
+			}
+			component.ID = 0
+			component.BundleID = bundleId
+		}
+		if err := tx.Where("bundle_id = ?", bundleId).Delete(&models.BundleComponent{}).Error; err != nil {
+			return err
+		}
+		for i := range components {
+			if err := tx.Omit("Component").Create(&components[i]).Error; err != nil {
+				return err
+			}
+		}
+		bundle.IsBundle = len(components) > 0
+		bundle.BundlePricing = pricing
+		bundle.BundleDiscount = discount
+		if !bundle.IsBundle {
+			bundle.BundlePricing, bundle.BundleDiscount = "", 0
+		}
+		oldPrice := bundle.Price
+		bundle.Price = bundlePrice(&bundle, components)
+		bundle.Components = components
+		if err := tx.Model(&bundle).Select("IsBundle", "BundlePricing", "BundleDiscount", "Price", "StockPolicy").
+			Updates(models.Product{IsBundle: bundle.IsBundle, BundlePricing: bundle.BundlePricing, BundleDiscount: bundle.BundleDiscount, Price: bundle.Price, StockPolicy: models.StockPolicyDeny}).Error; err != nil {
+			return err
+		}
+		if bundle.Price == oldPrice {
+			return nil
+		}
+		return recordPriceChange(ctx, tx, bundle.ID, oldPrice, bundle.Price, models.PriceChangeBundle, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
+
+// RefreshBundlePrices re-prices the discount bundles containing the product
+// after its price changed.
+func RefreshBundlePrices(ctx context.Context, db *gorm.DB, componentId int64) error {
+	var bundleIds []int64
+	if err := db.WithContext(ctx).Model(&models.BundleComponent{}).Where("component_id = ?", componentId).
+		Pluck("bundle_id", &bundleIds).Error; err != nil {
+		return err
+	}
+	if len(bundleIds) == 0 {
+		return nil
+	}
+	byBundle, err := bundleComponents(db.WithContext(ctx), bundleIds)
+	if err != nil {
+		return err
+	}
+	var bundles []models.Product
+	if err := db.WithContext(ctx).Where("id IN ? AND bundle_pricing = ?", bundleIds, models.BundlePricingDiscount).Find(&bundles).Error; err != nil {
+		return err
+	}
+	for _, bundle := range bundles {
+		if err := setProductPrice(ctx, db, &bundle, bundlePrice(&bundle, byBundle[bundle.ID]), models.PriceChangeBundle, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
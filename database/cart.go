@@ -25,23 +25,51 @@ var (
 	ErrProductIdIsNotValid       = errors.New("product id is not valid")
 	ErrCantFindProductInCart     = errors.New("can't find product in cart")
 	ErrCantFindUserAddress       = errors.New("can't find user address")
+	ErrInsufficientStock         = errors.New("not enough stock for requested quantity")
+	ErrExceedsMaxPerOrder        = errors.New("quantity exceeds the per-order limit for this product")
 )
 
-func AddProductToCart(ctx context.Context, db *gorm.DB, productId int64, userId int64) error {
+// CheckCartQuantity verifies that qty units of the product may sit in a
+// single cart line when sellable units can still be sold (see
+// SellableQuantity).
+func CheckCartQuantity(product *models.Product, sellable int, qty int) error {
+	if qty <= 0 {
+		return ErrQuantityMustBePositive
+	}
+	if product.MaxPerOrder > 0 && qty > product.MaxPerOrder {
+		return ErrExceedsMaxPerOrder
+	}
+	if qty > sellable {
+		return ErrInsufficientStock
+	}
+	return nil
+}
+
+// AddProductToCart adds qty units of the product to the user's cart,
+// respecting sellable stock and the product's per-order limit.
+func AddProductToCart(ctx context.Context, db *gorm.DB, productId int64, userId int64, qty int) error {
 	// Validate userId
 	if userId <= 0 {
 		return ErrUserIdIsNotValid
 	}
+	if qty <= 0 {
+		return ErrQuantityMustBePositive
+	}
 
 	// Fetch the product from the database to ensure it exists
 	var product models.Product
-	if err := db.WithContext(ctx).First(&product, "id = ?", productId).Error; err != nil {
+	if err := db.WithContext(ctx).Scopes(Published).First(&product, "id = ?", productId).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return ErrCanNotFindProduct
 		}
 		return err
 	}
 
+	sellable, err := SellableQuantity(ctx, db, &product)
+	if err != nil {
+		return err
+	}
+
 	// Fetch the user by userId
 	var user models.User
 	if err := db.WithContext(ctx).First(&user, "id = ?", userId).Error; err != nil {
@@ -55,19 +83,26 @@ func AddProductToCart(ctx context.Context, db *gorm.DB, productId int64, userId
 	var existingCart models.UserProduct
 	if err := db.WithContext(ctx).First(&existingCart, "user_id = ? AND product_id = ?", userId, productId).Error; err == nil {
 		// If the product exists, update the quantity
-		existingCart.Quantity += 1
+		if err := CheckCartQuantity(&product, sellable, existingCart.Quantity+qty); err != nil {
+			return err
+		}
+		existingCart.Quantity += qty
+		existingCart.Price = product.Price
 		if err := db.WithContext(ctx).Save(&existingCart).Error; err != nil {
 			log.Println("Failed to update product quantity in cart:", err)
 			return err
 		}
 	} else if err == gorm.ErrRecordNotFound {
+		if err := CheckCartQuantity(&product, sellable, qty); err != nil {
+			return err
+		}
 		// If the product does not exist in the cart, add it as a new entry
 		newCart := models.UserProduct{
 			UserID:      userId,
 			ProductID:   productId,
 			ProductName: product.Name,
 			Price:       product.Price,
-			Quantity:    1,
+			Quantity:    qty,
 			Rating:      product.Rating,
 			Image:       product.Image,
 		}
@@ -118,74 +153,55 @@ func GetCartItems(ctx context.Context, db *gorm.DB, userId int64) ([]models.User
 	return userProducts, nil
 }
 
-func CheckoutCart(ctx context.Context, db *gorm.DB, userId int64) error {
+// CheckoutCart turns the user's cart into a single order shipped to the
+// chosen address, or to the user's default one when the ids are zero;
+// digital products need no shipping address (see checkoutAddresses). Lines
+// are priced from the current product and their stock is reserved until the
+// payment is captured; the whole checkout fails if any line no longer has
+// enough stock.
+func CheckoutCart(ctx context.Context, db *gorm.DB, userId int64, shippingAddressId int64, billingAddressId int64) (*models.Order, error) {
 	// Validate userId
 	if userId <= 0 {
-		return ErrUserIdIsNotValid
+		return nil, ErrUserIdIsNotValid
 	}
 	var userProducts []models.UserProduct
 	if err := db.WithContext(ctx).Find(&userProducts, "user_id = ?", userId).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return ErrCantCheckoutCart
-		}
-		return err
-
+		return nil, err
 	}
-	// calculate the total amount
-	var totalAmount float64
-	for _, product := range userProducts {
-		totalAmount += product.Price * float64(product.Quantity)
+	if len(userProducts) == 0 {
+		return nil, ErrCantCheckoutCart
 	}
-	// get latest user address id from Address table
-	var userAddress models.Address
-	if err := db.WithContext(ctx).Last(&userAddress, "user_id =?", userId).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return ErrCantCheckoutCart
-		}
-		return err
+	lines := make([]cartEntry, len(userProducts))
+	productIds := make([]int64, len(userProducts))
+	for i, cartItem := range userProducts {
+		lines[i] = cartEntry{ProductID: cartItem.ProductID, Quantity: cartItem.Quantity, Price: cartItem.Price}
+		productIds[i] = cartItem.ProductID
 	}
-	// make order now
-	var order models.Order
-	for _, product := range userProducts {
-		order = models.Order{
-			UserID:        userId,
-			AddressID:     userAddress.ID,
-			TotalPrice:    totalAmount,
-			OrderStatus:   "ordered",
-			PaymentMethod: "cod",
-		}
-		if err := db.WithContext(ctx).Create(&order).Error; err != nil {
-			log.Println("Failed to make order:", err)
-			return err
-		}
-		orderItem := models.OrderItem{
-			OrderID:   order.ID,
-			ProductID: product.ProductID,
-			Quantity:  product.Quantity,
-			Price:     product.Price,
-		}
-		if err := db.WithContext(ctx).Create(&orderItem).Error; err != nil {
-			log.Println("Failed to make order item:", err)
-			return err
-		}
-	}
-	payment := models.Payment{
-		OrderID:     order.ID,
-		Amount:      totalAmount,
-		PaymentType: "cod",
+	shipping, billing, err := checkoutAddresses(ctx, db, userId, productIds, shippingAddressId, billingAddressId)
+	if err != nil {
+		return nil, err
 	}
-	if err := db.WithContext(ctx).Create(&payment).Error; err != nil {
-		log.Println("Failed to add payment record:", err)
-		return err
+	order := models.Order{
+		UserID:        userId,
+		OrderStatus:   models.OrderStatusPendingPayment,
+		PaymentMethod: "cod",
 	}
-	// remove cartitems from userProducts list
-	for _, product := range userProducts {
-		db.WithContext(ctx).Delete(&models.UserProduct{}, "user_id =? AND product_id =?", userId, product.ProductID)
+	setOrderAddresses(&order, shipping, billing)
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := reserveStock(ctx, tx, &order, lines); err != nil {
+			return err
+		}
+		// remove cartitems from userProducts list
+		return tx.Delete(&models.UserProduct{}, "user_id = ?", userId).Error
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	return &order, nil
 }
 
+// UpdateProductQuantity sets the quantity of a cart line, checked against
+// the product's current stock and per-order limit.
 func UpdateProductQuantity(ctx context.Context, db *gorm.DB, userId int64, productId int64, qty int) error {
 	if userId <= 0 {
 		return ErrUserIdIsNotValid
@@ -203,7 +219,22 @@ func UpdateProductQuantity(ctx context.Context, db *gorm.DB, userId int64, produ
 		}
 		return err
 	}
+	var product models.Product
+	if err := db.WithContext(ctx).Scopes(Published).First(&product, productId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrCanNotFindProduct
+		}
+		return err
+	}
+	sellable, err := SellableQuantity(ctx, db, &product)
+	if err != nil {
+		return err
+	}
+	if err := CheckCartQuantity(&product, sellable, qty); err != nil {
+		return err
+	}
 	userProduct.Quantity = qty
+	userProduct.Price = product.Price
 	if err := db.WithContext(ctx).Save(&userProduct).Error; err != nil {
 		log.Println("Failed to update product quantity:", err)
 		return err
@@ -228,69 +259,47 @@ func DeleteProductFromCart(ctx context.Context, db *gorm.DB, userId int64) error
 	}
 	return nil
 }
-func GetInstantBuyProduct(ctx context.Context, db *gorm.DB, productId int64, uerId int64) error {
-	if uerId <= 0 {
-		return ErrUserIdIsNotValid
+
+// GetInstantBuyProduct orders a single cart line right away, shipped to the
+// chosen address or the user's default one. Like CheckoutCart it reserves
+// the stock until the payment is captured.
+func GetInstantBuyProduct(ctx context.Context, db *gorm.DB, productId int64, userId int64, shippingAddressId int64, billingAddressId int64) (*models.Order, error) {
+	if userId <= 0 {
+		return nil, ErrUserIdIsNotValid
 	}
 	if productId <= 0 {
-		return ErrProductIdIsNotValid
-	}
-	var product models.Product
-	if err := db.WithContext(ctx).First(&product, "id = ?", productId).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return ErrCanNotFindProduct
-		}
-		return err
+		return nil, ErrProductIdIsNotValid
 	}
 	var userProduct models.UserProduct
-	if err := db.WithContext(ctx).First(&userProduct, "user_id = ? AND product_id = ?", uerId, productId).Error; err != nil {
+	if err := db.WithContext(ctx).First(&userProduct, "user_id = ? AND product_id = ?", userId, productId).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return ErrCantFindProductInCart
+			return nil, ErrCantFindProductInCart
 		}
-		return err
+		return nil, err
 	}
-	var userAddress models.Address
-	if err := db.WithContext(ctx).Last(&userAddress, "user_id =?", uerId).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return ErrCantFindUserAddress
-		}
-		return err
+	shipping, billing, err := checkoutAddresses(ctx, db, userId, []int64{productId}, shippingAddressId, billingAddressId)
+	if err != nil {
+		return nil, err
 	}
 	order := models.Order{
-		UserID:        uerId,
-		AddressID:     userAddress.ID,
-		TotalPrice:    product.Price * float64(userProduct.Quantity),
-		OrderStatus:   "ordered",
+		UserID:        userId,
+		OrderStatus:   models.OrderStatusPendingPayment,
 		PaymentMethod: "cod",
 	}
-	if err := db.WithContext(ctx).Create(&order).Error; err != nil {
-		log.Println("Failed to make order", err)
-		return err
-	}
-	orderItem := models.OrderItem{
-		OrderID:   order.ID,
-		ProductID: product.ID,
-		Quantity:  userProduct.Quantity,
-		Price:     product.Price,
-	}
-	if err := db.WithContext(ctx).Create(&orderItem).Error; err != nil {
-		log.Println("Failed to make order item", err)
-		return err
-
-	}
-	payment := models.Payment{
-		OrderID:     order.ID,
-		Amount:      product.Price * float64(userProduct.Quantity),
-		PaymentType: "cod",
-	}
-	if err := db.WithContext(ctx).Create(&payment).Error; err != nil {
-		log.Println("Failed to add payment record", err)
-		return err
-
-	}
-	if err := db.WithContext(ctx).Delete(&userProduct).Error; err != nil {
-		log.Println("Failed to remove product from cart", err)
-		return err
+	setOrderAddresses(&order, shipping, billing)
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		lines := []cartEntry{{ProductID: productId, Quantity: userProduct.Quantity, Price: userProduct.Price}}
+		if err := reserveStock(ctx, tx, &order, lines); err != nil {
+			return err
+		}
+		if err := tx.Delete(&userProduct).Error; err != nil {
+			log.Println("Failed to remove product from cart", err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return &order, nil
 }
@@ -0,0 +1,249 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+)
+
+const (
+	CartWarningPriceChanged       = "price_changed"
+	CartWarningUnavailable        = "unavailable"
+	CartWarningInsufficientStock  = "insufficient_stock"
+	CartWarningExceedsMaxPerOrder = "exceeds_max_per_order"
+	CartWarningBackordered        = "backordered"
+	CartWarningPreOrder           = "pre_order"
+)
+
+// CartWarning tells the customer that a cart line changed since it was added.
+type CartWarning struct {
+	ProductID int64   `json:"product_id"`
+	Code      string  `json:"code"`
+	Message   string  `json:"message"`
+	OldPrice  float64 `json:"old_price,omitempty"`
+	NewPrice  float64 `json:"new_price,omitempty"`
+	Available int     `json:"available,omitempty"`
+}
+
+// CartLine is a cart entry priced from the current product.
+type CartLine struct {
+	ProductID   int64   `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Image       string  `json:"image"`
+	UnitPrice   float64 `json:"unit_price"`
+	Quantity    int     `json:"quantity"`
+	LineTotal   float64 `json:"line_total"`
+	// Purchasable is false when the line can't be checked out as it is;
+	// such lines are left out of the totals.
+	Purchasable bool `json:"purchasable"`
+	// Backordered units ship once the product is restocked.
+	Backordered int  `json:"backordered,omitempty"`
+	PreOrder    bool `json:"pre_order,omitempty"`
+}
+
+// CartTotals is the price breakdown of the purchasable lines.
+type CartTotals struct {
+	ItemCount     int     `json:"item_count"`
+	Subtotal      float64 `json:"subtotal"`
+	ExcludedItems int     `json:"excluded_items"`
+	Total         float64 `json:"total"`
+}
+
+// CartView is a cart re-validated against current prices and stock.
+type CartView struct {
+	Items    []CartLine    `json:"items"`
+	Warnings []CartWarning `json:"warnings"`
+	Totals   CartTotals    `json:"totals"`
+}
+
+// cartEntry is what user and guest carts have in common.
+type cartEntry struct {
+	ProductID int64
+	Quantity  int
+	Price     float64
+}
+
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// buildCartView prices every entry from the current product and collects
+// warnings for lines whose price, stock or availability changed.
+func buildCartView(ctx context.Context, db *gorm.DB, entries []cartEntry) (*CartView, error) {
+	productIds := make([]int64, len(entries))
+	for i, entry := range entries {
+		productIds[i] = entry.ProductID
+	}
+	var products []models.Product
+	if len(productIds) > 0 {
+		if err := db.WithContext(ctx).Where("id IN ?", productIds).Find(&products).Error; err != nil {
+			return nil, err
+		}
+	}
+	byId := make(map[int64]*models.Product, len(products))
+	for i := range products {
+		byId[products[i].ID] = &products[i]
+	}
+	reserved, err := ReservedQuantities(ctx, db, productIds)
+	if err != nil {
+		return nil, err
+	}
+	outstanding, err := OutstandingBackorders(ctx, db, productIds)
+	if err != nil {
+		return nil, err
+	}
+	var bundleIds []int64
+	for _, product := range products {
+		if product.IsBundle {
+			bundleIds = append(bundleIds, product.ID)
+		}
+	}
+	assemblable, err := bundleAvailability(ctx, db, bundleIds)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	view := &CartView{Items: []CartLine{}, Warnings: []CartWarning{}}
+	for _, entry := range entries {
+		product, ok := byId[entry.ProductID]
+		if !ok || product.Status != models.ProductStatusPublished {
+			view.Items = append(view.Items, CartLine{ProductID: entry.ProductID, UnitPrice: entry.Price, Quantity: entry.Quantity})
+			view.Warnings = append(view.Warnings, CartWarning{
+				ProductID: entry.ProductID,
+				Code:      CartWarningUnavailable,
+				Message:   "This product is no longer available",
+			})
+			view.Totals.ExcludedItems += entry.Quantity
+			continue
+		}
+		line := CartLine{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			Image:       product.Image,
+			UnitPrice:   product.Price,
+			Quantity:    entry.Quantity,
+			LineTotal:   roundMoney(product.Price * float64(entry.Quantity)),
+			Purchasable: true,
+		}
+		if product.Price != entry.Price {
+			view.Warnings = append(view.Warnings, CartWarning{
+				ProductID: product.ID,
+				Code:      CartWarningPriceChanged,
+				Message:   fmt.Sprintf("The price of %s changed from %.2f to %.2f", product.Name, entry.Price, product.Price),
+				OldPrice:  entry.Price,
+				NewPrice:  product.Price,
+			})
+		}
+		available := product.Quantity - reserved[product.ID]
+		if product.IsBundle {
+			available = assemblable[product.ID]
+		} else if product.IsDigital {
+			available = unlimitedStock
+		}
+		sellable := sellableQuantity(product, available, outstanding[product.ID], now)
+		switch CheckCartQuantity(product, sellable, entry.Quantity) {
+		case nil:
+			switch {
+			case isPreOrder(product, now):
+				line.PreOrder = true
+				view.Warnings = append(view.Warnings, CartWarning{
+					ProductID: product.ID,
+					Code:      CartWarningPreOrder,
+					Message:   fmt.Sprintf("%s is a pre-order and ships after %s", product.Name, product.ReleaseDate.Format("2006-01-02")),
+				})
+			case entry.Quantity > available:
+				line.Backordered = entry.Quantity - max(available, 0)
+				message := fmt.Sprintf("%d of %s will ship when back in stock", line.Backordered, product.Name)
+				if product.RestockDate != nil {
+					message += ", expected " + product.RestockDate.Format("2006-01-02")
+				}
+				view.Warnings = append(view.Warnings, CartWarning{ProductID: product.ID, Code: CartWarningBackordered, Message: message, Available: max(available, 0)})
+			}
+		case ErrInsufficientStock:
+			line.Purchasable = false
+			code, message := CartWarningInsufficientStock, fmt.Sprintf("Only %d of %s left in stock", sellable, product.Name)
+			if sellable <= 0 {
+				code, message = CartWarningUnavailable, product.Name+" is out of stock"
+			}
+			view.Warnings = append(view.Warnings, CartWarning{ProductID: product.ID, Code: code, Message: message, Available: max(sellable, 0)})
+		case ErrExceedsMaxPerOrder:
+			line.Purchasable = false
+			view.Warnings = append(view.Warnings, CartWarning{
+				ProductID: product.ID,
+				Code:      CartWarningExceedsMaxPerOrder,
+				Message:   fmt.Sprintf("You can order at most %d of %s", product.MaxPerOrder, product.Name),
+				Available: product.MaxPerOrder,
+			})
+		}
+		if line.Purchasable {
+			view.Totals.ItemCount += line.Quantity
+			view.Totals.Subtotal += line.LineTotal
+		} else {
+			view.Totals.ExcludedItems += line.Quantity
+		}
+		view.Items = append(view.Items, line)
+	}
+	view.Totals.Subtotal = roundMoney(view.Totals.Subtotal)
+	view.Totals.Total = view.Totals.Subtotal
+	return view, nil
+}
+
+// GetCartView returns the user's cart priced from current products. Cart
+// lines whose cached price went stale are updated to the current price.
+func GetCartView(ctx context.Context, db *gorm.DB, userId int64) (*CartView, error) {
+	items, err := GetCartItems(ctx, db, userId)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]cartEntry, len(items))
+	for i, item := range items {
+		entries[i] = cartEntry{ProductID: item.ProductID, Quantity: item.Quantity, Price: item.Price}
+	}
+	view, err := buildCartView(ctx, db, entries)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range view.Warnings {
+		if warning.Code == CartWarningPriceChanged {
+			if err := db.WithContext(ctx).Model(&models.UserProduct{}).
+				Where("user_id = ? AND product_id = ?", userId, warning.ProductID).
+				Update("price", warning.NewPrice).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+	return view, nil
+}
+
+// GetGuestCartView is GetCartView for a guest cart.
+func GetGuestCartView(ctx context.Context, db *gorm.DB, cartId string) (*CartView, error) {
+	items, err := GetGuestCartItems(ctx, db, cartId)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]cartEntry, len(items))
+	for i, item := range items {
+		entries[i] = cartEntry{ProductID: item.ProductID, Quantity: item.Quantity, Price: item.Price}
+	}
+	view, err := buildCartView(ctx, db, entries)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range view.Warnings {
+		if warning.Code == CartWarningPriceChanged {
+			for _, item := range items {
+				if item.ProductID == warning.ProductID {
+					if err := db.WithContext(ctx).Model(&item).Update("price", warning.NewPrice).Error; err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+	return view, nil
+}
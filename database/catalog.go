@@ -0,0 +1,306 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/catalog"
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrImportJobIdIsNotValid = errors.New("import job id is not valid")
+	ErrCantFindImportJob     = errors.New("can't find import job")
+)
+
+// errDryRun rolls back the transaction of a dry-run import row.
+var errDryRun = errors.New("dry run")
+
+// importProgressEvery is how many rows are imported between progress updates.
+const importProgressEvery = 50
+
+// CreateImportJob queues an import of rows, recording the rows that could
+// not be parsed as failed right away.
+func CreateImportJob(ctx context.Context, db *gorm.DB, job *models.ImportJob, rows []catalog.Row, rowErrors []catalog.RowError) error {
+	job.Status = models.ImportStatusPending
+	job.TotalRows = len(rows) + len(rowErrors)
+	job.ProcessedRows = len(rowErrors)
+	job.FailedRows = len(rowErrors)
+	for _, rowErr := range rowErrors {
+		job.Errors = append(job.Errors, models.ImportJobError{Line: rowErr.Line, SKU: rowErr.SKU, Message: rowErr.Message})
+	}
+	if err := db.WithContext(ctx).Create(job).Error; err != nil {
+		log.Println("Failed to create import job:", err)
+		return err
+	}
+	return nil
+}
+
+// GetImportJob loads an import job with its per-row error report.
+func GetImportJob(ctx context.Context, db *gorm.DB, jobId int64) (*models.ImportJob, error) {
+	if jobId <= 0 {
+		return nil, ErrImportJobIdIsNotValid
+	}
+	var job models.ImportJob
+	err := db.WithContext(ctx).Preload("Errors", func(db *gorm.DB) *gorm.DB {
+		return db.Order("line, id")
+	}).First(&job, jobId).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindImportJob
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RunImport upserts the rows of a queued job one by one, each in its own
+// transaction, so a bad row only fails itself. Progress is saved as it
+// goes; the job fails as a whole only when its bookkeeping does or ctx is
+// cancelled, as on shutdown.
+func RunImport(ctx context.Context, db *gorm.DB, job *models.ImportJob, rows []catalog.Row) error {
+	now := time.Now()
+	job.Status = models.ImportStatusRunning
+	job.StartedAt = &now
+	if err := db.WithContext(ctx).Model(job).Updates(map[string]interface{}{
+		"status":     job.Status,
+		"started_at": now,
+	}).Error; err != nil {
+		return err
+	}
+	progress := func() error {
+		return db.WithContext(ctx).Model(job).Updates(map[string]interface{}{
+			"processed_rows": job.ProcessedRows,
+			"created_rows":   job.CreatedRows,
+			"updated_rows":   job.UpdatedRows,
+			"failed_rows":    job.FailedRows,
+		}).Error
+	}
+	fail := func(err error) error {
+		// The job is marked failed even when ctx was what stopped it.
+		job.Status = models.ImportStatusFailed
+		db.WithContext(context.WithoutCancel(ctx)).Model(job).Updates(map[string]interface{}{
+			"status":      models.ImportStatusFailed,
+			"finished_at": time.Now(),
+		})
+		return err
+	}
+	for i, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+		created, err := importRow(ctx, db, job.CreatedBy, row, job.DryRun)
+		job.ProcessedRows++
+		switch {
+		case err != nil:
+			job.FailedRows++
+			rowErr := models.ImportJobError{ImportJobID: job.ID, Line: row.Line, SKU: row.SKU, Message: err.Error()}
+			var parsed *catalog.RowError
+			if errors.As(err, &parsed) {
+				rowErr.Message = parsed.Message
+			}
+			if err := db.WithContext(ctx).Create(&rowErr).Error; err != nil {
+				return fail(err)
+			}
+		case created:
+			job.CreatedRows++
+		default:
+			job.UpdatedRows++
+		}
+		if (i+1)%importProgressEvery == 0 {
+			if err := progress(); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	if err := progress(); err != nil {
+		return fail(err)
+	}
+	finished := time.Now()
+	job.Status = models.ImportStatusCompleted
+	job.FinishedAt = &finished
+	return db.WithContext(ctx).Model(job).Updates(map[string]interface{}{
+		"status":      job.Status,
+		"finished_at": finished,
+	}).Error
+}
+
+// FailInterruptedImports marks the jobs a previous run of the server left
+// pending or running as failed. Their rows only lived in that process, so
+// they can't be resumed; the admin has to upload the file again.
+func FailInterruptedImports(ctx context.Context, db *gorm.DB) error {
+	result := db.WithContext(ctx).Model(&models.ImportJob{}).
+		Where("status IN ?", []string{models.ImportStatusPending, models.ImportStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":      models.ImportStatusFailed,
+			"finished_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Marked %d interrupted import jobs as failed", result.RowsAffected)
+	}
+	return nil
+}
+
+// importRow creates or updates the product of one row, matched by ID and
+// then by SKU. Stock changes go through the ledger at the default
+// warehouse. A dry run does all the work and rolls it back.
+func importRow(ctx context.Context, db *gorm.DB, adminId int64, row catalog.Row, dryRun bool) (bool, error) {
+	created := false
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		switch {
+		case row.ID > 0:
+			if err := tx.First(&product, row.ID).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return fmt.Errorf("product %d doesn't exist", row.ID)
+				}
+				return err
+			}
+		case row.SKU != "":
+			if err := tx.Where("sku = ?", row.SKU).Limit(1).Find(&product).Error; err != nil {
+				return err
+			}
+		}
+		created = product.ID == 0
+		if err := row.Validate(created); err != nil {
+			return err
+		}
+		if row.SKU != "" && row.SKU != product.SKU {
+			var owner int64
+			if err := tx.Model(&models.Product{}).Where("sku = ? AND id <> ?", row.SKU, product.ID).
+				Limit(1).Pluck("id", &owner).Error; err != nil {
+				return err
+			}
+			if owner != 0 {
+				return fmt.Errorf("sku %s is already used by product %d", row.SKU, owner)
+			}
+		}
+		if row.CategoryID != nil {
+			var count int64
+			if err := tx.Model(&models.Category{}).Where("id = ?", *row.CategoryID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return fmt.Errorf("category %d doesn't exist", *row.CategoryID)
+			}
+		}
+		if created {
+			product = models.Product{
+				SKU:         row.SKU,
+				Name:        *row.Name,
+				Description: *row.Description,
+				CategoryID:  *row.CategoryID,
+				Price:       *row.Price,
+				Status:      models.ProductStatusDraft,
+			}
+			if err := tx.Create(&product).Error; err != nil {
+				return err
+			}
+			if err := RecordInitialPrice(ctx, tx, &product, adminId); err != nil {
+				return err
+			}
+		}
+		if changes := importChanges(row); len(changes) > 0 {
+			if err := tx.Model(&product).Updates(changes).Error; err != nil {
+				return err
+			}
+		}
+		if row.Status != nil && *row.Status != product.Status {
+			if _, err := SetProductStatus(ctx, tx, product.ID, *row.Status, nil, nil); err != nil {
+				return err
+			}
+		}
+		if !created && row.Price != nil {
+			if _, err := ChangeProductPrice(ctx, tx, product.ID, *row.Price, models.PriceChangeImport, adminId); err != nil {
+				return err
+			}
+		}
+		digital := product.IsDigital
+		if row.IsDigital != nil {
+			digital = *row.IsDigital
+		}
+		if row.Quantity != nil && !product.IsBundle && !digital {
+			if created && *row.Quantity > 0 {
+				warehouse, err := DefaultWarehouse(ctx, tx)
+				if err != nil {
+					return err
+				}
+				if _, err := AdjustStock(ctx, tx, adminId, warehouse.ID, product.ID, *row.Quantity, models.StockMovementReceipt, "import"); err != nil {
+					return err
+				}
+			} else if !created {
+				if err := SetProductStock(ctx, tx, adminId, product.ID, *row.Quantity, "import"); err != nil {
+					return err
+				}
+			}
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err == errDryRun {
+		err = nil
+	}
+	return created, err
+}
+
+// importChanges lists the columns a row sets, other than stock and price.
+func importChanges(row catalog.Row) map[string]interface{} {
+	changes := map[string]interface{}{}
+	if row.SKU != "" {
+		changes["sku"] = row.SKU
+	}
+	if row.Name != nil {
+		changes["name"] = *row.Name
+	}
+	if row.Description != nil {
+		changes["description"] = *row.Description
+	}
+	if row.CategoryID != nil {
+		changes["category_id"] = *row.CategoryID
+	}
+	if row.Image != nil {
+		changes["image"] = *row.Image
+	}
+	if row.MaxPerOrder != nil {
+		changes["max_per_order"] = *row.MaxPerOrder
+	}
+	if row.LowStockThreshold != nil {
+		changes["low_stock_threshold"] = *row.LowStockThreshold
+	}
+	if row.StockPolicy != nil {
+		changes["stock_policy"] = *row.StockPolicy
+	}
+	if row.BackorderLimit != nil {
+		changes["backorder_limit"] = *row.BackorderLimit
+	}
+	if row.IsDigital != nil {
+		changes["is_digital"] = *row.IsDigital
+	}
+	if row.DownloadLimit != nil {
+		changes["download_limit"] = *row.DownloadLimit
+	}
+	return changes
+}
+
+// ExportProducts calls fn for every product, in id order, loading them in
+// batches.
+func ExportProducts(ctx context.Context, db *gorm.DB, fn func(product *models.Product) error) error {
+	var batch []models.Product
+	return db.WithContext(ctx).Order("id").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
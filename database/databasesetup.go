@@ -1,72 +1,72 @@
 package database
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"time"
 
-	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/config"
+	"githum.com/muhammadAslam/ecommerce/migrations"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func DBSet() *gorm.DB {
+// Connect opens the configured database and sizes its connection pool,
+// without looking at the schema.
+func Connect(cfg config.Database) (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.New(postgres.Config{
-		DSN:                  "user=postgres password=azzan310 dbname=ecommerce port=5432 sslmode=disable TimeZone=Asia/Karachi",
+		DSN:                  cfg.DSN(),
 		PreferSimpleProtocol: true, // disables implicit prepared statement usage
 	}), &gorm.Config{})
 	if err != nil {
-		log.Fatal("failed to connect database", err)
+		return nil, fmt.Errorf("failed to connect database %s on %s:%d: %w", cfg.Name, cfg.Host, cfg.Port, err)
 	}
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Category{},
-		&models.Product{},
-		&models.UserProduct{},
-		&models.Address{},
-		&models.Order{},
-		&models.OrderItem{},
-		&models.Payment{},
-		&models.Review{},
-	)
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatal("failed to migrate models: " + err.Error())
-	}
-	fmt.Println("successfully migrated")
-	return db
-
-}
-
-var Client = DBSet()
-
-type ProductData struct {
-	DB        *gorm.DB
-	TableName string
-}
-
-func NewProductData(db *gorm.DB, tableName string) *ProductData {
-	return &ProductData{
-		DB:        db,
-		TableName: tableName,
+		return nil, err
 	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+	sqlDB.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime))
+	return db, nil
 }
 
-func (pd *ProductData) GetById(id int64) (*models.Product, error) {
-	var product models.Product
-	if err := pd.DB.First(&product, id).Error; err != nil {
+// DBSet connects to the configured database and makes sure every migration
+// has been applied. It never changes the schema itself; that is the job of
+// the migrate command.
+func DBSet(cfg config.Database) (*gorm.DB, error) {
+	db, err := Connect(cfg)
+	if err != nil {
 		return nil, err
 	}
-	return &product, nil
+	if err := checkSchema(context.Background(), db); err != nil {
+		return nil, fmt.Errorf("failed to check database schema: %w", err)
+	}
+	return db, nil
 }
 
-// UserData retrieves users from the database
-type UserData struct {
-	DB        *gorm.DB
-	TableName string
+// Ready reports whether the database answers and its schema is up to date,
+// for the readiness probe.
+func Ready(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("database is unreachable: %w", err)
+	}
+	return checkSchema(ctx, db)
 }
 
-func NewUserData(db *gorm.DB, tableName string) *UserData {
-	return &UserData{
-		DB:        db,
-		TableName: tableName,
+func checkSchema(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	migrator, err := migrations.New(sqlDB)
+	if err != nil {
+		return err
 	}
+	return migrator.Check(ctx)
 }
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// unlimitedStock is the available quantity of products that never run out.
+const unlimitedStock = math.MaxInt32
+
+var (
+	ErrProductNotDigital     = errors.New("product is not digital")
+	ErrCantFindProductFile   = errors.New("can't find product file")
+	ErrDownloadNotAvailable  = errors.New("download is not available for this order item")
+	ErrDownloadLimitReached  = errors.New("download limit reached for this order item")
+	ErrOrderItemIdIsNotValid = errors.New("order item id is not valid")
+)
+
+// Download is a file a customer can download for a digital order item.
+type Download struct {
+	OrderItemID   int64  `json:"order_item_id"`
+	FileID        int64  `json:"file_id"`
+	Name          string `json:"name"`
+	ContentType   string `json:"content_type"`
+	Size          int64  `json:"size"`
+	DownloadsLeft int    `json:"downloads_left"` // -1 when unlimited
+}
+
+// digitalOnly reports whether every product, counting the components of
+// bundles, is digital, so that an order of them has nothing to ship.
+func digitalOnly(ctx context.Context, db *gorm.DB, productIds []int64) (bool, error) {
+	var products []models.Product
+	if err := db.WithContext(ctx).Where("id IN ?", productIds).Find(&products).Error; err != nil {
+		return false, err
+	}
+	var bundleIds []int64
+	for _, product := range products {
+		if product.IsBundle {
+			bundleIds = append(bundleIds, product.ID)
+		} else if !product.IsDigital {
+			return false, nil
+		}
+	}
+	components, err := bundleComponents(db.WithContext(ctx), bundleIds)
+	if err != nil {
+		return false, err
+	}
+	for _, bundle := range components {
+		for _, component := range bundle {
+			if !component.Component.IsDigital {
+				return false, nil
+			}
+		}
+	}
+	return len(products) > 0, nil
+}
+
+// checkoutAddresses resolves the addresses of a new order of the products.
+// Orders of digital products only are never shipped: they get no shipping
+// address and the billing address is optional.
+func checkoutAddresses(ctx context.Context, db *gorm.DB, userId int64, productIds []int64, shippingId int64, billingId int64) (*models.Address, *models.Address, error) {
+	digital, err := digitalOnly(ctx, db, productIds)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !digital {
+		return ResolveCheckoutAddresses(ctx, db, userId, shippingId, billingId)
+	}
+	var billing *models.Address
+	if billingId > 0 {
+		billing, err = GetAddress(ctx, db, userId, billingId)
+	} else {
+		billing, err = GetDefaultAddress(ctx, db, userId, AddressKindBilling)
+		if err == ErrCantFindUserAddress {
+			billing, err = nil, nil
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, billing, nil
+}
+
+// setOrderAddresses links the order to its addresses and snapshots them.
+func setOrderAddresses(order *models.Order, shipping *models.Address, billing *models.Address) {
+	if shipping != nil {
+		order.AddressID = shipping.ID
+		order.ShippingAddress = SnapshotAddress(shipping)
+	}
+	if billing != nil {
+		order.BillingAddressID = billing.ID
+		order.BillingAddress = SnapshotAddress(billing)
+	}
+}
+
+// fulfillDigitalItems makes the digital items of a paid order downloadable.
+// It must run inside the capture transaction.
+func fulfillDigitalItems(tx *gorm.DB, order *models.Order, now time.Time) error {
+	return tx.Model(&models.OrderItem{}).
+		Where("order_id = ? AND is_digital AND fulfilled_at IS NULL", order.ID).
+		Update("fulfilled_at", now).Error
+}
+
+// GetProductFiles lists the files of a digital product.
+func GetProductFiles(ctx context.Context, db *gorm.DB, productId int64) ([]models.ProductFile, error) {
+	var files []models.ProductFile
+	err := db.WithContext(ctx).Where("product_id = ?", productId).Order("id").Find(&files).Error
+	return files, err
+}
+
+// AddProductFile records a file stored for a digital product.
+func AddProductFile(ctx context.Context, db *gorm.DB, file *models.ProductFile) error {
+	var product models.Product
+	if err := db.WithContext(ctx).First(&product, file.ProductID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrCanNotFindProduct
+		}
+		return err
+	}
+	if !product.IsDigital {
+		return ErrProductNotDigital
+	}
+	if err := db.WithContext(ctx).Create(file).Error; err != nil {
+		log.Println("Failed to add product file:", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteProductFile removes a file from a product and returns it so the
+// caller can delete its content.
+func DeleteProductFile(ctx context.Context, db *gorm.DB, productId int64, fileId int64) (*models.ProductFile, error) {
+	var file models.ProductFile
+	if err := db.WithContext(ctx).First(&file, "id = ? AND product_id = ?", fileId, productId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindProductFile
+		}
+		return nil, err
+	}
+	if err := db.WithContext(ctx).Unscoped().Delete(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// GetDownloads lists the files the user can download for an order. Items
+// of unpaid or cancelled orders have none.
+func GetDownloads(ctx context.Context, db *gorm.DB, userId int64, orderId int64) ([]Download, error) {
+	order, err := GetOrder(ctx, db, userId, orderId)
+	if err != nil {
+		return nil, err
+	}
+	var items []models.OrderItem
+	if err := db.WithContext(ctx).Where("order_id = ? AND is_digital AND fulfilled_at IS NOT NULL", order.ID).
+		Order("id").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	downloads := []Download{}
+	if order.OrderStatus == models.OrderStatusCancelled {
+		return downloads, nil
+	}
+	for _, item := range items {
+		files, err := GetProductFiles(ctx, db, item.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		left := -1
+		if item.DownloadLimit > 0 {
+			left = max(item.DownloadLimit-item.Downloads, 0)
+		}
+		for _, file := range files {
+			downloads = append(downloads, Download{
+				OrderItemID:   item.ID,
+				FileID:        file.ID,
+				Name:          file.Name,
+				ContentType:   file.ContentType,
+				Size:          file.Size,
+				DownloadsLeft: left,
+			})
+		}
+	}
+	return downloads, nil
+}
+
+// ClaimDownload checks the order item may download the file, calls open
+// with it and counts the download once open succeeds, so a file that can't
+// be served doesn't use up the item's limit. It fails once the limit is
+// used up.
+func ClaimDownload(ctx context.Context, db *gorm.DB, orderItemId int64, fileId int64, open func(file *models.ProductFile) error) (*models.ProductFile, error) {
+	if orderItemId <= 0 {
+		return nil, ErrOrderItemIdIsNotValid
+	}
+	var file models.ProductFile
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var item models.OrderItem
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, orderItemId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrDownloadNotAvailable
+			}
+			return err
+		}
+		var order models.Order
+		if err := tx.First(&order, item.OrderID).Error; err != nil {
+			return err
+		}
+		if !item.IsDigital || item.FulfilledAt == nil || order.OrderStatus == models.OrderStatusCancelled {
+			return ErrDownloadNotAvailable
+		}
+		if item.DownloadLimit > 0 && item.Downloads >= item.DownloadLimit {
+			return ErrDownloadLimitReached
+		}
+		if err := tx.First(&file, "id = ? AND product_id = ?", fileId, item.ProductID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCantFindProductFile
+			}
+			return err
+		}
+		if err := open(&file); err != nil {
+			return err
+		}
+		return tx.Model(&item).Update("downloads", gorm.Expr("downloads + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
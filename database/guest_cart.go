@@ -0,0 +1,264 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GuestCartLifetime is how long an untouched guest cart is kept.
+const GuestCartLifetime = 30 * 24 * time.Hour
+
+var ErrCantFindGuestCart = errors.New("can't find guest cart")
+
+// getGuestCart loads a live guest cart by id, or creates it when create is
+// set. An expired cart the cleanup job hasn't removed yet is started over
+// empty. Every access pushes the expiry back.
+func getGuestCart(ctx context.Context, db *gorm.DB, cartId string, create bool) (*models.GuestCart, error) {
+	if cartId == "" {
+		return nil, ErrCantFindGuestCart
+	}
+	var cart models.GuestCart
+	err := db.WithContext(ctx).Preload("Items").First(&cart, "token = ? AND expires_at > ?", cartId, time.Now()).Error
+	if err == gorm.ErrRecordNotFound {
+		if !create {
+			return nil, ErrCantFindGuestCart
+		}
+		now := time.Now()
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			expired := tx.Model(&models.GuestCart{}).Select("id").Where("token = ? AND expires_at <= ?", cartId, now)
+			if err := tx.Unscoped().Where("guest_cart_id IN (?)", expired).Delete(&models.GuestCartItem{}).Error; err != nil {
+				return err
+			}
+			cart = models.GuestCart{Token: cartId, ExpiresAt: now.Add(GuestCartLifetime)}
+			return tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "token"}},
+				DoUpdates: clause.AssignmentColumns([]string{"expires_at", "updated_at"}),
+			}).Create(&cart).Error
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Read the row back, as a cart revived or created concurrently keeps
+		// its own id and items.
+		cart = models.GuestCart{}
+		if err := db.WithContext(ctx).Preload("Items").First(&cart, "token = ?", cartId).Error; err != nil {
+			return nil, err
+		}
+		return &cart, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cart.ExpiresAt = time.Now().Add(GuestCartLifetime)
+	if err := db.WithContext(ctx).Model(&cart).Update("expires_at", cart.ExpiresAt).Error; err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+func GetGuestCartItems(ctx context.Context, db *gorm.DB, cartId string) ([]models.GuestCartItem, error) {
+	cart, err := getGuestCart(ctx, db, cartId, false)
+	if err == ErrCantFindGuestCart {
+		return []models.GuestCartItem{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cart.Items, nil
+}
+
+// AddProductToGuestCart is AddProductToCart for anonymous visitors.
+func AddProductToGuestCart(ctx context.Context, db *gorm.DB, cartId string, productId int64, qty int) error {
+	if qty <= 0 {
+		return ErrQuantityMustBePositive
+	}
+	var product models.Product
+	if err := db.WithContext(ctx).Scopes(Published).First(&product, "id = ?", productId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrCanNotFindProduct
+		}
+		return err
+	}
+	sellable, err := SellableQuantity(ctx, db, &product)
+	if err != nil {
+		return err
+	}
+	cart, err := getGuestCart(ctx, db, cartId, true)
+	if err != nil {
+		return err
+	}
+	for _, item := range cart.Items {
+		if item.ProductID == productId {
+			if err := CheckCartQuantity(&product, sellable, item.Quantity+qty); err != nil {
+				return err
+			}
+			item.Quantity += qty
+			item.Price = product.Price
+			if err := db.WithContext(ctx).Save(&item).Error; err != nil {
+				log.Println("Failed to update product quantity in guest cart:", err)
+				return err
+			}
+			return nil
+		}
+	}
+	if err := CheckCartQuantity(&product, sellable, qty); err != nil {
+		return err
+	}
+	item := models.GuestCartItem{
+		GuestCartID: cart.ID,
+		ProductID:   productId,
+		ProductName: product.Name,
+		Price:       product.Price,
+		Quantity:    qty,
+		Image:       product.Image,
+	}
+	if err := db.WithContext(ctx).Create(&item).Error; err != nil {
+		log.Println("Failed to add product to guest cart:", err)
+		return err
+	}
+	return nil
+}
+
+func RemoveProductFromGuestCart(ctx context.Context, db *gorm.DB, cartId string, productId int64) error {
+	cart, err := getGuestCart(ctx, db, cartId, false)
+	if err == ErrCantFindGuestCart {
+		return ErrCantRemoveItemCart
+	}
+	if err != nil {
+		return err
+	}
+	for _, item := range cart.Items {
+		if item.ProductID == productId {
+			return db.WithContext(ctx).Unscoped().Delete(&item).Error
+		}
+	}
+	return ErrCantRemoveItemCart
+}
+
+func UpdateGuestCartQuantity(ctx context.Context, db *gorm.DB, cartId string, productId int64, qty int) error {
+	if qty <= 0 {
+		return ErrQuantityMustBePositive
+	}
+	cart, err := getGuestCart(ctx, db, cartId, false)
+	if err == ErrCantFindGuestCart {
+		return ErrCantUpdateProductQuantity
+	}
+	if err != nil {
+		return err
+	}
+	for _, item := range cart.Items {
+		if item.ProductID == productId {
+			var product models.Product
+			if err := db.WithContext(ctx).Scopes(Published).First(&product, productId).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return ErrCanNotFindProduct
+				}
+				return err
+			}
+			sellable, err := SellableQuantity(ctx, db, &product)
+			if err != nil {
+				return err
+			}
+			if err := CheckCartQuantity(&product, sellable, qty); err != nil {
+				return err
+			}
+			return db.WithContext(ctx).Model(&item).Updates(map[string]interface{}{
+				"quantity": qty,
+				"price":    product.Price,
+			}).Error
+		}
+	}
+	return ErrCantUpdateProductQuantity
+}
+
+// MergeGuestCart moves a guest cart into the user's cart after login or
+// signup. Quantities of products already in the user's cart are summed and
+// every line is capped the way the cart handlers check it: at the sellable
+// quantity, which counts reservations and the backorder or pre-order policy,
+// and at the per-order limit. Products that are gone or can't be sold are
+// dropped. The guest cart
+// is deleted afterwards.
+func MergeGuestCart(ctx context.Context, db *gorm.DB, cartId string, userId int64) error {
+	if userId <= 0 {
+		return ErrUserIdIsNotValid
+	}
+	cart, err := getGuestCart(ctx, db, cartId, false)
+	if err == ErrCantFindGuestCart {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, item := range cart.Items {
+			var product models.Product
+			if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Scopes(Published).First(&product, item.ProductID).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					continue
+				}
+				return err
+			}
+			var line models.UserProduct
+			err := tx.First(&line, "user_id = ? AND product_id = ?", userId, item.ProductID).Error
+			if err != nil && err != gorm.ErrRecordNotFound {
+				return err
+			}
+			sellable, err := SellableQuantity(ctx, tx, &product)
+			if err != nil {
+				return err
+			}
+			quantity := line.Quantity + item.Quantity
+			if err := CheckCartQuantity(&product, sellable, quantity); err != nil {
+				limit := sellable
+				if product.MaxPerOrder > 0 {
+					limit = min(limit, product.MaxPerOrder)
+				}
+				log.Printf("Guest cart merge capped product %d at %d for user %d", product.ID, limit, userId)
+				quantity = limit
+			}
+			switch {
+			case quantity <= line.Quantity:
+				// Nothing to add beyond what the user already has.
+			case line.ID == 0:
+				line = models.UserProduct{
+					UserID:      userId,
+					ProductID:   product.ID,
+					ProductName: product.Name,
+					Price:       product.Price,
+					Quantity:    quantity,
+					Rating:      product.Rating,
+					Image:       product.Image,
+				}
+				if err := tx.Create(&line).Error; err != nil {
+					return err
+				}
+			default:
+				if err := tx.Model(&line).Update("quantity", quantity).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Unscoped().Delete(&models.GuestCartItem{}, "guest_cart_id = ?", cart.ID).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(cart).Error
+	})
+}
+
+// DeleteExpiredGuestCarts removes guest carts nobody has touched within
+// GuestCartLifetime.
+func DeleteExpiredGuestCarts(ctx context.Context, db *gorm.DB) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&models.GuestCart{}).Select("id").Where("expires_at <= ?", time.Now())
+		if err := tx.Unscoped().Where("guest_cart_id IN (?)", expired).Delete(&models.GuestCartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("expires_at <= ?", time.Now()).Delete(&models.GuestCart{}).Error
+	})
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyKeyLifetime is how long a stored response can be replayed.
+const IdempotencyKeyLifetime = 24 * time.Hour
+
+// IdempotencyLockTimeout is how long a claim holds its key without being
+// extended before a retry may assume the request died and take the key over.
+// It is a variable so tests can shorten it.
+var IdempotencyLockTimeout = 30 * time.Second
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyLost       = errors.New("idempotency key was taken over by another request")
+)
+
+// ClaimIdempotencyKey reserves the user's key for a request with the given
+// hash. When claimed is true the caller must handle the request and then call
+// CompleteIdempotencyKey or ReleaseIdempotencyKey. Otherwise the returned
+// record holds the stored response of an earlier request to replay.
+func ClaimIdempotencyKey(ctx context.Context, db *gorm.DB, userId int64, key string, hash string) (record *models.IdempotencyKey, claimed bool, err error) {
+	now := time.Now()
+	created := models.IdempotencyKey{
+		UserID:      userId,
+		Key:         key,
+		RequestHash: hash,
+		LockedUntil: now.Add(IdempotencyLockTimeout),
+		ExpiresAt:   now.Add(IdempotencyKeyLifetime),
+	}
+	result := db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&created)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return &created, true, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := db.WithContext(ctx).First(&existing, "user_id = ? AND key = ?", userId, key).Error; err != nil {
+		return nil, false, err
+	}
+	if existing.ExpiresAt.Before(now) {
+		// The old response is no longer replayable; start over.
+		return takeOverIdempotencyKey(ctx, db, &existing, hash, map[string]interface{}{
+			"request_hash": hash,
+			"status_code":  0,
+			"content_type": "",
+			"response":     nil,
+			"expires_at":   now.Add(IdempotencyKeyLifetime),
+		})
+	}
+	if existing.RequestHash != hash {
+		return nil, false, ErrIdempotencyKeyReused
+	}
+	if existing.StatusCode != 0 {
+		return &existing, false, nil
+	}
+	if existing.LockedUntil.After(now) {
+		return nil, false, ErrIdempotencyKeyInProgress
+	}
+	// The first request never finished, so let this retry run it.
+	return takeOverIdempotencyKey(ctx, db, &existing, hash, map[string]interface{}{})
+}
+
+// takeOverIdempotencyKey re-locks an expired or abandoned key. The update is
+// conditional on the lock the record was read with, so only one of several
+// concurrent retries wins.
+func takeOverIdempotencyKey(ctx context.Context, db *gorm.DB, existing *models.IdempotencyKey, hash string, updates map[string]interface{}) (*models.IdempotencyKey, bool, error) {
+	lockedUntil := time.Now().Add(IdempotencyLockTimeout)
+	updates["locked_until"] = lockedUntil
+	result := db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("id = ? AND locked_until = ?", existing.ID, existing.LockedUntil).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, false, ErrIdempotencyKeyInProgress
+	}
+	existing.RequestHash = hash
+	existing.StatusCode = 0
+	existing.LockedUntil = lockedUntil
+	return existing, true, nil
+}
+
+// ExtendIdempotencyKey pushes back the lock of a claimed key whose request is
+// still running. It fails with ErrIdempotencyKeyLost when the lock already
+// lapsed and a retry took the key over.
+func ExtendIdempotencyKey(ctx context.Context, db *gorm.DB, record *models.IdempotencyKey) error {
+	lockedUntil := time.Now().Add(IdempotencyLockTimeout)
+	result := db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("id = ? AND locked_until = ?", record.ID, record.LockedUntil).
+		Update("locked_until", lockedUntil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrIdempotencyKeyLost
+	}
+	record.LockedUntil = lockedUntil
+	return nil
+}
+
+// CompleteIdempotencyKey stores the response so later retries replay it.
+func CompleteIdempotencyKey(ctx context.Context, db *gorm.DB, record *models.IdempotencyKey, statusCode int, contentType string, response []byte) error {
+	return db.WithContext(ctx).Model(record).Updates(map[string]interface{}{
+		"status_code":  statusCode,
+		"content_type": contentType,
+		"response":     response,
+	}).Error
+}
+
+// ReleaseIdempotencyKey forgets a claimed key whose request failed, so the
+// client can retry it.
+func ReleaseIdempotencyKey(ctx context.Context, db *gorm.DB, record *models.IdempotencyKey) error {
+	return db.WithContext(ctx).Unscoped().Delete(record).Error
+}
+
+// DeleteExpiredIdempotencyKeys removes keys past IdempotencyKeyLifetime.
+func DeleteExpiredIdempotencyKeys(ctx context.Context, db *gorm.DB) error {
+	return db.WithContext(ctx).Unscoped().Where("expires_at <= ?", time.Now()).Delete(&models.IdempotencyKey{}).Error
+}
@@ -0,0 +1,392 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+
+	"githum.com/muhammadAslam/ecommerce/inventory"
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrWarehouseIdIsNotValid = errors.New("warehouse id is not valid")
+	ErrCantFindWarehouse     = errors.New("can't find warehouse")
+	ErrMovementTypeNotValid  = errors.New("stock movement type is not valid")
+	ErrMovementReasonMissing = errors.New("stock movement needs a reason")
+	ErrStockWouldGoNegative  = errors.New("not enough stock at this warehouse")
+	ErrTransferToSameSource  = errors.New("can't transfer stock to the same warehouse")
+)
+
+// DefaultWarehouse is the preferred active warehouse; admin stock edits made
+// on the product itself land there.
+func DefaultWarehouse(ctx context.Context, db *gorm.DB) (*models.Warehouse, error) {
+	var warehouse models.Warehouse
+	if err := db.WithContext(ctx).Where("active").Order("priority, id").First(&warehouse).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindWarehouse
+		}
+		return nil, err
+	}
+	return &warehouse, nil
+}
+
+func GetWarehouses(ctx context.Context, db *gorm.DB) ([]models.Warehouse, error) {
+	var warehouses []models.Warehouse
+	err := db.WithContext(ctx).Order("priority, id").Find(&warehouses).Error
+	return warehouses, err
+}
+
+func CreateWarehouse(ctx context.Context, db *gorm.DB, warehouse *models.Warehouse) error {
+	warehouse.ID = 0
+	if err := db.WithContext(ctx).Create(warehouse).Error; err != nil {
+		log.Println("Failed to create warehouse:", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateWarehouse renames, re-prioritizes or (de)activates a warehouse.
+// Inactive warehouses keep their stock, but it leaves the product totals and
+// is skipped by allocation until the warehouse is activated again.
+// Reactivated stock goes to waiting backorders first.
+func UpdateWarehouse(ctx context.Context, db *gorm.DB, warehouseId int64, changes models.Warehouse) (*models.Warehouse, error) {
+	if warehouseId <= 0 {
+		return nil, ErrWarehouseIdIsNotValid
+	}
+	var warehouse models.Warehouse
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&warehouse, warehouseId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCantFindWarehouse
+			}
+			return err
+		}
+		wasActive := warehouse.Active
+		if err := tx.Model(&warehouse).Select("Name", "Priority", "Active").Updates(changes).Error; err != nil {
+			return err
+		}
+		if warehouse.Active == wasActive {
+			return nil
+		}
+		sign := -1
+		if warehouse.Active {
+			sign = 1
+		}
+		if err := tx.Exec("UPDATE products SET quantity = quantity + ? * "+
+			"(SELECT stock_levels.quantity FROM stock_levels WHERE stock_levels.product_id = products.id AND stock_levels.warehouse_id = ?) "+
+			"WHERE id IN (SELECT product_id FROM stock_levels WHERE warehouse_id = ?)", sign, warehouseId, warehouseId).Error; err != nil {
+			return err
+		}
+		if !warehouse.Active {
+			return nil
+		}
+		var productIds []int64
+		if err := tx.Model(&models.StockLevel{}).Where("warehouse_id = ? AND quantity > 0", warehouseId).
+			Order("product_id").Pluck("product_id", &productIds).Error; err != nil {
+			return err
+		}
+		for _, productId := range productIds {
+			if err := fillBackorders(tx, productId, warehouseId); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &warehouse, nil
+}
+
+func getWarehouse(ctx context.Context, db *gorm.DB, warehouseId int64) (*models.Warehouse, error) {
+	if warehouseId <= 0 {
+		return nil, ErrWarehouseIdIsNotValid
+	}
+	var warehouse models.Warehouse
+	if err := db.WithContext(ctx).First(&warehouse, warehouseId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindWarehouse
+		}
+		return nil, err
+	}
+	return &warehouse, nil
+}
+
+// recordMovement appends the movement to the ledger and applies it to the
+// warehouse's stock level and, for active warehouses, the product's cached
+// total. Incoming stock at an active warehouse goes to waiting backorders
+// first. It must run inside a transaction; a movement that would take the
+// level below zero fails.
+func recordMovement(tx *gorm.DB, movement *models.StockMovement) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.StockLevel{WarehouseID: movement.WarehouseID, ProductID: movement.ProductID}).Error; err != nil {
+		return err
+	}
+	var level models.StockLevel
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&level, "warehouse_id = ? AND product_id = ?", movement.WarehouseID, movement.ProductID).Error; err != nil {
+		return err
+	}
+	if level.Quantity+movement.Quantity < 0 {
+		return ErrStockWouldGoNegative
+	}
+	if err := tx.Create(movement).Error; err != nil {
+		log.Println("Failed to record stock movement:", err)
+		return err
+	}
+	if err := tx.Model(&level).Update("quantity", level.Quantity+movement.Quantity).Error; err != nil {
+		return err
+	}
+	var warehouse models.Warehouse
+	if err := tx.Select("id", "active").First(&warehouse, movement.WarehouseID).Error; err != nil {
+		return err
+	}
+	if !warehouse.Active {
+		return nil
+	}
+	if err := tx.Model(&models.Product{}).Where("id = ?", movement.ProductID).
+		Update("quantity", gorm.Expr("quantity + ?", movement.Quantity)).Error; err != nil {
+		return err
+	}
+	if movement.Quantity > 0 {
+		return fillBackorders(tx, movement.ProductID, movement.WarehouseID)
+	}
+	return nil
+}
+
+// AdjustStock books a receipt, customer return or manual adjustment of
+// quantity units at a warehouse. Receipts and returns must add stock.
+func AdjustStock(ctx context.Context, db *gorm.DB, adminId int64, warehouseId int64, productId int64, quantity int, movementType string, reason string) (*models.StockMovement, error) {
+	switch movementType {
+	case models.StockMovementReceipt, models.StockMovementReturn:
+		if quantity <= 0 {
+			return nil, ErrQuantityMustBePositive
+		}
+	case models.StockMovementAdjustment:
+		if quantity == 0 {
+			return nil, ErrQuantityMustBePositive
+		}
+	default:
+		return nil, ErrMovementTypeNotValid
+	}
+	if reason == "" {
+		return nil, ErrMovementReasonMissing
+	}
+	if _, err := getWarehouse(ctx, db, warehouseId); err != nil {
+		return nil, err
+	}
+	var product models.Product
+	if err := db.WithContext(ctx).First(&product, productId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCanNotFindProduct
+		}
+		return nil, err
+	}
+	if product.IsBundle {
+		return nil, ErrBundleHasNoStock
+	}
+	movement := models.StockMovement{
+		WarehouseID: warehouseId,
+		ProductID:   productId,
+		Quantity:    quantity,
+		Type:        movementType,
+		Reason:      reason,
+		CreatedBy:   adminId,
+	}
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return recordMovement(tx, &movement)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &movement, nil
+}
+
+// TransferStock moves quantity units of a product between warehouses as a
+// pair of ledger entries sharing a reference.
+func TransferStock(ctx context.Context, db *gorm.DB, adminId int64, fromId int64, toId int64, productId int64, quantity int, reason string) ([]models.StockMovement, error) {
+	if quantity <= 0 {
+		return nil, ErrQuantityMustBePositive
+	}
+	if reason == "" {
+		return nil, ErrMovementReasonMissing
+	}
+	if fromId == toId {
+		return nil, ErrTransferToSameSource
+	}
+	for _, id := range []int64{fromId, toId} {
+		if _, err := getWarehouse(ctx, db, id); err != nil {
+			return nil, err
+		}
+	}
+	var product models.Product
+	if err := db.WithContext(ctx).First(&product, productId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCanNotFindProduct
+		}
+		return nil, err
+	}
+	if product.IsBundle {
+		return nil, ErrBundleHasNoStock
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	reference := "transfer:" + hex.EncodeToString(buf)
+	movements := []models.StockMovement{
+		{WarehouseID: fromId, ProductID: productId, Quantity: -quantity, Type: models.StockMovementTransfer, Reason: reason, Reference: reference, CreatedBy: adminId},
+		{WarehouseID: toId, ProductID: productId, Quantity: quantity, Type: models.StockMovementTransfer, Reason: reason, Reference: reference, CreatedBy: adminId},
+	}
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range movements {
+			if err := recordMovement(tx, &movements[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
+
+// SetProductStock books the difference between quantity and the product's
+// current total as an adjustment at the default warehouse. It backs the
+// Quantity field of the admin product endpoints.
+func SetProductStock(ctx context.Context, db *gorm.DB, adminId int64, productId int64, quantity int, reason string) error {
+	warehouse, err := DefaultWarehouse(ctx, db)
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCanNotFindProduct
+			}
+			return err
+		}
+		if quantity == product.Quantity {
+			return nil
+		}
+		if product.IsBundle {
+			return ErrBundleHasNoStock
+		}
+		return recordMovement(tx, &models.StockMovement{
+			WarehouseID: warehouse.ID,
+			ProductID:   productId,
+			Quantity:    quantity - product.Quantity,
+			Type:        models.StockMovementAdjustment,
+			Reason:      reason,
+			CreatedBy:   adminId,
+		})
+	})
+}
+
+// GetStockLevels lists a product's stock per warehouse.
+func GetStockLevels(ctx context.Context, db *gorm.DB, productId int64) ([]models.StockLevel, error) {
+	var levels []models.StockLevel
+	err := db.WithContext(ctx).Preload("Warehouse").Where("product_id = ?", productId).Order("warehouse_id").Find(&levels).Error
+	return levels, err
+}
+
+// GetStockMovements returns the newest ledger entries, optionally filtered by
+// product and warehouse (zero means any).
+func GetStockMovements(ctx context.Context, db *gorm.DB, productId int64, warehouseId int64, limit int) ([]models.StockMovement, error) {
+	query := db.WithContext(ctx).Order("id DESC").Limit(limit)
+	if productId > 0 {
+		query = query.Where("product_id = ?", productId)
+	}
+	if warehouseId > 0 {
+		query = query.Where("warehouse_id = ?", warehouseId)
+	}
+	var movements []models.StockMovement
+	err := query.Find(&movements).Error
+	return movements, err
+}
+
+// RebuildStockLevels recomputes every stock level from the ledger and every
+// product total from the levels at active warehouses, repairing any drift.
+func RebuildStockLevels(ctx context.Context, db *gorm.DB) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM stock_levels").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("INSERT INTO stock_levels (warehouse_id, product_id, quantity, updated_at) " +
+			"SELECT warehouse_id, product_id, SUM(quantity), NOW() FROM stock_movements GROUP BY warehouse_id, product_id").Error; err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE products SET quantity = COALESCE((SELECT SUM(stock_levels.quantity) FROM stock_levels " +
+			"JOIN warehouses ON warehouses.id = stock_levels.warehouse_id AND warehouses.active AND warehouses.deleted_at IS NULL " +
+			"WHERE stock_levels.product_id = products.id), 0)").Error
+	})
+}
+
+// allocateOrder picks the warehouses that ship the order's lines using the
+// strategy and books a sale movement for each pick.
+func allocateOrder(tx *gorm.DB, strategy inventory.Strategy, orderId int64, lines []inventory.Line) error {
+	// A product can be on the order directly and inside a bundle; ship it
+	// as one line.
+	var merged []inventory.Line
+	index := map[int64]int{}
+	for _, line := range lines {
+		if i, ok := index[line.ProductID]; ok {
+			merged[i].Quantity += line.Quantity
+			continue
+		}
+		index[line.ProductID] = len(merged)
+		merged = append(merged, line)
+	}
+	lines = merged
+	productIds := make([]int64, len(lines))
+	for i, line := range lines {
+		productIds[i] = line.ProductID
+	}
+	var levels []models.StockLevel
+	if err := tx.Joins("JOIN warehouses ON warehouses.id = stock_levels.warehouse_id AND warehouses.active AND warehouses.deleted_at IS NULL").
+		Where("stock_levels.product_id IN ? AND stock_levels.quantity > 0", productIds).
+		Preload("Warehouse").
+		Find(&levels).Error; err != nil {
+		return err
+	}
+	position := map[int64]int{}
+	var warehouses []inventory.Warehouse
+	for _, level := range levels {
+		i, ok := position[level.WarehouseID]
+		if !ok {
+			i = len(warehouses)
+			position[level.WarehouseID] = i
+			warehouses = append(warehouses, inventory.Warehouse{ID: level.WarehouseID, Priority: level.Warehouse.Priority, Stock: map[int64]int{}})
+		}
+		warehouses[i].Stock[level.ProductID] = level.Quantity
+	}
+	picks, err := strategy.Allocate(lines, warehouses)
+	if err == inventory.ErrCantAllocate {
+		return ErrInsufficientStock
+	}
+	if err != nil {
+		return err
+	}
+	for _, pick := range picks {
+		if err := recordMovement(tx, &models.StockMovement{
+			WarehouseID: pick.WarehouseID,
+			ProductID:   pick.ProductID,
+			Quantity:    -pick.Quantity,
+			Type:        models.StockMovementSale,
+			OrderID:     &orderId,
+		}); err != nil {
+			if err == ErrStockWouldGoNegative {
+				return ErrInsufficientStock
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"githum.com/muhammadAslam/ecommerce/inventory"
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB creates the schema from the models in a SQLite file that is
+// removed with the test.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// createProduct adds a product in a new category.
+func createProduct(t *testing.T, db *gorm.DB, product models.Product) *models.Product {
+	t.Helper()
+	category := models.Category{Name: "Category " + product.Name, Slug: "category-" + product.Name}
+	if err := db.Create(&category).Error; err != nil {
+		t.Fatal(err)
+	}
+	product.CategoryID = category.ID
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &product
+}
+
+func TestAllocateOrderSkipsInactiveWarehouses(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy inventory.Strategy
+		quantity int
+		// want is the stock left at the inactive, preferred and other
+		// warehouse, or nil when the order can't be allocated.
+		want []int
+	}{
+		{"priority", inventory.Priority{}, 5, []int{10, 0, 2}},
+		{"priority beyond active stock", inventory.Priority{}, 8, nil},
+		{"single", inventory.SingleWarehouse{}, 4, []int{10, 3, 0}},
+		{"single beyond active stock", inventory.SingleWarehouse{}, 8, nil},
+		{"most stock", inventory.MostStock{}, 2, []int{10, 3, 2}},
+		{"most stock beyond active stock", inventory.MostStock{}, 8, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDB(t)
+			// The inactive warehouse is preferred and holds the most stock,
+			// so every strategy would pick it first if it were active.
+			warehouses := []models.Warehouse{
+				{Code: "CLOSED", Name: "Closed", Priority: 0, Active: false},
+				{Code: "EAST", Name: "East", Priority: 1, Active: true},
+				{Code: "WEST", Name: "West", Priority: 2, Active: true},
+			}
+			if err := db.Create(&warehouses).Error; err != nil {
+				t.Fatal(err)
+			}
+			product := createProduct(t, db, models.Product{Name: "Desk", Price: 200, Quantity: 7})
+			for i, quantity := range []int{10, 3, 4} {
+				level := models.StockLevel{WarehouseID: warehouses[i].ID, ProductID: product.ID, Quantity: quantity}
+				if err := db.Create(&level).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				return allocateOrder(tx, test.strategy, 1, []inventory.Line{{ProductID: product.ID, Quantity: test.quantity}})
+			})
+			if test.want == nil {
+				if err != ErrInsufficientStock {
+					t.Fatalf("got error %v, want %v", err, ErrInsufficientStock)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range test.want {
+				var level models.StockLevel
+				if err := db.First(&level, "warehouse_id = ? AND product_id = ?", warehouses[i].ID, product.ID).Error; err != nil {
+					t.Fatal(err)
+				}
+				if level.Quantity != want {
+					t.Errorf("%s has %d left, want %d", warehouses[i].Code, level.Quantity, want)
+				}
+			}
+		})
+	}
+}
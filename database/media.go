@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrCantFindProductImage   = errors.New("can't find product image")
+	ErrImageOrderNotValid     = errors.New("image order must list every image of the product exactly once")
+	ErrImageVariantIsNotValid = errors.New("image variant must be original, medium or thumbnail")
+)
+
+// ProductImageURL is where the variant of a product image is served.
+func ProductImageURL(image *models.ProductImage, variant string) string {
+	return fmt.Sprintf("/products/%d/images/%d/%s", image.ProductID, image.ID, variant)
+}
+
+// ProductImageKey returns the storage key of a variant of the image.
+func ProductImageKey(image *models.ProductImage, variant string) (string, error) {
+	switch variant {
+	case "original":
+		return image.OriginalKey, nil
+	case "medium":
+		return image.MediumKey, nil
+	case "thumbnail":
+		return image.ThumbnailKey, nil
+	}
+	return "", ErrImageVariantIsNotValid
+}
+
+// GetProductImages lists the images of a product in display order.
+func GetProductImages(ctx context.Context, db *gorm.DB, productId int64) ([]models.ProductImage, error) {
+	var images []models.ProductImage
+	err := db.WithContext(ctx).Where("product_id = ?", productId).Order("position, id").Find(&images).Error
+	return images, err
+}
+
+func GetProductImage(ctx context.Context, db *gorm.DB, productId int64, imageId int64) (*models.ProductImage, error) {
+	var image models.ProductImage
+	if err := db.WithContext(ctx).First(&image, "id = ? AND product_id = ?", imageId, productId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindProductImage
+		}
+		return nil, err
+	}
+	return &image, nil
+}
+
+// AddProductImages appends stored images after the product's existing ones.
+func AddProductImages(ctx context.Context, db *gorm.DB, productId int64, images []models.ProductImage) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCanNotFindProduct
+			}
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.ProductImage{}).Where("product_id = ?", productId).Count(&count).Error; err != nil {
+			return err
+		}
+		for i := range images {
+			images[i].ProductID = productId
+			images[i].Position = int(count) + i
+			if err := tx.Create(&images[i]).Error; err != nil {
+				log.Println("Failed to add product image:", err)
+				return err
+			}
+		}
+		return syncProductImage(tx, productId)
+	})
+}
+
+// ReorderProductImages puts the product's images in the order of imageIds,
+// which must name each of them once.
+func ReorderProductImages(ctx context.Context, db *gorm.DB, productId int64, imageIds []int64) ([]models.ProductImage, error) {
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		images, err := GetProductImages(ctx, tx, productId)
+		if err != nil {
+			return err
+		}
+		if len(images) != len(imageIds) {
+			return ErrImageOrderNotValid
+		}
+		position := make(map[int64]int, len(imageIds))
+		for i, id := range imageIds {
+			if _, seen := position[id]; seen {
+				return ErrImageOrderNotValid
+			}
+			position[id] = i
+		}
+		for _, image := range images {
+			i, ok := position[image.ID]
+			if !ok {
+				return ErrImageOrderNotValid
+			}
+			if err := tx.Model(&image).Update("position", i).Error; err != nil {
+				return err
+			}
+		}
+		return syncProductImage(tx, productId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetProductImages(ctx, db, productId)
+}
+
+// DeleteProductImage removes an image from a product and returns it so the
+// caller can delete its stored variants.
+func DeleteProductImage(ctx context.Context, db *gorm.DB, productId int64, imageId int64) (*models.ProductImage, error) {
+	var image *models.ProductImage
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if image, err = GetProductImage(ctx, tx, productId, imageId); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(image).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ProductImage{}).Where("product_id = ? AND position > ?", productId, image.Position).
+			Update("position", gorm.Expr("position - 1")).Error; err != nil {
+			return err
+		}
+		return syncProductImage(tx, productId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// syncProductImage points Product.Image, which carts and orders copy, at the
+// first uploaded image. Products without uploads keep an external image URL.
+func syncProductImage(tx *gorm.DB, productId int64) error {
+	var first models.ProductImage
+	err := tx.Where("product_id = ?", productId).Order("position, id").First(&first).Error
+	if err == gorm.ErrRecordNotFound {
+		return tx.Model(&models.Product{}).Where("id = ? AND image LIKE ?", productId, fmt.Sprintf("/products/%d/images/%%", productId)).
+			Update("image", "").Error
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Model(&models.Product{}).Where("id = ?", productId).Update("image", ProductImageURL(&first, "medium")).Error
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrOrderIdIsNotValid       = errors.New("order id is not valid")
+	ErrCantFindOrder           = errors.New("can't find order")
+	ErrOrderStatusNotValid     = errors.New("order status is not valid")
+	ErrOrderNotPaid            = errors.New("order has not been paid yet")
+	ErrOrderNotShippable       = errors.New("digital orders are not shipped")
+	ErrOrderTransitionNotValid = errors.New("order can't move to that status")
+)
+
+// SnapshotAddress copies an address book entry into the form stored on orders.
+func SnapshotAddress(address *models.Address) models.AddressSnapshot {
+	return models.AddressSnapshot{
+		RecipientName: address.RecipientName,
+		Phone:         address.Phone,
+		Street:        address.Street,
+		City:          address.City,
+		State:         address.State,
+		PostalCode:    address.PostalCode,
+		Country:       address.Country,
+	}
+}
+
+// snapshotOrderItem builds an order line from the product as it is right now.
+func snapshotOrderItem(product *models.Product, quantity int, unitPrice float64) models.OrderItem {
+	return models.OrderItem{
+		ProductID:   product.ID,
+		ProductName: product.Name,
+		SKU:         product.SKU,
+		Image:       product.Image,
+		Quantity:    quantity,
+		Price:       unitPrice,
+		IsDigital:   product.IsDigital,
+		// Every unit bought adds to the download allowance.
+		DownloadLimit: product.DownloadLimit * quantity,
+	}
+}
+
+// preloadOrderItems loads an order's items with bundle components nested
+// under their bundle item.
+func preloadOrderItems(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items", "parent_item_id IS NULL").Preload("Items.Components")
+}
+
+// GetOrders lists the user's orders with their items, newest first. Only the
+// snapshot columns are read; products and addresses are never joined in.
+func GetOrders(ctx context.Context, db *gorm.DB, userId int64) ([]models.Order, error) {
+	if userId <= 0 {
+		return nil, ErrUserIdIsNotValid
+	}
+	var orders []models.Order
+	if err := preloadOrderItems(db.WithContext(ctx)).Where("user_id = ?", userId).Order("id DESC").Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// GetOrder loads one of the user's orders with its items.
+func GetOrder(ctx context.Context, db *gorm.DB, userId int64, orderId int64) (*models.Order, error) {
+	if userId <= 0 {
+		return nil, ErrUserIdIsNotValid
+	}
+	if orderId <= 0 {
+		return nil, ErrOrderIdIsNotValid
+	}
+	var order models.Order
+	if err := preloadOrderItems(db.WithContext(ctx)).First(&order, "id = ? AND user_id = ?", orderId, userId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindOrder
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// orderTransitions lists the statuses an admin can move an order to from
+// each status. Orders awaiting payment are handled separately and delivered
+// or cancelled orders are final.
+var orderTransitions = map[string][]string{
+	models.OrderStatusOrdered: {models.OrderStatusShipped, models.OrderStatusDelivered, models.OrderStatusCancelled},
+	models.OrderStatusShipped: {models.OrderStatusDelivered},
+}
+
+// UpdateOrderStatus moves an order to a new status, e.g. when it is shipped
+// or delivered, following orderTransitions. Orders awaiting payment can only
+// be cancelled, which releases their stock reservations. Cancelling a paid
+// order returns its stock to the warehouses it was taken from and refunds its
+// payment. Digital orders are never shipped.
+func UpdateOrderStatus(ctx context.Context, db *gorm.DB, orderId int64, status string) (*models.Order, error) {
+	switch status {
+	case models.OrderStatusOrdered, models.OrderStatusShipped, models.OrderStatusDelivered, models.OrderStatusCancelled:
+	default:
+		return nil, ErrOrderStatusNotValid
+	}
+	var order models.Order
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCantFindOrder
+			}
+			return err
+		}
+		if order.Digital && status == models.OrderStatusShipped {
+			return ErrOrderNotShippable
+		}
+		if order.OrderStatus == models.OrderStatusPendingPayment {
+			if status != models.OrderStatusCancelled {
+				return ErrOrderNotPaid
+			}
+			return releaseReservations(tx, []int64{order.ID})
+		}
+		if !slices.Contains(orderTransitions[order.OrderStatus], status) {
+			return ErrOrderTransitionNotValid
+		}
+		if err := tx.Model(&order).Update("order_status", status).Error; err != nil {
+			return err
+		}
+		if status == models.OrderStatusCancelled {
+			return refundOrder(tx, order.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	order.OrderStatus = status
+	return &order, nil
+}
+
+// refundOrder books a return for every unit a cancelled order took from a
+// warehouse and marks its captured payment refunded. Units still on
+// backorder were never taken, and stop counting as outstanding once the
+// order is cancelled. It must run inside a transaction, after the order's
+// status has changed so the returned stock isn't given back to it.
+func refundOrder(tx *gorm.DB, orderId int64) error {
+	var taken []struct {
+		WarehouseID int64
+		ProductID   int64
+		Units       int
+	}
+	if err := tx.Model(&models.StockMovement{}).
+		Select("warehouse_id, product_id, -SUM(quantity) AS units").
+		Where("order_id = ? AND type = ?", orderId, models.StockMovementSale).
+		Group("warehouse_id, product_id").
+		Order("warehouse_id, product_id").
+		Scan(&taken).Error; err != nil {
+		return err
+	}
+	for _, line := range taken {
+		if line.Units <= 0 {
+			continue
+		}
+		if err := recordMovement(tx, &models.StockMovement{
+			WarehouseID: line.WarehouseID,
+			ProductID:   line.ProductID,
+			Quantity:    line.Units,
+			Type:        models.StockMovementReturn,
+			Reason:      "order cancelled",
+			OrderID:     &orderId,
+		}); err != nil {
+			return err
+		}
+	}
+	return tx.Model(&models.Payment{}).
+		Where("order_id = ? AND status = ?", orderId, models.PaymentStatusCaptured).
+		Update("status", models.PaymentStatusRefunded).Error
+}
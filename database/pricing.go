@@ -0,0 +1,351 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LowestPriceWindow is how far back the lowest previous price of a product
+// is looked up, as price reduction rules require.
+const LowestPriceWindow = 30 * 24 * time.Hour
+
+var (
+	ErrPriceNotValid         = errors.New("price can't be negative")
+	ErrPriceScheduleNotValid = errors.New("sale prices need a non-negative price and an end after both the start and now")
+	ErrPriceScheduleOverlaps = errors.New("product already has a sale price in this period")
+	ErrPriceScheduleFinished = errors.New("sale price has already ended or was cancelled")
+	ErrCantFindPriceSchedule = errors.New("can't find price schedule")
+	ErrBundlePriceIsComputed = errors.New("discount bundles are priced from their components")
+)
+
+// PriceTimeline is a product's price history and sale prices, with the
+// lowest price it had in the LowestPriceWindow before its current price.
+type PriceTimeline struct {
+	ProductID      int64                  `json:"product_id"`
+	Price          float64                `json:"price"`
+	CompareAtPrice *float64               `json:"compare_at_price"`
+	LowestPrice    float64                `json:"lowest_price_30_days"`
+	History        []models.PriceChange   `json:"history"`
+	Schedules      []models.PriceSchedule `json:"schedules"`
+}
+
+func recordPriceChange(ctx context.Context, db *gorm.DB, productId int64, oldPrice, newPrice float64, reason string, changedBy int64) error {
+	change := models.PriceChange{ProductID: productId, OldPrice: oldPrice, NewPrice: newPrice, Reason: reason, ChangedBy: changedBy}
+	return db.WithContext(ctx).Create(&change).Error
+}
+
+// RecordInitialPrice starts the price history of a newly created product.
+func RecordInitialPrice(ctx context.Context, db *gorm.DB, product *models.Product, changedBy int64) error {
+	return recordPriceChange(ctx, db, product.ID, 0, product.Price, models.PriceChangeCreated, changedBy)
+}
+
+// setProductPrice updates the price and writes it to the history. It
+// doesn't re-price bundles; ChangeProductPrice does.
+func setProductPrice(ctx context.Context, db *gorm.DB, product *models.Product, price float64, reason string, changedBy int64) error {
+	if price == product.Price {
+		return nil
+	}
+	if err := db.WithContext(ctx).Model(product).Update("price", price).Error; err != nil {
+		return err
+	}
+	if err := recordPriceChange(ctx, db, product.ID, product.Price, price, reason, changedBy); err != nil {
+		return err
+	}
+	product.Price = price
+	return nil
+}
+
+// ChangeProductPrice is the one way to change the price of an existing
+// product: it keeps the price history and re-prices the discount bundles
+// the product is part of.
+func ChangeProductPrice(ctx context.Context, db *gorm.DB, productId int64, price float64, reason string, changedBy int64) (*models.Product, error) {
+	if price < 0 {
+		return nil, ErrPriceNotValid
+	}
+	var product models.Product
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCanNotFindProduct
+			}
+			return err
+		}
+		if price == product.Price {
+			return nil
+		}
+		if err := setProductPrice(ctx, tx, &product, price, reason, changedBy); err != nil {
+			return err
+		}
+		return RefreshBundlePrices(ctx, tx, product.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// LowestPrice is the lowest price the product had between since and until.
+func LowestPrice(ctx context.Context, db *gorm.DB, product *models.Product, since, until time.Time) (float64, error) {
+	var before models.PriceChange
+	err := db.WithContext(ctx).Where("product_id = ? AND created_at <= ?", product.ID, since).
+		Order("created_at DESC, id DESC").First(&before).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return 0, err
+	}
+	var changes []models.PriceChange
+	if err := db.WithContext(ctx).Where("product_id = ? AND created_at > ? AND created_at < ?", product.ID, since, until).
+		Order("created_at, id").Find(&changes).Error; err != nil {
+		return 0, err
+	}
+	var prices []float64
+	switch {
+	case before.ID != 0:
+		prices = append(prices, before.NewPrice)
+	case len(changes) > 0 && changes[0].Reason != models.PriceChangeCreated:
+		// The product is older than its history.
+		prices = append(prices, changes[0].OldPrice)
+	case len(changes) == 0:
+		// Nothing changed in the window, so the price now is the price then.
+		prices = append(prices, product.Price)
+	}
+	for _, change := range changes {
+		prices = append(prices, change.NewPrice)
+	}
+	if len(prices) == 0 {
+		return product.Price, nil
+	}
+	lowest := prices[0]
+	for _, price := range prices[1:] {
+		lowest = min(lowest, price)
+	}
+	return lowest, nil
+}
+
+// GetPriceTimeline returns the product's price history, newest first, its
+// sale prices and the lowest price in the LowestPriceWindow before the
+// running sale, or before now when there is none.
+func GetPriceTimeline(ctx context.Context, db *gorm.DB, productId int64) (*PriceTimeline, error) {
+	var product models.Product
+	if err := db.WithContext(ctx).First(&product, productId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCanNotFindProduct
+		}
+		return nil, err
+	}
+	timeline := PriceTimeline{ProductID: product.ID, Price: product.Price, CompareAtPrice: product.CompareAtPrice}
+	if err := db.WithContext(ctx).Where("product_id = ?", productId).Order("created_at DESC, id DESC").
+		Find(&timeline.History).Error; err != nil {
+		return nil, err
+	}
+	if err := db.WithContext(ctx).Where("product_id = ?", productId).Order("starts_at DESC").
+		Find(&timeline.Schedules).Error; err != nil {
+		return nil, err
+	}
+	reference := time.Now()
+	for _, schedule := range timeline.Schedules {
+		if schedule.Status == models.PriceScheduleStatusActive {
+			reference = schedule.StartsAt
+		}
+	}
+	lowest, err := LowestPrice(ctx, db, &product, reference.Add(-LowestPriceWindow), reference)
+	if err != nil {
+		return nil, err
+	}
+	timeline.LowestPrice = lowest
+	return &timeline, nil
+}
+
+// CreatePriceSchedule plans a sale price for the product. ApplyPriceSchedules
+// puts it into effect once it starts.
+func CreatePriceSchedule(ctx context.Context, db *gorm.DB, schedule *models.PriceSchedule) error {
+	if schedule.Price < 0 || !schedule.EndsAt.After(schedule.StartsAt) || !schedule.EndsAt.After(time.Now()) {
+		return ErrPriceScheduleNotValid
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, schedule.ProductID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCanNotFindProduct
+			}
+			return err
+		}
+		if product.IsBundle && product.BundlePricing == models.BundlePricingDiscount {
+			return ErrBundlePriceIsComputed
+		}
+		var overlapping int64
+		if err := tx.Model(&models.PriceSchedule{}).
+			Where("product_id = ? AND status IN ?", product.ID, []string{models.PriceScheduleStatusPending, models.PriceScheduleStatusActive}).
+			Where("starts_at < ? AND ends_at > ?", schedule.EndsAt, schedule.StartsAt).
+			Count(&overlapping).Error; err != nil {
+			return err
+		}
+		if overlapping > 0 {
+			return ErrPriceScheduleOverlaps
+		}
+		schedule.ID = 0
+		schedule.RegularPrice = 0
+		schedule.Status = models.PriceScheduleStatusPending
+		return tx.Create(schedule).Error
+	})
+}
+
+// CancelPriceSchedule drops a sale price that hasn't started, or ends a
+// running one now.
+func CancelPriceSchedule(ctx context.Context, db *gorm.DB, scheduleId int64, cancelledBy int64) (*models.PriceSchedule, error) {
+	var schedule models.PriceSchedule
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, scheduleId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCantFindPriceSchedule
+			}
+			return err
+		}
+		switch schedule.Status {
+		case models.PriceScheduleStatusPending:
+			schedule.Status = models.PriceScheduleStatusCancelled
+			return tx.Model(&schedule).Update("status", schedule.Status).Error
+		case models.PriceScheduleStatusActive:
+			return endSale(ctx, tx, &schedule, models.PriceScheduleStatusCancelled, cancelledBy)
+		default:
+			return ErrPriceScheduleFinished
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// startSale puts the sale price into effect. The compare-at price shown
+// next to it is the lowest price in the LowestPriceWindow before the sale,
+// and none when the sale is no lower than that.
+func startSale(ctx context.Context, tx *gorm.DB, schedule *models.PriceSchedule) error {
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, schedule.ProductID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// The product was deleted; its sale can't run.
+			schedule.Status = models.PriceScheduleStatusCancelled
+			return tx.Model(schedule).Update("status", schedule.Status).Error
+		}
+		return err
+	}
+	now := time.Now()
+	lowest, err := LowestPrice(ctx, tx, &product, now.Add(-LowestPriceWindow), now)
+	if err != nil {
+		return err
+	}
+	schedule.RegularPrice = product.Price
+	schedule.RegularCompareAtPrice = product.CompareAtPrice
+	schedule.CompareAtPrice = nil
+	if lowest > schedule.Price {
+		schedule.CompareAtPrice = &lowest
+	}
+	schedule.Status = models.PriceScheduleStatusActive
+	if err := tx.Model(schedule).Select("RegularPrice", "RegularCompareAtPrice", "CompareAtPrice", "Status").Updates(schedule).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&product).Update("compare_at_price", schedule.CompareAtPrice).Error; err != nil {
+		return err
+	}
+	if err := setProductPrice(ctx, tx, &product, schedule.Price, models.PriceChangeSaleStart, 0); err != nil {
+		return err
+	}
+	return RefreshBundlePrices(ctx, tx, product.ID)
+}
+
+// endSale restores the regular price and compare-at price, each unless it
+// was changed by hand during the sale.
+func endSale(ctx context.Context, tx *gorm.DB, schedule *models.PriceSchedule, status string, changedBy int64) error {
+	schedule.Status = status
+	if err := tx.Model(schedule).Update("status", status).Error; err != nil {
+		return err
+	}
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, schedule.ProductID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+	if sameCompareAtPrice(product.CompareAtPrice, schedule.CompareAtPrice) {
+		if err := tx.Model(&product).Update("compare_at_price", schedule.RegularCompareAtPrice).Error; err != nil {
+			return err
+		}
+	}
+	if product.Price != schedule.Price {
+		return nil
+	}
+	if err := setProductPrice(ctx, tx, &product, schedule.RegularPrice, models.PriceChangeSaleEnd, changedBy); err != nil {
+		return err
+	}
+	return RefreshBundlePrices(ctx, tx, product.ID)
+}
+
+func sameCompareAtPrice(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// lockPriceSchedule reloads the schedule for update and reports whether it
+// still has the given status, as an admin may have cancelled it meanwhile.
+func lockPriceSchedule(tx *gorm.DB, schedule *models.PriceSchedule, status string) (bool, error) {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(schedule, schedule.ID).Error; err != nil {
+		return false, err
+	}
+	return schedule.Status == status, nil
+}
+
+// ApplyPriceSchedules ends the sale prices that are over and starts the ones
+// that are due. Sales that were missed entirely are marked ended.
+func ApplyPriceSchedules(ctx context.Context, db *gorm.DB) error {
+	now := time.Now()
+	var ending []models.PriceSchedule
+	if err := db.WithContext(ctx).Where("status = ? AND ends_at <= ?", models.PriceScheduleStatusActive, now).
+		Find(&ending).Error; err != nil {
+		return err
+	}
+	for i := range ending {
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if ok, err := lockPriceSchedule(tx, &ending[i], models.PriceScheduleStatusActive); !ok {
+				return err
+			}
+			return endSale(ctx, tx, &ending[i], models.PriceScheduleStatusEnded, 0)
+		})
+		if err != nil {
+			log.Println("Failed to end sale price:", err)
+		}
+	}
+	if err := db.WithContext(ctx).Model(&models.PriceSchedule{}).
+		Where("status = ? AND ends_at <= ?", models.PriceScheduleStatusPending, now).
+		Update("status", models.PriceScheduleStatusEnded).Error; err != nil {
+		return err
+	}
+	var starting []models.PriceSchedule
+	if err := db.WithContext(ctx).Where("status = ? AND starts_at <= ?", models.PriceScheduleStatusPending, now).
+		Order("starts_at").Find(&starting).Error; err != nil {
+		return err
+	}
+	for i := range starting {
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if ok, err := lockPriceSchedule(tx, &starting[i], models.PriceScheduleStatusPending); !ok {
+				return err
+			}
+			return startSale(ctx, tx, &starting[i])
+		})
+		if err != nil {
+			log.Println("Failed to start sale price:", err)
+		}
+	}
+	if len(ending) > 0 || len(starting) > 0 {
+		log.Printf("Ended %d and started %d sale prices", len(ending), len(starting))
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+)
+
+var ErrInitialStockNotBooked = errors.New("product created but its stock could not be booked")
+
+// ProductQuery narrows a product listing. Zero fields don't filter.
+type ProductQuery struct {
+	Name       string
+	CategoryID int64
+	Filters    []AttributeFilter
+}
+
+// ProductChanges are the edits of an admin product update. Columns are
+// plain column updates; the price goes through the price history, a new
+// stock total is booked at the default warehouse and attributes are only
+// replaced when SetAttributes is true.
+type ProductChanges struct {
+	Columns       map[string]interface{}
+	Price         *float64
+	Quantity      *int
+	Attributes    map[string]interface{}
+	SetAttributes bool
+}
+
+// GetProduct loads a product with its bundle components, attributes and
+// images. With publishedOnly, drafts and archived products aren't found.
+func GetProduct(ctx context.Context, db *gorm.DB, productId int64, publishedOnly bool) (*models.Product, error) {
+	query := db.WithContext(ctx)
+	if publishedOnly {
+		query = query.Scopes(Published)
+	}
+	var product models.Product
+	err := query.Preload("Components.Component").Preload("Attributes.Definition").Preload("Images", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).First(&product, productId).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCanNotFindProduct
+		}
+		return nil, err
+	}
+	return &product, nil
+}
+
+// GetProducts lists every product, in any status, for admins.
+func GetProducts(ctx context.Context, db *gorm.DB) ([]models.Product, error) {
+	var products []models.Product
+	err := db.WithContext(ctx).Find(&products).Error
+	return products, err
+}
+
+// SearchProducts lists the published products matching the query, with
+// attribute facet counts of the matches.
+func SearchProducts(ctx context.Context, db *gorm.DB, query ProductQuery) ([]models.Product, []AttributeFacet, error) {
+	products := db.WithContext(ctx).Model(&models.Product{}).Scopes(Published)
+	if query.Name != "" {
+		products = products.Where("name LIKE ?", "%"+query.Name+"%")
+	}
+	if query.CategoryID != 0 {
+		products = products.Where("products.category_id = ?", query.CategoryID)
+	}
+	products, err := FilterByAttributes(ctx, db, products, query.Filters)
+	if err != nil {
+		return nil, nil, err
+	}
+	var list []models.Product
+	if err := products.Session(&gorm.Session{}).Find(&list).Error; err != nil {
+		return nil, nil, err
+	}
+	facets, err := AttributeFacets(ctx, db, products)
+	if err != nil {
+		return nil, nil, err
+	}
+	return list, facets, nil
+}
+
+// CreateProduct adds a product with its attribute values. New products are
+// drafts unless product.Status asks for another status, and their stock
+// enters the ledger as a receipt at the default warehouse. When only the
+// receipt fails, the product exists and ErrInitialStockNotBooked is
+// returned.
+func CreateProduct(ctx context.Context, db *gorm.DB, product *models.Product, attributes map[string]interface{}, createdBy int64) error {
+	status := product.Status
+	product.Status = models.ProductStatusDraft
+	quantity := product.Quantity
+	product.Quantity = 0
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		if err := RecordInitialPrice(ctx, tx, product, createdBy); err != nil {
+			return err
+		}
+		values, err := SetProductAttributes(ctx, tx, product.ID, attributes)
+		if err != nil {
+			return err
+		}
+		product.Attributes = values
+		if status != "" && status != models.ProductStatusDraft {
+			updated, err := SetProductStatus(ctx, tx, product.ID, status, product.PublishAt, product.UnpublishAt)
+			if err != nil {
+				return err
+			}
+			product.Status, product.PublishAt, product.UnpublishAt = updated.Status, updated.PublishAt, updated.UnpublishAt
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Digital products have no stock to book.
+	if quantity > 0 && !product.IsDigital {
+		warehouse, err := DefaultWarehouse(ctx, db)
+		if err == nil {
+			_, err = AdjustStock(ctx, db, createdBy, warehouse.ID, product.ID, quantity, models.StockMovementReceipt, "initial stock")
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInitialStockNotBooked, err)
+		}
+		product.Quantity = quantity
+	}
+	return nil
+}
+
+// UpdateProduct applies an admin's changes to a product in one transaction.
+func UpdateProduct(ctx context.Context, db *gorm.DB, productId int64, changes ProductChanges, changedBy int64) (*models.Product, error) {
+	var product models.Product
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&product, productId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCanNotFindProduct
+			}
+			return err
+		}
+		if len(changes.Columns) > 0 {
+			if err := tx.Model(&product).Updates(changes.Columns).Error; err != nil {
+				return err
+			}
+		}
+		// Price changes go through the price history and re-price bundles.
+		if changes.Price != nil {
+			updated, err := ChangeProductPrice(ctx, tx, product.ID, *changes.Price, models.PriceChangeManual, changedBy)
+			if err != nil {
+				return err
+			}
+			product.Price = updated.Price
+		}
+		if changes.SetAttributes {
+			attributes, err := SetProductAttributes(ctx, tx, product.ID, changes.Attributes)
+			if err != nil {
+				return err
+			}
+			product.Attributes = attributes
+		}
+		if changes.Quantity != nil {
+			if err := SetProductStock(ctx, tx, changedBy, product.ID, *changes.Quantity, "quantity set on product"); err != nil {
+				return err
+			}
+			// Incoming stock may have gone to backorders right away.
+			if err := tx.Select("quantity").First(&product, product.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// DeleteProduct moves a product to the trash, where it stays until
+// PurgeDeletedProducts removes it.
+func DeleteProduct(ctx context.Context, db *gorm.DB, productId int64) error {
+	result := db.WithContext(ctx).Delete(&models.Product{}, productId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCanNotFindProduct
+	}
+	return nil
+}
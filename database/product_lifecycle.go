@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/storage"
+	"gorm.io/gorm"
+)
+
+// ProductTrashRetention is how long deleted products stay in the trash,
+// where they can be restored, before they are purged for good.
+const ProductTrashRetention = 30 * 24 * time.Hour
+
+var (
+	ErrProductStatusNotValid   = errors.New("product status must be draft, scheduled, published or archived")
+	ErrProductScheduleNotValid = errors.New("scheduled products need a future publish time, and unpublishing must come after publishing")
+	ErrCantFindDeletedProduct  = errors.New("can't find product in trash")
+)
+
+// Published limits a products query to the products customers may see and
+// buy.
+func Published(db *gorm.DB) *gorm.DB {
+	return db.Where("products.status = ?", models.ProductStatusPublished)
+}
+
+// SetProductStatus moves a product through its lifecycle. A scheduled
+// product goes live at publishAt; a published one with unpublishAt is
+// archived then. Drafts and archived products drop their schedule.
+func SetProductStatus(ctx context.Context, db *gorm.DB, productId int64, status string, publishAt *time.Time, unpublishAt *time.Time) (*models.Product, error) {
+	now := time.Now()
+	switch status {
+	case models.ProductStatusDraft, models.ProductStatusArchived:
+		publishAt, unpublishAt = nil, nil
+	case models.ProductStatusScheduled:
+		if publishAt == nil || !publishAt.After(now) || unpublishAt != nil && !unpublishAt.After(*publishAt) {
+			return nil, ErrProductScheduleNotValid
+		}
+	case models.ProductStatusPublished:
+		if unpublishAt != nil && !unpublishAt.After(now) {
+			return nil, ErrProductScheduleNotValid
+		}
+		publishAt = &now
+	default:
+		return nil, ErrProductStatusNotValid
+	}
+	var product models.Product
+	if err := db.WithContext(ctx).First(&product, productId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCanNotFindProduct
+		}
+		return nil, err
+	}
+	product.Status, product.PublishAt, product.UnpublishAt = status, publishAt, unpublishAt
+	if err := db.WithContext(ctx).Model(&product).Select("Status", "PublishAt", "UnpublishAt").Updates(&product).Error; err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// ApplyProductSchedules publishes scheduled products whose time has come
+// and archives published products past their unpublish time.
+func ApplyProductSchedules(ctx context.Context, db *gorm.DB) error {
+	now := time.Now()
+	published := db.WithContext(ctx).Model(&models.Product{}).
+		Where("status = ? AND publish_at <= ?", models.ProductStatusScheduled, now).
+		Update("status", models.ProductStatusPublished)
+	if published.Error != nil {
+		return published.Error
+	}
+	archived := db.WithContext(ctx).Model(&models.Product{}).
+		Where("status = ? AND unpublish_at <= ?", models.ProductStatusPublished, now).
+		Updates(map[string]interface{}{"status": models.ProductStatusArchived, "unpublish_at": nil})
+	if archived.Error != nil {
+		return archived.Error
+	}
+	if published.RowsAffected > 0 || archived.RowsAffected > 0 {
+		log.Printf("Published %d and archived %d scheduled products", published.RowsAffected, archived.RowsAffected)
+	}
+	return nil
+}
+
+// GetDeletedProducts lists the trash, most recently deleted first.
+func GetDeletedProducts(ctx context.Context, db *gorm.DB) ([]models.Product, error) {
+	var products []models.Product
+	err := db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&products).Error
+	return products, err
+}
+
+// RestoreProduct takes a product out of the trash with the status it had.
+func RestoreProduct(ctx context.Context, db *gorm.DB, productId int64) (*models.Product, error) {
+	var product models.Product
+	if err := db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").First(&product, productId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindDeletedProduct
+		}
+		return nil, err
+	}
+	if err := db.WithContext(ctx).Unscoped().Model(&product).Update("deleted_at", nil).Error; err != nil {
+		return nil, err
+	}
+	product.DeletedAt = gorm.DeletedAt{}
+	return &product, nil
+}
+
+// PurgeDeletedProducts removes products deleted before the cutoff together
+// with their files, images, stock levels and cart or wishlist lines. The
+// stock ledger and price history are kept for auditing and keep the purged
+// product's ID. Products that were ordered, reviewed or are still part of a
+// bundle stay in the trash, as those records refer to them.
+func PurgeDeletedProducts(ctx context.Context, db *gorm.DB, store storage.BlobStore, before time.Time) error {
+	var productIds []int64
+	err := db.WithContext(ctx).Unscoped().Model(&models.Product{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Where("NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.product_id = products.id)").
+		Where("NOT EXISTS (SELECT 1 FROM reservations WHERE reservations.product_id = products.id)").
+		Where("NOT EXISTS (SELECT 1 FROM reviews WHERE reviews.product_id = products.id)").
+		Where("NOT EXISTS (SELECT 1 FROM bundle_components JOIN products bundles ON bundles.id = bundle_components.bundle_id "+
+			"WHERE bundle_components.component_id = products.id AND bundles.deleted_at IS NULL)").
+		Pluck("id", &productIds).Error
+	if err != nil || len(productIds) == 0 {
+		return err
+	}
+	var keys []string
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var files []models.ProductFile
+		if err := tx.Unscoped().Where("product_id IN ?", productIds).Find(&files).Error; err != nil {
+			return err
+		}
+		for _, file := range files {
+			keys = append(keys, file.StorageKey)
+		}
+		var images []models.ProductImage
+		if err := tx.Unscoped().Where("product_id IN ?", productIds).Find(&images).Error; err != nil {
+			return err
+		}
+		for _, image := range images {
+			keys = append(keys, image.OriginalKey, image.MediumKey, image.ThumbnailKey)
+		}
+		for _, dependent := range []struct {
+			model  interface{}
+			column string
+		}{
+			{&models.ProductFile{}, "product_id"},
+			{&models.ProductImage{}, "product_id"},
+			{&models.ProductAttribute{}, "product_id"},
+			{&models.BundleComponent{}, "bundle_id"},
+			{&models.BundleComponent{}, "component_id"},
+			{&models.UserProduct{}, "product_id"},
+			{&models.GuestCartItem{}, "product_id"},
+			{&models.WishlistItem{}, "product_id"},
+			{&models.StockLevel{}, "product_id"},
+			{&models.PriceSchedule{}, "product_id"},
+		} {
+			if err := tx.Unscoped().Where(dependent.column+" IN ?", productIds).Delete(dependent.model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Where("id IN ?", productIds).Delete(&models.Product{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			log.Println("Failed to delete stored file of purged product:", err)
+		}
+	}
+	log.Printf("Purged %d products deleted before %s", len(productIds), before.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,401 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/inventory"
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReservationLifetime is how long checkout holds stock for an order that
+// has not been paid yet.
+const ReservationLifetime = 15 * time.Minute
+
+var (
+	ErrOrderNotAwaitingPayment = errors.New("order is not awaiting payment")
+	ErrReservationExpired      = errors.New("stock reservation expired, please checkout again")
+)
+
+// ReservedQuantities sums the active reservations of each product.
+func ReservedQuantities(ctx context.Context, db *gorm.DB, productIds []int64) (map[int64]int, error) {
+	reserved := make(map[int64]int, len(productIds))
+	if len(productIds) == 0 {
+		return reserved, nil
+	}
+	var rows []struct {
+		ProductID int64
+		Quantity  int
+	}
+	err := db.WithContext(ctx).Model(&models.Reservation{}).
+		Select("product_id, SUM(quantity) AS quantity").
+		Where("product_id IN ? AND status = ? AND expires_at > ?", productIds, models.ReservationStatusActive, time.Now()).
+		Group("product_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		reserved[row.ProductID] = row.Quantity
+	}
+	return reserved, nil
+}
+
+// AvailableQuantity is the product's on-hand stock minus what active
+// reservations hold. For bundles it is the number that can be assembled
+// from their components; digital products never run out.
+func AvailableQuantity(ctx context.Context, db *gorm.DB, product *models.Product) (int, error) {
+	if product.IsBundle {
+		available, err := bundleAvailability(ctx, db, []int64{product.ID})
+		if err != nil {
+			return 0, err
+		}
+		return available[product.ID], nil
+	}
+	if product.IsDigital {
+		return unlimitedStock, nil
+	}
+	reserved, err := ReservedQuantities(ctx, db, []int64{product.ID})
+	if err != nil {
+		return 0, err
+	}
+	return product.Quantity - reserved[product.ID], nil
+}
+
+// reserveStock locks the products of the order lines, checks they can still
+// be sold and reserves the units in stock for the order. Units beyond the
+// stock are backordered, or all of them for pre-orders, as the product's
+// policy allows; digital products need no stock. Bundles are reserved
+// through their components, which are added to the order as child items. It
+// must run inside the checkout transaction; locking the product rows
+// serializes concurrent checkouts.
+func reserveStock(ctx context.Context, tx *gorm.DB, order *models.Order, lines []cartEntry) error {
+	productIds := make([]int64, len(lines))
+	for i, line := range lines {
+		productIds[i] = line.ProductID
+	}
+	components, err := bundleComponents(tx, productIds)
+	if err != nil {
+		return err
+	}
+	lockIds := append([]int64(nil), productIds...)
+	for _, bundle := range components {
+		for _, component := range bundle {
+			lockIds = append(lockIds, component.ComponentID)
+		}
+	}
+	var products []models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", lockIds).Order("id").Find(&products).Error; err != nil {
+		return err
+	}
+	byId := make(map[int64]*models.Product, len(products))
+	for i := range products {
+		byId[products[i].ID] = &products[i]
+	}
+	reserved, err := ReservedQuantities(ctx, tx, lockIds)
+	if err != nil {
+		return err
+	}
+	outstanding, err := OutstandingBackorders(ctx, tx, productIds)
+	if err != nil {
+		return err
+	}
+	// available is what is left of each product after the lines before.
+	available := func(product *models.Product) int {
+		if product.IsDigital {
+			return unlimitedStock
+		}
+		return product.Quantity - reserved[product.ID]
+	}
+	now := time.Now()
+	expiresAt := now.Add(ReservationLifetime)
+	var items []models.OrderItem
+	var parents []int // index of each item's bundle item, or -1
+	for i, line := range lines {
+		product, ok := byId[line.ProductID]
+		if !ok || product.Status != models.ProductStatusPublished {
+			return ErrCanNotFindProduct
+		}
+		preOrder := isPreOrder(product, now)
+		if i == 0 {
+			order.PreOrder = preOrder
+		} else if preOrder != order.PreOrder {
+			return ErrPreOrderMixedCart
+		}
+		orderItem := snapshotOrderItem(product, line.Quantity, product.Price)
+		orderItem.UserID = order.UserID
+		order.TotalPrice += product.Price * float64(line.Quantity)
+
+		if product.IsBundle {
+			assemblable := math.MaxInt32
+			for _, component := range components[product.ID] {
+				assemblable = min(assemblable, max(available(byId[component.ComponentID]), 0)/component.Quantity)
+			}
+			if len(components[product.ID]) == 0 {
+				assemblable = 0
+			}
+			if err := CheckCartQuantity(product, assemblable, line.Quantity); err != nil {
+				return err
+			}
+			orderItem.IsBundle = true
+			items = append(items, orderItem)
+			parents = append(parents, -1)
+			parent := len(items) - 1
+			for _, component := range components[product.ID] {
+				part := byId[component.ComponentID]
+				partItem := snapshotOrderItem(part, component.Quantity*line.Quantity, 0)
+				partItem.UserID = order.UserID
+				items = append(items, partItem)
+				parents = append(parents, parent)
+				reserved[part.ID] += partItem.Quantity
+			}
+			continue
+		}
+
+		free := available(product)
+		if err := CheckCartQuantity(product, sellableQuantity(product, free, outstanding[product.ID], now), line.Quantity); err != nil {
+			return err
+		}
+		orderItem.PreOrder = preOrder
+		switch {
+		case preOrder:
+			if !product.IsDigital {
+				orderItem.Backordered = line.Quantity
+			}
+			if order.ReleaseDate == nil || product.ReleaseDate.After(*order.ReleaseDate) {
+				order.ReleaseDate = product.ReleaseDate
+			}
+		case line.Quantity > free:
+			orderItem.Backordered = line.Quantity - max(free, 0)
+			order.Backordered = true
+		}
+		items = append(items, orderItem)
+		parents = append(parents, -1)
+		reserved[product.ID] += line.Quantity - orderItem.Backordered
+	}
+	order.TotalPrice = roundMoney(order.TotalPrice)
+	order.Digital = true
+	for _, item := range items {
+		if !item.IsBundle && !item.IsDigital {
+			order.Digital = false
+		}
+	}
+	if err := tx.Omit(clause.Associations).Create(order).Error; err != nil {
+		log.Println("Failed to make order:", err)
+		return err
+	}
+	for i := range items {
+		items[i].OrderID = order.ID
+		if parents[i] >= 0 {
+			items[i].ParentItemID = &items[parents[i]].ID
+		}
+		if err := tx.Omit(clause.Associations).Create(&items[i]).Error; err != nil {
+			log.Println("Failed to make order item:", err)
+			return err
+		}
+		inStock := items[i].Quantity - items[i].Backordered
+		if items[i].IsBundle || items[i].IsDigital || inStock == 0 {
+			continue
+		}
+		reservation := models.Reservation{
+			OrderID:   order.ID,
+			ProductID: items[i].ProductID,
+			SKU:       items[i].SKU,
+			Quantity:  inStock,
+			Status:    models.ReservationStatusActive,
+			ExpiresAt: expiresAt,
+		}
+		if err := tx.Create(&reservation).Error; err != nil {
+			log.Println("Failed to reserve stock:", err)
+			return err
+		}
+	}
+	// Nest bundle components under their bundle item, as orders are read.
+	for i := range items {
+		if parents[i] < 0 {
+			order.Items = append(order.Items, items[i])
+		}
+	}
+	for i := range items {
+		if parents[i] >= 0 {
+			for j := range order.Items {
+				if order.Items[j].ID == *items[i].ParentItemID {
+					order.Items[j].Components = append(order.Items[j].Components, items[i])
+				}
+			}
+		}
+	}
+	payment := models.Payment{
+		OrderID:     order.ID,
+		Amount:      order.TotalPrice,
+		PaymentType: order.PaymentMethod,
+		Status:      models.PaymentStatusPending,
+	}
+	if err := tx.Omit(clause.Associations).Create(&payment).Error; err != nil {
+		log.Println("Failed to add payment record:", err)
+		return err
+	}
+	return nil
+}
+
+// CapturePayment marks the payment of a pending order as captured and turns
+// its reservations into sales from the warehouses the strategy picks. It
+// fails when the reservations or, for orders without any, the payment
+// deadline already expired. Pre-orders can be paid from their release date
+// until preOrderWindow after it.
+// Digital items become downloadable right away, and orders of only digital
+// items are delivered. Backordered units are shipped as stock comes in.
+func CapturePayment(ctx context.Context, db *gorm.DB, strategy inventory.Strategy, preOrderWindow time.Duration, userId int64, orderId int64) (*models.Order, error) {
+	if userId <= 0 {
+		return nil, ErrUserIdIsNotValid
+	}
+	if orderId <= 0 {
+		return nil, ErrOrderIdIsNotValid
+	}
+	var order models.Order
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ? AND user_id = ?", orderId, userId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCantFindOrder
+			}
+			return err
+		}
+		if order.OrderStatus != models.OrderStatusPendingPayment {
+			return ErrOrderNotAwaitingPayment
+		}
+		now := time.Now()
+		if order.PreOrder && order.ReleaseDate != nil && now.Before(*order.ReleaseDate) {
+			return ErrPreOrderNotReleased
+		}
+		var reservations []models.Reservation
+		if err := tx.Find(&reservations, "order_id = ?", order.ID).Error; err != nil {
+			return err
+		}
+		if len(reservations) == 0 && paymentOverdue(&order, now, preOrderWindow) {
+			return ErrReservationExpired
+		}
+		lines := make([]inventory.Line, len(reservations))
+		for i, reservation := range reservations {
+			if reservation.Status != models.ReservationStatusActive || !reservation.ExpiresAt.After(now) {
+				return ErrReservationExpired
+			}
+			lines[i] = inventory.Line{ProductID: reservation.ProductID, Quantity: reservation.Quantity}
+		}
+		if len(lines) > 0 {
+			if err := allocateOrder(tx, strategy, order.ID, lines); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Reservation{}).Where("order_id = ?", order.ID).
+			Update("status", models.ReservationStatusConverted).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Payment{}).Where("order_id = ?", order.ID).Updates(map[string]interface{}{
+			"status":      models.PaymentStatusCaptured,
+			"captured_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := fulfillDigitalItems(tx, &order, now); err != nil {
+			return err
+		}
+		order.OrderStatus = models.OrderStatusOrdered
+		if order.Digital {
+			order.OrderStatus = models.OrderStatusDelivered
+		}
+		if err := tx.Model(&order).Update("order_status", order.OrderStatus).Error; err != nil {
+			return err
+		}
+		if !order.Backordered && !order.PreOrder {
+			return nil
+		}
+		// Stock that arrived while the order was unpaid can ship right away.
+		var productIds []int64
+		if err := tx.Model(&models.OrderItem{}).Where("order_id = ? AND backordered > 0", order.ID).
+			Pluck("product_id", &productIds).Error; err != nil {
+			return err
+		}
+		for _, productId := range productIds {
+			if err := fillBackorders(tx, productId, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := preloadOrderItems(db.WithContext(ctx)).First(&order, order.ID).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// releaseReservations frees the active reservations of the orders and
+// cancels those still waiting for payment.
+func releaseReservations(tx *gorm.DB, orderIds []int64) error {
+	if len(orderIds) == 0 {
+		return nil
+	}
+	if err := tx.Model(&models.Reservation{}).
+		Where("order_id IN ? AND status = ?", orderIds, models.ReservationStatusActive).
+		Update("status", models.ReservationStatusReleased).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Payment{}).
+		Where("order_id IN ? AND status = ?", orderIds, models.PaymentStatusPending).
+		Update("status", models.PaymentStatusCancelled).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Order{}).
+		Where("id IN ? AND order_status = ?", orderIds, models.OrderStatusPendingPayment).
+		Update("order_status", models.OrderStatusCancelled).Error
+}
+
+// paymentOverdue reports whether an order awaiting payment has missed its
+// deadline: ReservationLifetime after checkout, or preOrderWindow after the
+// release date for pre-orders. It covers orders that hold no reservations
+// because all their units are backordered, pre-ordered or digital.
+func paymentOverdue(order *models.Order, now time.Time, preOrderWindow time.Duration) bool {
+	deadline := order.CreatedAt.Add(ReservationLifetime)
+	if order.PreOrder && order.ReleaseDate != nil {
+		deadline = order.ReleaseDate.Add(preOrderWindow)
+	}
+	return !deadline.After(now)
+}
+
+// ReleaseExpiredReservations gives the stock of unpaid orders back once their
+// reservations expire and cancels those orders, along with orders without
+// reservations that are past their payment deadline. Pre-orders are due
+// preOrderWindow after their release date. Cancelling them also takes their
+// backordered units out of OutstandingBackorders.
+func ReleaseExpiredReservations(ctx context.Context, db *gorm.DB, preOrderWindow time.Duration) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var orderIds []int64
+		if err := tx.Model(&models.Reservation{}).Distinct("order_id").
+			Where("status = ? AND expires_at <= ?", models.ReservationStatusActive, now).
+			Pluck("order_id", &orderIds).Error; err != nil {
+			return err
+		}
+		var overdue []int64
+		if err := tx.Model(&models.Order{}).
+			Where("order_status = ? AND ((NOT pre_order AND created_at <= ?) OR (pre_order AND release_date <= ?))",
+				models.OrderStatusPendingPayment, now.Add(-ReservationLifetime), now.Add(-preOrderWindow)).
+			Where("NOT EXISTS (SELECT 1 FROM reservations WHERE reservations.order_id = orders.id AND reservations.status = ?)",
+				models.ReservationStatusActive).
+			Pluck("id", &overdue).Error; err != nil {
+			return err
+		}
+		orderIds = append(orderIds, overdue...)
+		if len(orderIds) > 0 {
+			log.Printf("Releasing expired stock reservations of %d orders", len(orderIds))
+		}
+		return releaseReservations(tx, orderIds)
+	})
+}
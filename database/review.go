@@ -0,0 +1,361 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/moderation"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRatingOutOfRange           = errors.New("rating must be between 1 and 5")
+	ErrReviewIdIsNotValid         = errors.New("review id is not valid")
+	ErrCantFindReview             = errors.New("can't find review")
+	ErrReviewAlreadyExists        = errors.New("product already reviewed by user")
+	ErrNotVerifiedPurchaser       = errors.New("only customers who received the product can review it")
+	ErrCantVoteOwnReview          = errors.New("can't vote on own review")
+	ErrAlreadyVotedReview         = errors.New("review already voted helpful")
+	ErrAlreadyReportedReview      = errors.New("review already reported by user")
+	ErrCantReportOwnReview        = errors.New("can't report own review")
+	ErrModerationActionIsNotValid = errors.New("moderation action must be approve, reject or hide")
+	ErrModerationReasonRequired   = errors.New("a reason is required to reject or hide a review")
+)
+
+// HasDeliveredPurchase reports whether the user has a delivered order
+// containing the product.
+func HasDeliveredPurchase(ctx context.Context, db *gorm.DB, userId int64, productId int64) (bool, error) {
+	var count int64
+	err := db.WithContext(ctx).Model(&models.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Where("orders.user_id = ? AND order_items.product_id = ? AND orders.order_status = ?", userId, productId, models.OrderStatusDelivered).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CreateReview adds the user's review of a product they have received.
+func CreateReview(ctx context.Context, db *gorm.DB, userId int64, productId int64, review *models.Review) error {
+	if userId <= 0 {
+		return ErrUserIdIsNotValid
+	}
+	if productId <= 0 {
+		return ErrProductIdIsNotValid
+	}
+	if review.Rating < 1 || review.Rating > 5 {
+		return ErrRatingOutOfRange
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.Product{}, productId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCanNotFindProduct
+			}
+			return err
+		}
+		verified, err := HasDeliveredPurchase(ctx, tx, userId, productId)
+		if err != nil {
+			return err
+		}
+		if !verified {
+			return ErrNotVerifiedPurchaser
+		}
+		var existing int64
+		if err := tx.Model(&models.Review{}).Where("user_id = ? AND product_id = ?", userId, productId).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrReviewAlreadyExists
+		}
+		review.ID = 0
+		review.UserID = userId
+		review.ProductID = productId
+		review.HelpfulCount = 0
+		review.ReportCount = 0
+		if review.Status == "" {
+			review.Status = models.ReviewStatusPending
+		}
+		if err := tx.Omit(clause.Associations).Create(review).Error; err != nil {
+			log.Println("Failed to create review:", err)
+			return err
+		}
+		return RecalculateProductRating(tx, productId)
+	})
+}
+
+// getOwnReview loads a review only if it was written by the user.
+func getOwnReview(tx *gorm.DB, userId int64, reviewId int64) (*models.Review, error) {
+	if reviewId <= 0 {
+		return nil, ErrReviewIdIsNotValid
+	}
+	var review models.Review
+	if err := tx.First(&review, "id = ? AND user_id = ?", reviewId, userId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindReview
+		}
+		return nil, err
+	}
+	return &review, nil
+}
+
+// UpdateReview edits the rating and text of the user's own review. The
+// edited text goes through moderation again, so data carries the new status.
+func UpdateReview(ctx context.Context, db *gorm.DB, userId int64, reviewId int64, data models.Review) (*models.Review, error) {
+	if data.Rating < 1 || data.Rating > 5 {
+		return nil, ErrRatingOutOfRange
+	}
+	var review *models.Review
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		review, err = getOwnReview(tx, userId, reviewId)
+		if err != nil {
+			return err
+		}
+		review.Rating = data.Rating
+		review.Title = data.Title
+		review.Comment = data.Comment
+		review.Status = data.Status
+		review.ModerationReason = data.ModerationReason
+		if review.Status == "" {
+			review.Status = models.ReviewStatusPending
+		}
+		if err := tx.Omit(clause.Associations).Save(review).Error; err != nil {
+			log.Println("Failed to update review:", err)
+			return err
+		}
+		return RecalculateProductRating(tx, review.ProductID)
+	})
+	return review, err
+}
+
+// DeleteReview permanently removes the user's own review and its votes.
+func DeleteReview(ctx context.Context, db *gorm.DB, userId int64, reviewId int64) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		review, err := getOwnReview(tx, userId, reviewId)
+		if err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&models.ReviewVote{}, "review_id = ?", review.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&models.ReviewReport{}, "review_id = ?", review.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(review).Error; err != nil {
+			log.Println("Failed to delete review:", err)
+			return err
+		}
+		return RecalculateProductRating(tx, review.ProductID)
+	})
+}
+
+// VoteReviewHelpful records the user's helpful vote, once per review.
+func VoteReviewHelpful(ctx context.Context, db *gorm.DB, userId int64, reviewId int64) (*models.Review, error) {
+	if userId <= 0 {
+		return nil, ErrUserIdIsNotValid
+	}
+	if reviewId <= 0 {
+		return nil, ErrReviewIdIsNotValid
+	}
+	var review models.Review
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&review, "id = ? AND status = ?", reviewId, models.ReviewStatusApproved).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCantFindReview
+			}
+			return err
+		}
+		if review.UserID == userId {
+			return ErrCantVoteOwnReview
+		}
+		var voted int64
+		if err := tx.Model(&models.ReviewVote{}).Where("review_id = ? AND user_id = ?", reviewId, userId).Count(&voted).Error; err != nil {
+			return err
+		}
+		if voted > 0 {
+			return ErrAlreadyVotedReview
+		}
+		if err := tx.Create(&models.ReviewVote{ReviewID: reviewId, UserID: userId}).Error; err != nil {
+			return err
+		}
+		review.HelpfulCount++
+		return tx.Model(&review).Update("helpful_count", review.HelpfulCount).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// GetProductReviews pages through a product's approved reviews, most
+// helpful first.
+func GetProductReviews(ctx context.Context, db *gorm.DB, productId int64, limit int, offset int) ([]models.Review, error) {
+	if productId <= 0 {
+		return nil, ErrProductIdIsNotValid
+	}
+	var reviews []models.Review
+	err := db.WithContext(ctx).Where("product_id = ? AND status = ?", productId, models.ReviewStatusApproved).
+		Order("helpful_count DESC, id DESC").Limit(limit).Offset(offset).
+		Find(&reviews).Error
+	return reviews, err
+}
+
+// RecalculateProductRating rebuilds the product's rating summary from its
+// approved reviews. Call it inside the transaction that changed the reviews.
+func RecalculateProductRating(tx *gorm.DB, productId int64) error {
+	var rows []struct {
+		Rating int
+		Total  int
+	}
+	if err := tx.Model(&models.Review{}).Select("rating, COUNT(*) AS total").
+		Where("product_id = ? AND status = ?", productId, models.ReviewStatusApproved).Group("rating").Scan(&rows).Error; err != nil {
+		return err
+	}
+	var summary models.RatingSummary
+	sum := 0
+	for _, row := range rows {
+		switch row.Rating {
+		case 1:
+			summary.Star1 = row.Total
+		case 2:
+			summary.Star2 = row.Total
+		case 3:
+			summary.Star3 = row.Total
+		case 4:
+			summary.Star4 = row.Total
+		case 5:
+			summary.Star5 = row.Total
+		}
+		summary.Count += row.Total
+		sum += row.Rating * row.Total
+	}
+	if summary.Count > 0 {
+		summary.Average = math.Round(float64(sum)/float64(summary.Count)*100) / 100
+	}
+	return tx.Model(&models.Product{}).Where("id = ?", productId).Updates(map[string]interface{}{
+		"rating":         int(math.Round(summary.Average)),
+		"rating_average": summary.Average,
+		"rating_count":   summary.Count,
+		"rating_star1":   summary.Star1,
+		"rating_star2":   summary.Star2,
+		"rating_star3":   summary.Star3,
+		"rating_star4":   summary.Star4,
+		"rating_star5":   summary.Star5,
+	}).Error
+}
+
+// ReportReview records a customer's report against a published review. When
+// the rules decide it has been reported too often, the review is pulled from
+// the product page and goes back to the moderation queue.
+func ReportReview(ctx context.Context, db *gorm.DB, userId int64, reviewId int64, reason string, rules moderation.Rules) (*models.Review, error) {
+	if userId <= 0 {
+		return nil, ErrUserIdIsNotValid
+	}
+	if reviewId <= 0 {
+		return nil, ErrReviewIdIsNotValid
+	}
+	var review models.Review
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&review, "id = ? AND status = ?", reviewId, models.ReviewStatusApproved).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCantFindReview
+			}
+			return err
+		}
+		if review.UserID == userId {
+			return ErrCantReportOwnReview
+		}
+		var reported int64
+		if err := tx.Model(&models.ReviewReport{}).Where("review_id = ? AND user_id = ?", reviewId, userId).Count(&reported).Error; err != nil {
+			return err
+		}
+		if reported > 0 {
+			return ErrAlreadyReportedReview
+		}
+		if err := tx.Create(&models.ReviewReport{ReviewID: reviewId, UserID: userId, Reason: reason}).Error; err != nil {
+			return err
+		}
+		review.ReportCount++
+		updates := map[string]interface{}{"report_count": review.ReportCount}
+		if decision := rules.Reported(review.ReportCount); decision.Status != models.ReviewStatusApproved {
+			review.Status = decision.Status
+			review.ModerationReason = decision.Reason
+			updates["status"] = review.Status
+			updates["moderation_reason"] = review.ModerationReason
+		}
+		if err := tx.Model(&review).Updates(updates).Error; err != nil {
+			return err
+		}
+		if review.Status != models.ReviewStatusApproved {
+			return RecalculateProductRating(tx, review.ProductID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// GetModerationQueue lists reviews in the given status, most reported and
+// then oldest first.
+func GetModerationQueue(ctx context.Context, db *gorm.DB, status string, limit int, offset int) ([]models.Review, error) {
+	var reviews []models.Review
+	err := db.WithContext(ctx).Where("status = ?", status).
+		Order("report_count DESC, created_at ASC").Limit(limit).Offset(offset).
+		Find(&reviews).Error
+	return reviews, err
+}
+
+// ModerateReview applies an admin decision: "approve", "reject" or "hide".
+// Rejecting or hiding needs a reason, which is kept on the review.
+func ModerateReview(ctx context.Context, db *gorm.DB, adminId int64, reviewId int64, action string, reason string) (*models.Review, error) {
+	var status string
+	switch action {
+	case "approve":
+		status = models.ReviewStatusApproved
+	case "reject":
+		status = models.ReviewStatusRejected
+	case "hide":
+		status = models.ReviewStatusHidden
+	default:
+		return nil, ErrModerationActionIsNotValid
+	}
+	if status != models.ReviewStatusApproved && reason == "" {
+		return nil, ErrModerationReasonRequired
+	}
+	if reviewId <= 0 {
+		return nil, ErrReviewIdIsNotValid
+	}
+	var review models.Review
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&review, reviewId).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCantFindReview
+			}
+			return err
+		}
+		now := time.Now()
+		review.Status = status
+		review.ModerationReason = reason
+		review.ModeratedBy = adminId
+		review.ModeratedAt = &now
+		if err := tx.Model(&review).Updates(map[string]interface{}{
+			"status":            review.Status,
+			"moderation_reason": review.ModerationReason,
+			"moderated_by":      review.ModeratedBy,
+			"moderated_at":      review.ModeratedAt,
+		}).Error; err != nil {
+			log.Println("Failed to moderate review:", err)
+			return err
+		}
+		return RecalculateProductRating(tx, review.ProductID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/notify"
+	"gorm.io/gorm"
+)
+
+// CheckLowStock alerts every admin about products whose stock fell to their
+// low-stock threshold since the last run. Each product alerts once per
+// crossing; the flag is cleared when stock recovers above the threshold.
+func CheckLowStock(ctx context.Context, db *gorm.DB, notifier notify.Notifier) error {
+	if err := db.WithContext(ctx).Model(&models.Product{}).
+		Where("low_stock_alerted AND quantity > low_stock_threshold").
+		Update("low_stock_alerted", false).Error; err != nil {
+		return err
+	}
+	var products []models.Product
+	if err := db.WithContext(ctx).
+		Where("low_stock_threshold > 0 AND quantity <= low_stock_threshold AND NOT low_stock_alerted").
+		Find(&products).Error; err != nil {
+		return err
+	}
+	if len(products) == 0 {
+		return nil
+	}
+	var admins []models.User
+	if err := db.WithContext(ctx).Where("roles = ?", "admin").Find(&admins).Error; err != nil {
+		return err
+	}
+	if len(admins) == 0 {
+		log.Printf("%d products are low on stock but there is no admin to alert", len(products))
+		return nil
+	}
+	for _, product := range products {
+		subject := "Low stock: " + product.Name
+		body := fmt.Sprintf("%s (SKU %s) is down to %d units, at or below its threshold of %d.", product.Name, product.SKU, product.Quantity, product.LowStockThreshold)
+		if product.Quantity <= 0 {
+			subject = "Out of stock: " + product.Name
+			body = fmt.Sprintf("%s (SKU %s) is out of stock.", product.Name, product.SKU)
+		}
+		sent := false
+		for _, admin := range admins {
+			if err := notifier.Notify(ctx, notify.Notification{UserID: admin.ID, Email: admin.Email, Subject: subject, Body: body}); err != nil {
+				log.Printf("Failed to send low-stock alert for product %d to user %d: %v", product.ID, admin.ID, err)
+				continue
+			}
+			sent = true
+		}
+		// Without a single delivery the alert is retried next run.
+		if sent {
+			if err := db.WithContext(ctx).Model(&product).Update("low_stock_alerted", true).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReorderSuggestion is a line of the reorder report.
+type ReorderSuggestion struct {
+	ProductID         int64   `json:"product_id"`
+	Name              string  `json:"name"`
+	SKU               string  `json:"sku"`
+	Quantity          int     `json:"quantity"`
+	Reserved          int     `json:"reserved"`
+	Backordered       int     `json:"backordered"`
+	LowStockThreshold int     `json:"low_stock_threshold"`
+	UnitsSold         int     `json:"units_sold"`
+	DailyVelocity     float64 `json:"daily_velocity"`
+	DaysOfCover       float64 `json:"days_of_cover"` // -1 when nothing sold
+	SuggestedQuantity int     `json:"suggested_quantity"`
+}
+
+// ReorderOptions tunes the reorder report.
+type ReorderOptions struct {
+	// Window is how far back sales are counted to compute velocity.
+	Window time.Duration
+	// LeadTime is how long a supplier takes to deliver.
+	LeadTime time.Duration
+	// Cover is how long the reordered stock should last after it arrives.
+	Cover time.Duration
+}
+
+// GetReorderReport suggests how much of each product to reorder so stock
+// lasts through the supplier lead time plus the cover period at the rate it
+// sold during the window, keeping the low-stock threshold as safety stock
+// and covering units customers are waiting for on backorder.
+// Products that need nothing are left out.
+func GetReorderReport(ctx context.Context, db *gorm.DB, options ReorderOptions) ([]ReorderSuggestion, error) {
+	since := time.Now().Add(-options.Window)
+	var sales []struct {
+		ProductID int64
+		Units     int
+	}
+	err := db.WithContext(ctx).Model(&models.OrderItem{}).
+		Select("order_items.product_id, SUM(order_items.quantity) AS units").
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Where("orders.created_at >= ? AND orders.order_status NOT IN ?", since, []string{models.OrderStatusPendingPayment, models.OrderStatusCancelled}).
+		Group("order_items.product_id").
+		Scan(&sales).Error
+	if err != nil {
+		return nil, err
+	}
+	sold := make(map[int64]int, len(sales))
+	for _, sale := range sales {
+		sold[sale.ProductID] = sale.Units
+	}
+	var products []models.Product
+	if err := db.WithContext(ctx).Order("id").Find(&products).Error; err != nil {
+		return nil, err
+	}
+	productIds := make([]int64, len(products))
+	for i, product := range products {
+		productIds[i] = product.ID
+	}
+	reserved, err := ReservedQuantities(ctx, db, productIds)
+	if err != nil {
+		return nil, err
+	}
+	backordered, err := OutstandingBackorders(ctx, db, productIds)
+	if err != nil {
+		return nil, err
+	}
+
+	windowDays := math.Max(options.Window.Hours()/24, 1)
+	horizonDays := (options.LeadTime + options.Cover).Hours() / 24
+	report := []ReorderSuggestion{}
+	for _, product := range products {
+		velocity := float64(sold[product.ID]) / windowDays
+		available := product.Quantity - reserved[product.ID] - backordered[product.ID]
+		target := int(math.Ceil(velocity*horizonDays)) + product.LowStockThreshold
+		suggestion := ReorderSuggestion{
+			ProductID:         product.ID,
+			Name:              product.Name,
+			SKU:               product.SKU,
+			Quantity:          product.Quantity,
+			Reserved:          reserved[product.ID],
+			Backordered:       backordered[product.ID],
+			LowStockThreshold: product.LowStockThreshold,
+			UnitsSold:         sold[product.ID],
+			DailyVelocity:     math.Round(velocity*100) / 100,
+			DaysOfCover:       -1,
+			SuggestedQuantity: target - available,
+		}
+		if velocity > 0 {
+			suggestion.DaysOfCover = math.Round(float64(available)/velocity*10) / 10
+		}
+		if suggestion.SuggestedQuantity > 0 {
+			report = append(report, suggestion)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/notify"
+)
+
+// recordingNotifier keeps the notifications it is asked to send.
+type recordingNotifier struct{ sent []notify.Notification }
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification notify.Notification) error {
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+func TestCheckLowStock(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		alerted   bool
+		// quantities are the stock at each run, and subjects the alert
+		// expected after it, if any.
+		quantities []int
+		subjects   []string
+	}{
+		{"above the threshold", 5, false, []int{6}, []string{""}},
+		{"at the threshold", 5, false, []int{5}, []string{"Low stock: Lamp"}},
+		{"out of stock", 5, false, []int{0}, []string{"Out of stock: Lamp"}},
+		{"no threshold", 0, false, []int{0}, []string{""}},
+		{"already alerted", 5, true, []int{3}, []string{""}},
+		{"once per crossing", 5, false, []int{4, 2, 0}, []string{"Low stock: Lamp", "", ""}},
+		{"again after recovering", 5, false, []int{4, 9, 3}, []string{"Low stock: Lamp", "", "Low stock: Lamp"}},
+		{"recovering to the threshold is still low", 5, true, []int{5}, []string{""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDB(t)
+			ctx := context.Background()
+			admin := models.User{Name: "Admin", Email: "admin@example.com", Phone: "1", Password: "x", Roles: "admin"}
+			if err := db.Create(&admin).Error; err != nil {
+				t.Fatal(err)
+			}
+			product := createProduct(t, db, models.Product{Name: "Lamp", Price: 40, LowStockThreshold: test.threshold})
+			if err := db.Model(product).Update("low_stock_alerted", test.alerted).Error; err != nil {
+				t.Fatal(err)
+			}
+			for i, quantity := range test.quantities {
+				if err := db.Model(product).Update("quantity", quantity).Error; err != nil {
+					t.Fatal(err)
+				}
+				notifier := &recordingNotifier{}
+				if err := CheckLowStock(ctx, db, notifier); err != nil {
+					t.Fatal(err)
+				}
+				want := test.subjects[i]
+				switch {
+				case want == "" && len(notifier.sent) > 0:
+					t.Errorf("run %d with %d in stock sent %+v, want nothing", i+1, quantity, notifier.sent)
+				case want != "" && len(notifier.sent) != 1:
+					t.Errorf("run %d with %d in stock sent %d alerts, want %q", i+1, quantity, len(notifier.sent), want)
+				case want != "" && (notifier.sent[0].Subject != want || notifier.sent[0].Email != admin.Email):
+					t.Errorf("run %d with %d in stock sent %+v, want %q to the admin", i+1, quantity, notifier.sent[0], want)
+				case want != "" && !strings.Contains(notifier.sent[0].Body, "Lamp"):
+					t.Errorf("alert body %q doesn't name the product", notifier.sent[0].Body)
+				}
+			}
+		})
+	}
+}
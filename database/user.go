@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm"
+)
+
+var ErrCantFindUser = errors.New("can't find user")
+
+// GetUserByEmail looks up the user logging in.
+func GetUserByEmail(ctx context.Context, db *gorm.DB, email string) (*models.User, error) {
+	var user models.User
+	if err := db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindUser
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UserExists reports whether a user already signed up with the email or the
+// phone number.
+func UserExists(ctx context.Context, db *gorm.DB, column string, value string) (bool, error) {
+	if column != "email" && column != "phone" {
+		return false, errors.New("users are looked up by email or phone")
+	}
+	var count int64
+	err := db.WithContext(ctx).Model(&models.User{}).Where(column+" = ?", value).Count(&count).Error
+	return count > 0, err
+}
+
+func CreateUser(ctx context.Context, db *gorm.DB, user *models.User) error {
+	return db.WithContext(ctx).Create(user).Error
+}
+
+// UpdateUserTokens stores the tokens last issued to the user.
+func UpdateUserTokens(ctx context.Context, db *gorm.DB, userId int64, token string, refreshToken string) error {
+	result := db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userId).
+		Updates(map[string]interface{}{"token": token, "refresh_token": refreshToken})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCantFindUser
+	}
+	return nil
+}
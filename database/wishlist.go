@@ -0,0 +1,312 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/notify"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrWishlistIdIsNotValid     = errors.New("wishlist id is not valid")
+	ErrCantFindWishlist         = errors.New("can't find wishlist")
+	ErrCantFindWishlistItem     = errors.New("can't find product in wishlist")
+	ErrProductAlreadyInWishlist = errors.New("product already in wishlist")
+)
+
+// GetWishlists returns the user's wishlists with their products.
+func GetWishlists(ctx context.Context, db *gorm.DB, userId int64) ([]models.Wishlist, error) {
+	if userId <= 0 {
+		return nil, ErrUserIdIsNotValid
+	}
+	var wishlists []models.Wishlist
+	err := db.WithContext(ctx).Preload("Items.Product").Where("user_id = ?", userId).Order("id").Find(&wishlists).Error
+	return wishlists, err
+}
+
+// GetWishlist loads one of the user's wishlists with its products.
+func GetWishlist(ctx context.Context, db *gorm.DB, userId int64, wishlistId int64) (*models.Wishlist, error) {
+	if userId <= 0 {
+		return nil, ErrUserIdIsNotValid
+	}
+	if wishlistId <= 0 {
+		return nil, ErrWishlistIdIsNotValid
+	}
+	var wishlist models.Wishlist
+	if err := db.WithContext(ctx).Preload("Items.Product").First(&wishlist, "id = ? AND user_id = ?", wishlistId, userId).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindWishlist
+		}
+		return nil, err
+	}
+	return &wishlist, nil
+}
+
+// GetSharedWishlist loads a wishlist by its share token. The link is public,
+// so only items whose products are published are included.
+func GetSharedWishlist(ctx context.Context, db *gorm.DB, token string) (*models.Wishlist, error) {
+	var wishlist models.Wishlist
+	err := db.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Select("wishlist_items.*").
+				Joins("JOIN products ON products.id = wishlist_items.product_id AND products.deleted_at IS NULL").
+				Scopes(Published)
+		}).
+		Preload("Items.Product").
+		First(&wishlist, "share_token = ?", token).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCantFindWishlist
+		}
+		return nil, err
+	}
+	return &wishlist, nil
+}
+
+func CreateWishlist(ctx context.Context, db *gorm.DB, userId int64, name string) (*models.Wishlist, error) {
+	if userId <= 0 {
+		return nil, ErrUserIdIsNotValid
+	}
+	wishlist := models.Wishlist{UserID: userId, Name: name}
+	if err := db.WithContext(ctx).Create(&wishlist).Error; err != nil {
+		log.Println("Failed to create wishlist:", err)
+		return nil, err
+	}
+	return &wishlist, nil
+}
+
+func RenameWishlist(ctx context.Context, db *gorm.DB, userId int64, wishlistId int64, name string) (*models.Wishlist, error) {
+	wishlist, err := GetWishlist(ctx, db, userId, wishlistId)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.WithContext(ctx).Model(wishlist).Update("name", name).Error; err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+// DeleteWishlist removes the wishlist and its items for good, which also
+// revokes its share link.
+func DeleteWishlist(ctx context.Context, db *gorm.DB, userId int64, wishlistId int64) error {
+	wishlist, err := GetWishlist(ctx, db, userId, wishlistId)
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&models.WishlistItem{}, "wishlist_id = ?", wishlist.ID).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(wishlist).Error
+	})
+}
+
+// AddWishlistItem puts a product on the wishlist. The product's current price
+// and stock are the baseline for back-in-stock and price-drop alerts.
+func AddWishlistItem(ctx context.Context, db *gorm.DB, userId int64, wishlistId int64, item models.WishlistItem) (*models.WishlistItem, error) {
+	wishlist, err := GetWishlist(ctx, db, userId, wishlistId)
+	if err != nil {
+		return nil, err
+	}
+	if item.ProductID <= 0 {
+		return nil, ErrProductIdIsNotValid
+	}
+	var product models.Product
+	if err := db.WithContext(ctx).Scopes(Published).First(&product, item.ProductID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrCanNotFindProduct
+		}
+		return nil, err
+	}
+	for _, existing := range wishlist.Items {
+		if existing.ProductID == item.ProductID {
+			return nil, ErrProductAlreadyInWishlist
+		}
+	}
+	sellable, err := SellableQuantity(ctx, db, &product)
+	if err != nil {
+		return nil, err
+	}
+	item.ID = 0
+	item.WishlistID = wishlist.ID
+	item.LastSeenPrice = product.Price
+	item.LastSeenInStock = sellable > 0
+	if err := db.WithContext(ctx).Omit(clause.Associations).Create(&item).Error; err != nil {
+		log.Println("Failed to add product to wishlist:", err)
+		return nil, err
+	}
+	item.Product = product
+	return &item, nil
+}
+
+// UpdateWishlistItemAlerts changes which alerts the customer wants for a product.
+func UpdateWishlistItemAlerts(ctx context.Context, db *gorm.DB, userId int64, wishlistId int64, productId int64, backInStock bool, priceDrop bool) (*models.WishlistItem, error) {
+	item, err := getWishlistItem(ctx, db, userId, wishlistId, productId)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.WithContext(ctx).Model(item).Updates(map[string]interface{}{
+		"notify_back_in_stock": backInStock,
+		"notify_price_drop":    priceDrop,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func RemoveWishlistItem(ctx context.Context, db *gorm.DB, userId int64, wishlistId int64, productId int64) error {
+	item, err := getWishlistItem(ctx, db, userId, wishlistId, productId)
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Unscoped().Delete(item).Error
+}
+
+// MoveWishlistItemToCart adds the product to the user's cart and takes it
+// off the wishlist.
+func MoveWishlistItemToCart(ctx context.Context, db *gorm.DB, userId int64, wishlistId int64, productId int64) error {
+	item, err := getWishlistItem(ctx, db, userId, wishlistId, productId)
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := AddProductToCart(ctx, tx, productId, userId, 1); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(item).Error
+	})
+}
+
+// ShareWishlist gives the wishlist an unguessable share token, replacing any
+// previous one so old links stop working.
+func ShareWishlist(ctx context.Context, db *gorm.DB, userId int64, wishlistId int64) (*models.Wishlist, error) {
+	wishlist, err := GetWishlist(ctx, db, userId, wishlistId)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	if err := db.WithContext(ctx).Model(wishlist).Update("share_token", token).Error; err != nil {
+		return nil, err
+	}
+	wishlist.ShareToken = &token
+	return wishlist, nil
+}
+
+// UnshareWishlist revokes the wishlist's share link.
+func UnshareWishlist(ctx context.Context, db *gorm.DB, userId int64, wishlistId int64) error {
+	wishlist, err := GetWishlist(ctx, db, userId, wishlistId)
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Model(wishlist).Update("share_token", nil).Error
+}
+
+func getWishlistItem(ctx context.Context, db *gorm.DB, userId int64, wishlistId int64, productId int64) (*models.WishlistItem, error) {
+	wishlist, err := GetWishlist(ctx, db, userId, wishlistId)
+	if err != nil {
+		return nil, err
+	}
+	for i := range wishlist.Items {
+		if wishlist.Items[i].ProductID == productId {
+			return &wishlist.Items[i], nil
+		}
+	}
+	return nil, ErrCantFindWishlistItem
+}
+
+// CheckWishlistAlerts notifies customers whose wishlisted products came back
+// into stock or got cheaper since they last saw them, then moves each item's
+// baseline to the product's current state. A product is in stock when it can
+// be added to a cart: it is published and its SellableQuantity, which counts
+// reservations, bundles, digital goods and the backorder policy, is positive.
+// Unpublished products are left alone until they are published again.
+func CheckWishlistAlerts(ctx context.Context, db *gorm.DB, notifier notify.Notifier) error {
+	var rows []struct {
+		ItemID            int64
+		ProductID         int64
+		UserID            int64
+		Email             string
+		WishlistName      string
+		ProductName       string
+		Price             float64
+		NotifyBackInStock bool
+		NotifyPriceDrop   bool
+		LastSeenPrice     float64
+		LastSeenInStock   bool
+	}
+	err := db.WithContext(ctx).Table("wishlist_items").
+		Select("wishlist_items.id AS item_id, wishlist_items.product_id, users.id AS user_id, users.email, " +
+			"wishlists.name AS wishlist_name, products.name AS product_name, products.price, " +
+			"wishlist_items.notify_back_in_stock, wishlist_items.notify_price_drop, " +
+			"wishlist_items.last_seen_price, wishlist_items.last_seen_in_stock").
+		Joins("JOIN wishlists ON wishlists.id = wishlist_items.wishlist_id AND wishlists.deleted_at IS NULL").
+		Joins("JOIN users ON users.id = wishlists.user_id").
+		Joins("JOIN products ON products.id = wishlist_items.product_id AND products.deleted_at IS NULL").
+		Scopes(Published).
+		Where("wishlist_items.deleted_at IS NULL").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	available := map[int64]bool{}
+	for _, row := range rows {
+		if _, ok := available[row.ProductID]; ok {
+			continue
+		}
+		var product models.Product
+		if err := db.WithContext(ctx).First(&product, row.ProductID).Error; err != nil {
+			return err
+		}
+		sellable, err := SellableQuantity(ctx, db, &product)
+		if err != nil {
+			return err
+		}
+		available[row.ProductID] = sellable > 0
+	}
+	for _, row := range rows {
+		inStock := available[row.ProductID]
+		if inStock == row.LastSeenInStock && row.Price == row.LastSeenPrice {
+			continue
+		}
+		// A product can come back and get cheaper between two runs; both
+		// go into one message.
+		var news []string
+		if row.NotifyBackInStock && inStock && !row.LastSeenInStock {
+			news = append(news, "is back in stock")
+		}
+		if row.NotifyPriceDrop && row.Price < row.LastSeenPrice {
+			news = append(news, fmt.Sprintf("dropped from %.2f to %.2f", row.LastSeenPrice, row.Price))
+		}
+		if len(news) > 0 {
+			if err := notifier.Notify(ctx, notify.Notification{
+				UserID:  row.UserID,
+				Email:   row.Email,
+				Subject: "Wishlist update: " + row.ProductName,
+				Body:    fmt.Sprintf("%s from your wishlist \"%s\" %s.", row.ProductName, row.WishlistName, strings.Join(news, " and ")),
+			}); err != nil {
+				// Keep the old baseline so the alert is retried next run.
+				log.Printf("Failed to send wishlist alert for item %d: %v", row.ItemID, err)
+				continue
+			}
+		}
+		if err := db.WithContext(ctx).Model(&models.WishlistItem{}).Where("id = ?", row.ItemID).Updates(map[string]interface{}{
+			"last_seen_price":    row.Price,
+			"last_seen_in_stock": inStock,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Package inventory decides which warehouses fulfil the lines of an order.
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"githum.com/muhammadAslam/ecommerce/config"
+)
+
+// Line is a quantity of a product that has to be shipped.
+type Line struct {
+	ProductID int64
+	Quantity  int
+}
+
+// Warehouse is a fulfilment location with its stock on hand per product.
+// Lower Priority values are preferred.
+type Warehouse struct {
+	ID       int64
+	Priority int
+	Stock    map[int64]int
+}
+
+// Pick ships Quantity units of a product from one warehouse. A line may be
+// split over several picks when no single warehouse holds all of it.
+type Pick struct {
+	ProductID   int64
+	WarehouseID int64
+	Quantity    int
+}
+
+var ErrCantAllocate = errors.New("not enough stock in any warehouse to fulfil the order")
+
+// Strategy chooses the warehouses an order ships from.
+type Strategy interface {
+	Allocate(lines []Line, warehouses []Warehouse) ([]Pick, error)
+}
+
+const (
+	StrategyPriority        = "priority"
+	StrategySingleWarehouse = "single"
+	StrategyMostStock       = "most-stock"
+)
+
+// StrategyByName returns the strategy configured under name.
+func StrategyByName(name string) (Strategy, error) {
+	switch name {
+	case StrategyPriority:
+		return Priority{}, nil
+	case StrategySingleWarehouse, "":
+		return SingleWarehouse{}, nil
+	case StrategyMostStock:
+		return MostStock{}, nil
+	}
+	return nil, fmt.Errorf("unknown allocation strategy %q", name)
+}
+
+// NewStrategy returns the strategy named in the inventory configuration.
+func NewStrategy(cfg config.Inventory) (Strategy, error) {
+	return StrategyByName(cfg.AllocationStrategy)
+}
+
+// Priority takes every line from the preferred warehouses first, moving on
+// to the next one when a warehouse runs out.
+type Priority struct{}
+
+func (Priority) Allocate(lines []Line, warehouses []Warehouse) ([]Pick, error) {
+	ordered := byPriority(warehouses)
+	return greedy(lines, func(int64) []Warehouse { return ordered })
+}
+
+// SingleWarehouse ships the whole order from the preferred warehouse that
+// can fulfil all of it, and falls back to Priority when none can.
+type SingleWarehouse struct{}
+
+func (SingleWarehouse) Allocate(lines []Line, warehouses []Warehouse) ([]Pick, error) {
+	for _, warehouse := range byPriority(warehouses) {
+		covered := true
+		for _, line := range lines {
+			if warehouse.Stock[line.ProductID] < line.Quantity {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			picks := make([]Pick, len(lines))
+			for i, line := range lines {
+				picks[i] = Pick{ProductID: line.ProductID, WarehouseID: warehouse.ID, Quantity: line.Quantity}
+			}
+			return picks, nil
+		}
+	}
+	return Priority{}.Allocate(lines, warehouses)
+}
+
+// MostStock takes each line from the warehouses holding most of the product,
+// which keeps stock levels even across locations.
+type MostStock struct{}
+
+func (MostStock) Allocate(lines []Line, warehouses []Warehouse) ([]Pick, error) {
+	return greedy(lines, func(productId int64) []Warehouse {
+		ordered := byPriority(warehouses)
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].Stock[productId] > ordered[j].Stock[productId]
+		})
+		return ordered
+	})
+}
+
+func byPriority(warehouses []Warehouse) []Warehouse {
+	ordered := append([]Warehouse(nil), warehouses...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Priority != ordered[j].Priority {
+			return ordered[i].Priority < ordered[j].Priority
+		}
+		return ordered[i].ID < ordered[j].ID
+	})
+	return ordered
+}
+
+// greedy fills each line from the warehouses in the order returned by
+// candidates, splitting it when one warehouse doesn't hold enough.
+func greedy(lines []Line, candidates func(productId int64) []Warehouse) ([]Pick, error) {
+	used := map[int64]map[int64]int{}
+	var picks []Pick
+	for _, line := range lines {
+		remaining := line.Quantity
+		for _, warehouse := range candidates(line.ProductID) {
+			if remaining == 0 {
+				break
+			}
+			if used[warehouse.ID] == nil {
+				used[warehouse.ID] = map[int64]int{}
+			}
+			free := warehouse.Stock[line.ProductID] - used[warehouse.ID][line.ProductID]
+			if free <= 0 {
+				continue
+			}
+			quantity := min(free, remaining)
+			used[warehouse.ID][line.ProductID] += quantity
+			picks = append(picks, Pick{ProductID: line.ProductID, WarehouseID: warehouse.ID, Quantity: quantity})
+			remaining -= quantity
+		}
+		if remaining > 0 {
+			return nil, ErrCantAllocate
+		}
+	}
+	return picks, nil
+}
@@ -0,0 +1,158 @@
+package inventory
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStrategies(t *testing.T) {
+	// East is preferred over West, which ties with North on priority.
+	east := Warehouse{ID: 1, Priority: 1, Stock: map[int64]int{10: 3, 20: 5}}
+	west := Warehouse{ID: 2, Priority: 2, Stock: map[int64]int{10: 8, 20: 1}}
+	north := Warehouse{ID: 3, Priority: 2, Stock: map[int64]int{10: 8}}
+	warehouses := []Warehouse{north, west, east}
+
+	tests := []struct {
+		name     string
+		strategy Strategy
+		lines    []Line
+		want     []Pick
+		err      error
+	}{
+		{
+			name:     "priority takes from the preferred warehouse",
+			strategy: Priority{},
+			lines:    []Line{{ProductID: 10, Quantity: 2}},
+			want:     []Pick{{ProductID: 10, WarehouseID: 1, Quantity: 2}},
+		},
+		{
+			name:     "priority splits a line when the preferred warehouse runs out",
+			strategy: Priority{},
+			lines:    []Line{{ProductID: 10, Quantity: 5}},
+			want:     []Pick{{ProductID: 10, WarehouseID: 1, Quantity: 3}, {ProductID: 10, WarehouseID: 2, Quantity: 2}},
+		},
+		{
+			name:     "priority breaks ties by lower ID",
+			strategy: Priority{},
+			lines:    []Line{{ProductID: 10, Quantity: 12}},
+			want: []Pick{
+				{ProductID: 10, WarehouseID: 1, Quantity: 3},
+				{ProductID: 10, WarehouseID: 2, Quantity: 8},
+				{ProductID: 10, WarehouseID: 3, Quantity: 1},
+			},
+		},
+		{
+			name:     "priority counts stock already picked for the order",
+			strategy: Priority{},
+			lines:    []Line{{ProductID: 10, Quantity: 2}, {ProductID: 10, Quantity: 2}},
+			want: []Pick{
+				{ProductID: 10, WarehouseID: 1, Quantity: 2},
+				{ProductID: 10, WarehouseID: 1, Quantity: 1},
+				{ProductID: 10, WarehouseID: 2, Quantity: 1},
+			},
+		},
+		{
+			name:     "priority without enough stock anywhere",
+			strategy: Priority{},
+			lines:    []Line{{ProductID: 20, Quantity: 7}},
+			err:      ErrCantAllocate,
+		},
+		{
+			name:     "single ships the whole order from one warehouse",
+			strategy: SingleWarehouse{},
+			lines:    []Line{{ProductID: 10, Quantity: 3}, {ProductID: 20, Quantity: 1}},
+			want:     []Pick{{ProductID: 10, WarehouseID: 1, Quantity: 3}, {ProductID: 20, WarehouseID: 1, Quantity: 1}},
+		},
+		{
+			name:     "single skips a preferred warehouse that can't cover every line",
+			strategy: SingleWarehouse{},
+			lines:    []Line{{ProductID: 10, Quantity: 4}, {ProductID: 20, Quantity: 1}},
+			want:     []Pick{{ProductID: 10, WarehouseID: 2, Quantity: 4}, {ProductID: 20, WarehouseID: 2, Quantity: 1}},
+		},
+		{
+			name:     "single breaks ties by lower ID",
+			strategy: SingleWarehouse{},
+			lines:    []Line{{ProductID: 10, Quantity: 6}},
+			want:     []Pick{{ProductID: 10, WarehouseID: 2, Quantity: 6}},
+		},
+		{
+			name:     "single falls back to splitting by priority",
+			strategy: SingleWarehouse{},
+			lines:    []Line{{ProductID: 10, Quantity: 4}, {ProductID: 20, Quantity: 6}},
+			want: []Pick{
+				{ProductID: 10, WarehouseID: 1, Quantity: 3},
+				{ProductID: 10, WarehouseID: 2, Quantity: 1},
+				{ProductID: 20, WarehouseID: 1, Quantity: 5},
+				{ProductID: 20, WarehouseID: 2, Quantity: 1},
+			},
+		},
+		{
+			name:     "single without enough stock anywhere",
+			strategy: SingleWarehouse{},
+			lines:    []Line{{ProductID: 30, Quantity: 1}},
+			err:      ErrCantAllocate,
+		},
+		{
+			name:     "most stock takes from the fullest warehouse",
+			strategy: MostStock{},
+			lines:    []Line{{ProductID: 20, Quantity: 2}},
+			want:     []Pick{{ProductID: 20, WarehouseID: 1, Quantity: 2}},
+		},
+		{
+			name:     "most stock breaks ties by priority, then lower ID",
+			strategy: MostStock{},
+			lines:    []Line{{ProductID: 10, Quantity: 2}},
+			want:     []Pick{{ProductID: 10, WarehouseID: 2, Quantity: 2}},
+		},
+		{
+			name:     "most stock splits a line over the fullest warehouses",
+			strategy: MostStock{},
+			lines:    []Line{{ProductID: 10, Quantity: 18}},
+			want: []Pick{
+				{ProductID: 10, WarehouseID: 2, Quantity: 8},
+				{ProductID: 10, WarehouseID: 3, Quantity: 8},
+				{ProductID: 10, WarehouseID: 1, Quantity: 2},
+			},
+		},
+		{
+			name:     "most stock without enough stock anywhere",
+			strategy: MostStock{},
+			lines:    []Line{{ProductID: 10, Quantity: 20}},
+			err:      ErrCantAllocate,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			picks, err := test.strategy.Allocate(test.lines, warehouses)
+			if err != test.err {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if !slices.Equal(picks, test.want) {
+				t.Errorf("got picks %+v, want %+v", picks, test.want)
+			}
+		})
+	}
+}
+
+func TestStrategyByName(t *testing.T) {
+	tests := []struct {
+		name string
+		want Strategy
+	}{
+		{StrategyPriority, Priority{}},
+		{StrategySingleWarehouse, SingleWarehouse{}},
+		{"", SingleWarehouse{}},
+		{StrategyMostStock, MostStock{}},
+	}
+	for _, test := range tests {
+		strategy, err := StrategyByName(test.name)
+		if err != nil {
+			t.Errorf("%q: %v", test.name, err)
+		} else if strategy != test.want {
+			t.Errorf("%q: got %T, want %T", test.name, strategy, test.want)
+		}
+	}
+	if _, err := StrategyByName("nearest"); err == nil {
+		t.Error("an unknown strategy got no error")
+	}
+}
@@ -0,0 +1,65 @@
+// Package jobs runs background work: periodic jobs such as stock alerts and
+// cleanup sweeps, and one-off jobs such as product imports.
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Every calls fn once per interval until ctx is cancelled. Errors are logged
+// and do not stop the job. Every blocks, so run it in its own goroutine.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("job %s stopped", name)
+			return
+		case <-ticker.C:
+			if err := fn(ctx); err != nil {
+				log.Printf("job %s failed: %v", name, err)
+			}
+		}
+	}
+}
+
+// Group runs jobs until its context is cancelled and lets the caller wait
+// for them to return, so a shutdown doesn't cut a job off mid-run.
+type Group struct {
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+func NewGroup(ctx context.Context) *Group {
+	return &Group{ctx: ctx}
+}
+
+// Every starts a job that calls fn once per interval, see Every.
+func (g *Group) Every(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		Every(g.ctx, name, interval, fn)
+	}()
+}
+
+// Go runs fn once in the background, such as a job an admin queued. fn
+// gets the group's context and should stop early when it is cancelled.
+func (g *Group) Go(name string, fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := fn(g.ctx); err != nil {
+			log.Printf("job %s failed: %v", name, err)
+		}
+	}()
+}
+
+// Wait blocks until every job has stopped.
+func (g *Group) Wait() {
+	g.wg.Wait()
+}
@@ -1,66 +1,143 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
-	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"githum.com/muhammadAslam/ecommerce/config"
 	"githum.com/muhammadAslam/ecommerce/controllers"
 	"githum.com/muhammadAslam/ecommerce/database"
-	"githum.com/muhammadAslam/ecommerce/middleware"
+	"githum.com/muhammadAslam/ecommerce/inventory"
+	"githum.com/muhammadAslam/ecommerce/jobs"
+	"githum.com/muhammadAslam/ecommerce/moderation"
+	"githum.com/muhammadAslam/ecommerce/notify"
+	"githum.com/muhammadAslam/ecommerce/repository"
 	"githum.com/muhammadAslam/ecommerce/routes"
+	"githum.com/muhammadAslam/ecommerce/storage"
+	"githum.com/muhammadAslam/ecommerce/tokens"
 )
 
 func main() {
-	err := godotenv.Load()
+	cfg, err := config.Load(os.Args[1:])
 	if err != nil {
-		log.Fatalf("Error loading .env file")
+		log.Fatal(err)
 	}
-	// Print to indicate the application started
-	fmt.Println("Hello, World!")
+	log.Println("Starting with configuration", cfg)
 
-	// Retrieve the port from the environment variable or default to 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	strategy, err := inventory.NewStrategy(cfg.Inventory)
+	if err != nil {
+		log.Fatal("invalid configuration: ", err)
 	}
+	files, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatal("invalid configuration: ", err)
+	}
+	db, err := database.DBSet(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Imports left running by a previous process can't resume.
+	if err := database.FailInterruptedImports(context.Background(), db); err != nil {
+		log.Fatal(err)
+	}
+	tokenManager := tokens.NewManager(cfg.Auth)
 
-	// Initialize the application with products and users data
-	productData := database.NewProductData(database.DBSet(), "Products")
-	userData := database.NewUserData(database.DBSet(), "Users")
-
-	app := controllers.NewApplication(productData, userData)
-	// Initialize Gin router
-	router := gin.New()
+	// Handlers get their storage and services from the application, so
+	// this is the only place they are wired to the database.
+	app := controllers.NewApplication(repository.NewGorm(db), tokenManager)
+	app.Files = files
+	app.AllocationStrategy = strategy
+	app.PreOrderPaymentWindow = time.Duration(cfg.Inventory.PreOrderPaymentWindow)
+	app.ReviewRules = moderation.NewRules(cfg.Reviews)
 
-	// Use built-in Gin logger middleware
-	router.Use(gin.Logger())
-	router.GET("/get-products", controllers.GetProds())
-	router.GET("/get-product/:id", controllers.GetProductByID())
-	router.GET("/search-products", controllers.SearchProduct())
-	router.POST("/signup", controllers.Signup())
-	router.POST("/login", controllers.Login())
-	router.GET("/", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"message": os.Getenv("APP_NAME"),
-		})
-	})
+	app.Ready = func(ctx context.Context) error { return database.Ready(ctx, db) }
 
-	// Define user routes
+	// SIGINT or SIGTERM starts a graceful shutdown.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Apply authentication middleware globally for all routes after this point
-	router.Use(middleware.Authentication())
-	routes.UserRoutes(router)
-	routes.AdminRoutes(router)
-	// Define other routes for the app
+	// Background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	workers := jobs.NewGroup(jobsCtx)
+	app.Jobs = workers
+	notifier := notify.LogNotifier{}
+	workers.Every("wishlist-alerts", 5*time.Minute, func(ctx context.Context) error {
+		return database.CheckWishlistAlerts(ctx, db, notifier)
+	})
+	workers.Every("guest-cart-cleanup", time.Hour, func(ctx context.Context) error {
+		return database.DeleteExpiredGuestCarts(ctx, db)
+	})
+	workers.Every("low-stock-alerts", 15*time.Minute, func(ctx context.Context) error {
+		return database.CheckLowStock(ctx, db, notifier)
+	})
+	workers.Every("reservation-sweeper", time.Minute, func(ctx context.Context) error {
+		return database.ReleaseExpiredReservations(ctx, db, app.PreOrderPaymentWindow)
+	})
+	workers.Every("idempotency-key-cleanup", time.Hour, func(ctx context.Context) error {
+		return database.DeleteExpiredIdempotencyKeys(ctx, db)
+	})
+	workers.Every("product-schedules", time.Minute, func(ctx context.Context) error {
+		return database.ApplyProductSchedules(ctx, db)
+	})
+	workers.Every("price-schedules", time.Minute, func(ctx context.Context) error {
+		return database.ApplyPriceSchedules(ctx, db)
+	})
+	workers.Every("product-trash-purge", time.Hour, func(ctx context.Context) error {
+		return database.PurgeDeletedProducts(ctx, db, app.Files, time.Now().Add(-database.ProductTrashRetention))
+	})
 
-	router.GET("/addtocart", app.AddToCart())
-	router.GET("/removefromcart", app.RemoveFromCart())
-	router.GET("/cartcheckout", app.Checkout()) // Fixed the path typo: "cartcheckput" -> "cartcheckout"
-	router.GET("/instantbuy", app.GetInstantBuy())
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.App.Port),
+		Handler:           routes.NewRouter(app, cfg.App.Name),
+		ReadHeaderTimeout: time.Duration(cfg.App.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.App.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.App.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.App.IdleTimeout),
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Println("Listening on", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-serverErr:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	stop()
 
-	// Start the server on the specified port
-	log.Fatal(router.Run(":" + port))
+	// Fail the readiness probe and keep serving for the drain delay, so load
+	// balancers stop routing here before the listener closes. Then stop
+	// accepting requests and let the ones in flight, such as a checkout in
+	// the middle of its transaction, finish. Handlers use their own
+	// contexts, so Shutdown waits for them rather than cancelling them.
+	log.Println("Shutting down")
+	app.Drain()
+	time.Sleep(time.Duration(cfg.App.DrainDelay))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.App.ShutdownTimeout))
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Failed to drain in-flight requests:", err)
+	}
+	stopJobs()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		log.Println("Background jobs did not stop in time")
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	log.Println("Stopped")
 }
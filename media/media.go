@@ -0,0 +1,207 @@
+// Package media checks uploaded product images and renders the smaller
+// variants shown in listings and on product pages.
+package media
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// MaxImageSize is the largest image upload accepted, in bytes.
+const MaxImageSize = 10 << 20
+
+// maxPixels bounds the decoded size of an image, so a small file can't
+// expand into a huge bitmap.
+const maxPixels = 40_000_000
+
+var (
+	ErrImageTooLarge       = errors.New("image is too large")
+	ErrUnsupportedImage    = errors.New("image must be a JPEG, PNG or GIF")
+	ErrImageDimensionLarge = errors.New("image dimensions are too large")
+)
+
+// Variant is a rendition of an uploaded image.
+type Variant struct {
+	Name    string
+	MaxSide int // longest side in pixels; 0 keeps the original
+}
+
+// Variants are rendered for every upload besides the original.
+var Variants = []Variant{
+	{Name: "thumbnail", MaxSide: 200},
+	{Name: "medium", MaxSide: 800},
+}
+
+// Rendition is an encoded image variant ready to be stored.
+type Rendition struct {
+	Variant     string
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+}
+
+// Image is a checked upload with its renditions, the original first.
+type Image struct {
+	ContentType string
+	Width       int
+	Height      int
+	Renditions  []Rendition
+}
+
+// KeyPrefix returns a new storage prefix for the renditions of one image of
+// the product. It is random so a replaced image never reuses the keys of an
+// old one that browsers may have cached for good.
+func KeyPrefix(productId int64) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("media/products/%d/%s", productId, hex.EncodeToString(suffix)), nil
+}
+
+// Key is the storage key of the rendition under prefix.
+func (r Rendition) Key(prefix string) string {
+	return prefix + "/" + r.Variant + Extension(r.ContentType)
+}
+
+// Extension is the file extension used to store images of the content type.
+func Extension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	}
+	return ""
+}
+
+// Sniff detects the content type from the data itself rather than trusting
+// the client, and only lets the supported image formats through.
+func Sniff(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return contentType, nil
+	}
+	return "", ErrUnsupportedImage
+}
+
+// Process checks an uploaded image and renders its variants. Variants keep
+// PNG and GIF transparency as PNG; everything else becomes JPEG.
+func Process(data []byte) (*Image, error) {
+	if len(data) > MaxImageSize {
+		return nil, ErrImageTooLarge
+	}
+	contentType, err := Sniff(data)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrImageDimensionLarge
+	}
+	source, err := decode(contentType, data)
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	result := &Image{
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+		Renditions: []Rendition{{
+			Variant:     "original",
+			ContentType: contentType,
+			Data:        data,
+			Width:       config.Width,
+			Height:      config.Height,
+		}},
+	}
+	for _, variant := range Variants {
+		resized := Resize(source, variant.MaxSide)
+		var buf bytes.Buffer
+		variantType := "image/jpeg"
+		if contentType == "image/jpeg" {
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 85})
+		} else {
+			variantType = "image/png"
+			err = png.Encode(&buf, resized)
+		}
+		if err != nil {
+			return nil, err
+		}
+		bounds := resized.Bounds()
+		result.Renditions = append(result.Renditions, Rendition{
+			Variant:     variant.Name,
+			ContentType: variantType,
+			Data:        buf.Bytes(),
+			Width:       bounds.Dx(),
+			Height:      bounds.Dy(),
+		})
+	}
+	return result, nil
+}
+
+func decode(contentType string, data []byte) (image.Image, error) {
+	switch contentType {
+	case "image/png":
+		return png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		return gif.Decode(bytes.NewReader(data))
+	}
+	return jpeg.Decode(bytes.NewReader(data))
+}
+
+// Resize scales the image down so its longest side is at most maxSide,
+// averaging the source pixels each target pixel covers. Smaller images are
+// returned unchanged.
+func Resize(source image.Image, maxSide int) image.Image {
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if maxSide <= 0 || width <= maxSide && height <= maxSide {
+		return source
+	}
+	targetWidth, targetHeight := maxSide, height*maxSide/width
+	if height > width {
+		targetWidth, targetHeight = width*maxSide/height, maxSide
+	}
+	targetWidth, targetHeight = max(targetWidth, 1), max(targetHeight, 1)
+	target := image.NewNRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	for y := 0; y < targetHeight; y++ {
+		y0 := bounds.Min.Y + y*height/targetHeight
+		y1 := max(bounds.Min.Y+(y+1)*height/targetHeight, y0+1)
+		for x := 0; x < targetWidth; x++ {
+			x0 := bounds.Min.X + x*width/targetWidth
+			x1 := max(bounds.Min.X+(x+1)*width/targetWidth, x0+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(source.At(sx, sy)).(color.NRGBA64)
+					r, g, b, a = r+uint64(c.R), g+uint64(c.G), b+uint64(c.B), a+uint64(c.A)
+					n++
+				}
+			}
+			target.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return target
+}
@@ -0,0 +1,180 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"regexp"
+	"testing"
+)
+
+// encode renders a width by height image in the given format.
+func encode(t *testing.T, format string, width int, height int) []byte {
+	t.Helper()
+	picture := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			picture.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, picture)
+	case "jpeg":
+		err = jpeg.Encode(&buf, picture, nil)
+	case "gif":
+		err = gif.Encode(&buf, picture, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pngHeader is the start of a PNG file claiming the given dimensions, enough
+// for image.DecodeConfig.
+func pngHeader(width uint32, height uint32) []byte {
+	ihdr := []byte("IHDR")
+	ihdr = binary.BigEndian.AppendUint32(ihdr, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8-bit RGBA
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, 13)
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		err         error
+	}{
+		{"png", encode(t, "png", 4, 4), "image/png", nil},
+		{"jpeg", encode(t, "jpeg", 4, 4), "image/jpeg", nil},
+		{"gif", encode(t, "gif", 4, 4), "image/gif", nil},
+		{"text", []byte("just some text"), "", ErrUnsupportedImage},
+		{"pdf", []byte("%PDF-1.7\n"), "", ErrUnsupportedImage},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "", ErrUnsupportedImage},
+		{"empty", nil, "", ErrUnsupportedImage},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contentType, err := Sniff(test.data)
+			if contentType != test.contentType || err != test.err {
+				t.Errorf("got %q and error %v, want %q and %v", contentType, err, test.contentType, test.err)
+			}
+		})
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"not an image", []byte("#!/bin/sh\nrm -rf /\n"), ErrUnsupportedImage},
+		{"truncated png", encode(t, "png", 50, 50)[:40], ErrUnsupportedImage},
+		{"too large a file", append(encode(t, "png", 4, 4), make([]byte, MaxImageSize)...), ErrImageTooLarge},
+		{"too many pixels", pngHeader(10_000, 10_000), ErrImageDimensionLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Process(test.data); err != test.err {
+				t.Errorf("got error %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestProcessRenditions(t *testing.T) {
+	type size struct{ width, height int }
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		variantType string
+		// sizes are those of the original, thumbnail and medium renditions.
+		sizes []size
+	}{
+		{"landscape png", encode(t, "png", 1000, 500), "image/png", "image/png", []size{{1000, 500}, {200, 100}, {800, 400}}},
+		{"portrait jpeg", encode(t, "jpeg", 300, 900), "image/jpeg", "image/jpeg", []size{{300, 900}, {66, 200}, {266, 800}}},
+		{"gif becomes png", encode(t, "gif", 400, 400), "image/gif", "image/png", []size{{400, 400}, {200, 200}, {400, 400}}},
+		{"small image keeps its size", encode(t, "png", 120, 80), "image/png", "image/png", []size{{120, 80}, {120, 80}, {120, 80}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			processed, err := Process(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if processed.ContentType != test.contentType || processed.Width != test.sizes[0].width || processed.Height != test.sizes[0].height {
+				t.Errorf("got a %dx%d %s, want %dx%d %s", processed.Width, processed.Height, processed.ContentType,
+					test.sizes[0].width, test.sizes[0].height, test.contentType)
+			}
+			variants := []string{"original", "thumbnail", "medium"}
+			if len(processed.Renditions) != len(variants) {
+				t.Fatalf("got %d renditions, want %d", len(processed.Renditions), len(variants))
+			}
+			for i, rendition := range processed.Renditions {
+				wantType := test.variantType
+				if i == 0 {
+					wantType = test.contentType
+					if !bytes.Equal(rendition.Data, test.data) {
+						t.Error("the original rendition is not the uploaded data")
+					}
+				}
+				if rendition.Variant != variants[i] || rendition.ContentType != wantType {
+					t.Errorf("rendition %d is %s %s, want %s %s", i, rendition.Variant, rendition.ContentType, variants[i], wantType)
+				}
+				config, format, err := image.DecodeConfig(bytes.NewReader(rendition.Data))
+				if err != nil {
+					t.Fatalf("%s doesn't decode: %v", rendition.Variant, err)
+				}
+				if "image/"+format != wantType {
+					t.Errorf("%s is encoded as %s, want %s", rendition.Variant, format, wantType)
+				}
+				want := test.sizes[i]
+				if config.Width != want.width || config.Height != want.height || rendition.Width != want.width || rendition.Height != want.height {
+					t.Errorf("%s is %dx%d (reported %dx%d), want %dx%d", rendition.Variant, config.Width, config.Height,
+						rendition.Width, rendition.Height, want.width, want.height)
+				}
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	prefix, err := KeyPrefix(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^media/products/42/[0-9a-f]{16}$`).MatchString(prefix) {
+		t.Errorf("got prefix %q, want media/products/42/ and 16 hex digits", prefix)
+	}
+	if other, err := KeyPrefix(42); err != nil || other == prefix {
+		t.Errorf("two uploads got the prefixes %q and %q, want different ones", prefix, other)
+	}
+	tests := []struct {
+		rendition Rendition
+		key       string
+	}{
+		{Rendition{Variant: "original", ContentType: "image/gif"}, prefix + "/original.gif"},
+		{Rendition{Variant: "thumbnail", ContentType: "image/png"}, prefix + "/thumbnail.png"},
+		{Rendition{Variant: "medium", ContentType: "image/jpeg"}, prefix + "/medium.jpg"},
+	}
+	for _, test := range tests {
+		if key := test.rendition.Key(prefix); key != test.key {
+			t.Errorf("%s %s: got key %q, want %q", test.rendition.Variant, test.rendition.ContentType, key, test.key)
+		}
+	}
+}
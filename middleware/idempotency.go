@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/repository"
+)
+
+// IdempotencyHeader is the header clients set to make retries of a request safe.
+const IdempotencyHeader = "Idempotency-Key"
+
+// idempotencyWait is how long a retry waits for a concurrent request with
+// the same key to finish before giving up with 409.
+const idempotencyWait = 5 * time.Second
+
+// recordingWriter keeps a copy of the response body.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// keepLocked extends the claim on record until done is closed, so a retry
+// can't take the key over from a request that is slow rather than dead. If
+// the lock is lost anyway, the request's context is cancelled.
+func keepLocked(keys repository.IdempotencyRepository, record *models.IdempotencyKey, done <-chan struct{}, cancel context.CancelFunc) {
+	interval := database.IdempotencyLockTimeout / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			ctx, cancelExtend := context.WithTimeout(context.Background(), interval)
+			err := keys.Extend(ctx, record)
+			cancelExtend()
+			if err != nil {
+				log.Println("Failed to extend idempotency key:", err)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// Idempotency makes a mutating endpoint safe to retry. A request carrying an
+// Idempotency-Key header runs once per user and key; retries get the stored
+// response back, and reusing the key for a different request is rejected with
+// 422. The key stays locked while the handler runs, so a retry of a slow
+// request waits for it instead of running it twice. Requests without the
+// header are handled as usual. It must run after Authentication.
+func Idempotency(keys repository.IdempotencyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId := c.GetInt64("uid")
+		var record *models.IdempotencyKey
+		var claimed bool
+		deadline := time.Now().Add(idempotencyWait)
+		for {
+			record, claimed, err = keys.Claim(ctx, userId, key, requestHash)
+			if err != database.ErrIdempotencyKeyInProgress || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		switch {
+		case err == database.ErrIdempotencyKeyReused:
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case err == database.ErrIdempotencyKeyInProgress:
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			log.Println("Failed to claim idempotency key:", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+			return
+		case !claimed:
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.StatusCode, record.ContentType, record.Response)
+			c.Abort()
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		handlerCtx, cancelHandler := context.WithCancel(c.Request.Context())
+		defer cancelHandler()
+		c.Request = c.Request.WithContext(handlerCtx)
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			keepLocked(keys, record, done, cancelHandler)
+		}()
+		c.Next()
+		close(done)
+		<-stopped
+
+		// The handler may have used up the claim's timeout; saving the
+		// outcome gets its own.
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		// Server errors are not replayed so the client can retry them.
+		if writer.Status() >= http.StatusInternalServerError {
+			err = keys.Release(ctx, record)
+		} else {
+			err = keys.Complete(ctx, record, writer.Status(), writer.Header().Get("Content-Type"), writer.body.Bytes())
+		}
+		if err != nil {
+			log.Println("Failed to save idempotency key:", err)
+		}
+	}
+}
@@ -1,27 +1,40 @@
 package middleware
 
 import (
-	"fmt"
-
 	"github.com/gin-gonic/gin"
 	"githum.com/muhammadAslam/ecommerce/tokens"
 )
 
-func Authentication() gin.HandlerFunc {
+// Authentication lets through requests carrying a valid access token in the
+// token header and stores its claims in the context.
+func Authentication(manager *tokens.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ClientToken := c.Request.Header.Get("token")
 		if ClientToken == "" {
 			c.AbortWithStatusJSON(401, gin.H{"error": "Unauthorized"})
 			return
 		}
-		claims, err := tokens.ValidateToken(ClientToken)
+		claims, err := manager.ValidateToken(ClientToken)
 		if err != nil {
 			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid token"})
 			return
 		}
+		c.Set("uid", claims.Uid)
 		c.Set("email", claims.Email)
 		c.Set("name", claims.Name)
-		fmt.Printf("Valid token! Email: %s, Name: %s\n", claims.Email, claims.Name)
+		c.Set("roles", claims.Roles)
+		c.Next()
+	}
+}
+
+// Admin only lets through users whose token carries the admin role. It must
+// run after Authentication.
+func Admin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("roles") != "admin" {
+			c.AbortWithStatusJSON(403, gin.H{"error": "Forbidden"})
+			return
+		}
 		c.Next()
 	}
 }
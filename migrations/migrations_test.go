@@ -0,0 +1,90 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, migration := range migrations {
+		if migration.Version != int64(i+1) {
+			t.Errorf("migration %s: got version %d, want %d", migration, migration.Version, i+1)
+		}
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			t.Errorf("migration %s has an empty up or down file", migration)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := func(sql string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(sql)} }
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  []string
+		err   string
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"0010_later.up.sql":     file("SELECT 10"),
+				"0010_later.down.sql":   file("SELECT -10"),
+				"0002_earlier.up.sql":   file("SELECT 2"),
+				"0002_earlier.down.sql": file("SELECT -2"),
+			},
+			want: []string{"0002_earlier", "0010_later"},
+		},
+		{
+			name:  "missing down",
+			files: fstest.MapFS{"0001_first.up.sql": file("SELECT 1")},
+			err:   "needs both an up and a down file",
+		},
+		{
+			name: "conflicting names",
+			files: fstest.MapFS{
+				"0001_first.up.sql":   file("SELECT 1"),
+				"0001_other.down.sql": file("SELECT -1"),
+			},
+			err: "is named both",
+		},
+		{
+			name:  "bad name",
+			files: fstest.MapFS{"first.sql": file("SELECT 1")},
+			err:   "unexpected migration file",
+		},
+		{
+			name:  "version zero",
+			files: fstest.MapFS{"0000_zero.up.sql": file("SELECT 0")},
+			err:   "invalid migration version",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			migrations, err := load(test.files)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, migration := range migrations {
+				got = append(got, migration.String())
+			}
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
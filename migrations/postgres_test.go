@@ -0,0 +1,121 @@
+//go:build postgres
+
+package migrations
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// TestMigrationsOnPostgres runs the migrations against a real database and
+// compares the result with the models. It needs an empty Postgres database:
+//
+//	TEST_DATABASE_DSN="host=localhost user=postgres dbname=ecommerce_test" go test -tags postgres ./migrations
+func TestMigrationsOnPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	if tables := userTables(t, db); len(tables) > 0 {
+		t.Fatalf("database is not empty, it has the tables %v", tables)
+	}
+	ctx := context.Background()
+	migrator, err := New(sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := migrator.Down(ctx, len(migrator.migrations)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	checkDatabaseSchema(t, db)
+	if err := migrator.Check(ctx); err != nil {
+		t.Errorf("after migrating up: %v", err)
+	}
+
+	// Every down file undoes its up file, so the migrations can run again.
+	if _, err := migrator.Down(ctx, len(migrator.migrations)); err != nil {
+		t.Fatal(err)
+	}
+	if tables := userTables(t, db); len(tables) > 0 {
+		t.Errorf("after migrating down the tables %v are left", tables)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	checkDatabaseSchema(t, db)
+}
+
+// userTables lists the tables in the current schema other than
+// schema_migrations.
+func userTables(t *testing.T, db *gorm.DB) []string {
+	t.Helper()
+	var tables []string
+	err := db.Raw("SELECT table_name FROM information_schema.tables " +
+		"WHERE table_schema = current_schema() AND table_name <> 'schema_migrations' ORDER BY table_name").
+		Scan(&tables).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tables
+}
+
+// checkDatabaseSchema reports the tables, columns and indexes of the models
+// that the database lacks, and columns no model field maps to.
+func checkDatabaseSchema(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	cache := &sync.Map{}
+	for _, model := range models.All() {
+		parsed, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !db.Migrator().HasTable(model) {
+			t.Errorf("table %s is missing", parsed.Table)
+			continue
+		}
+		columnTypes, err := db.Migrator().ColumnTypes(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		columns := map[string]bool{}
+		for _, column := range columnTypes {
+			columns[column.Name()] = true
+			if parsed.LookUpField(column.Name()) == nil {
+				t.Errorf("table %s has the column %s no model field maps to", parsed.Table, column.Name())
+			}
+		}
+		for _, column := range parsed.DBNames {
+			if !columns[column] {
+				t.Errorf("table %s is missing the column %s", parsed.Table, column)
+			}
+		}
+		for name := range parsed.ParseIndexes() {
+			if !db.Migrator().HasIndex(model, name) {
+				t.Errorf("table %s is missing the index %s", parsed.Table, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package migrations
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	createTable = regexp.MustCompile(`(?s)CREATE TABLE IF NOT EXISTS (\w+) \((.*?)\n\);`)
+	dropTable   = regexp.MustCompile(`DROP TABLE (?:IF EXISTS )?(\w+)`)
+	addColumn   = regexp.MustCompile(`ALTER TABLE (\w+) ADD COLUMN (?:IF NOT EXISTS )?(\w+)`)
+	dropColumn  = regexp.MustCompile(`ALTER TABLE (\w+) DROP COLUMN (?:IF EXISTS )?(\w+)`)
+	createIndex = regexp.MustCompile(`CREATE (?:UNIQUE )?INDEX (?:IF NOT EXISTS )?(\w+) ON (\w+)`)
+	dropIndex   = regexp.MustCompile(`DROP INDEX (?:IF EXISTS )?(\w+)`)
+)
+
+// tableSchema is the columns and indexes of a table.
+type tableSchema struct {
+	columns map[string]bool
+	indexes map[string]bool
+}
+
+// migratedSchema replays the DDL of the up migrations, in order, into the
+// tables, columns and indexes they leave behind.
+func migratedSchema(t *testing.T) map[string]*tableSchema {
+	t.Helper()
+	migrations, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := map[string]*tableSchema{}
+	indexTable := map[string]string{}
+	for _, migration := range migrations {
+		for _, statement := range strings.Split(migration.Up, ";") {
+			statement = strings.TrimSpace(statement) + ";"
+			switch {
+			case createTable.MatchString(statement):
+				match := createTable.FindStringSubmatch(statement)
+				table := &tableSchema{columns: map[string]bool{}, indexes: map[string]bool{}}
+				for _, line := range strings.Split(match[2], "\n") {
+					fields := strings.Fields(line)
+					if len(fields) == 0 {
+						continue
+					}
+					switch strings.ToUpper(fields[0]) {
+					case "CONSTRAINT", "PRIMARY", "UNIQUE", "FOREIGN", "CHECK":
+						continue
+					}
+					table.columns[fields[0]] = true
+				}
+				tables[match[1]] = table
+			case dropTable.MatchString(statement):
+				delete(tables, dropTable.FindStringSubmatch(statement)[1])
+			case addColumn.MatchString(statement):
+				match := addColumn.FindStringSubmatch(statement)
+				tables[match[1]].columns[match[2]] = true
+			case dropColumn.MatchString(statement):
+				match := dropColumn.FindStringSubmatch(statement)
+				delete(tables[match[1]].columns, match[2])
+			case createIndex.MatchString(statement):
+				match := createIndex.FindStringSubmatch(statement)
+				tables[match[2]].indexes[match[1]] = true
+				indexTable[match[1]] = match[2]
+			case dropIndex.MatchString(statement):
+				name := dropIndex.FindStringSubmatch(statement)[1]
+				delete(tables[indexTable[name]].indexes, name)
+			}
+		}
+	}
+	return tables
+}
+
+// TestMigrationsMatchModels checks that the migrations build the schema the
+// models describe: the tests create their SQLite schema from the models, so a
+// column or index missing from a migration would only show up in
+// production.
+func TestMigrationsMatchModels(t *testing.T) {
+	tables := migratedSchema(t)
+	cache := &sync.Map{}
+	seen := map[string]bool{}
+	for _, model := range models.All() {
+		parsed, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[parsed.Table] = true
+		table, ok := tables[parsed.Table]
+		if !ok {
+			t.Errorf("no migration creates the table %s", parsed.Table)
+			continue
+		}
+		var missing, extra []string
+		for _, column := range parsed.DBNames {
+			if !table.columns[column] {
+				missing = append(missing, column)
+			}
+		}
+		for column := range table.columns {
+			if parsed.LookUpField(column) == nil {
+				extra = append(extra, column)
+			}
+		}
+		for name := range parsed.ParseIndexes() {
+			if !table.indexes[name] {
+				missing = append(missing, "index "+name)
+			}
+		}
+		sort.Strings(missing)
+		sort.Strings(extra)
+		if len(missing) > 0 {
+			t.Errorf("table %s is missing %s", parsed.Table, strings.Join(missing, ", "))
+		}
+		if len(extra) > 0 {
+			t.Errorf("table %s has columns no model field maps to: %s", parsed.Table, strings.Join(extra, ", "))
+		}
+	}
+	for name := range tables {
+		if !seen[name] && name != "schema_migrations" {
+			t.Errorf("table %s has no model", name)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+DROP TABLE IF EXISTS wishlist_items;
+DROP TABLE IF EXISTS wishlists;
+DROP TABLE IF EXISTS review_reports;
+DROP TABLE IF EXISTS review_votes;
+DROP TABLE IF EXISTS reviews;
+DROP TABLE IF EXISTS import_job_errors;
+DROP TABLE IF EXISTS import_jobs;
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS price_schedules;
+DROP TABLE IF EXISTS price_changes;
+DROP TABLE IF EXISTS stock_movements;
+DROP TABLE IF EXISTS stock_levels;
+DROP TABLE IF EXISTS warehouses;
+DROP TABLE IF EXISTS reservations;
+DROP TABLE IF EXISTS payments;
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS addresses;
+DROP TABLE IF EXISTS guest_cart_items;
+DROP TABLE IF EXISTS guest_carts;
+DROP TABLE IF EXISTS user_products;
+DROP TABLE IF EXISTS product_attributes;
+DROP TABLE IF EXISTS attribute_definitions;
+DROP TABLE IF EXISTS product_images;
+DROP TABLE IF EXISTS product_files;
+DROP TABLE IF EXISTS bundle_components;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,556 @@
+-- The schema as the models described it when migrations were introduced.
+-- Tables and indexes are only created when missing, so databases that were
+-- set up by the earlier automatic migration are adopted as they are.
+
+CREATE TABLE IF NOT EXISTS users (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	name text NOT NULL,
+	email text NOT NULL,
+	phone text NOT NULL,
+	password text NOT NULL,
+	roles text NOT NULL,
+	token text,
+	refresh_token text
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_phone ON users (phone);
+
+CREATE TABLE IF NOT EXISTS categories (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	name text NOT NULL,
+	slug text NOT NULL,
+	CONSTRAINT uni_categories_slug UNIQUE (slug)
+);
+CREATE INDEX IF NOT EXISTS idx_categories_deleted_at ON categories (deleted_at);
+
+CREATE TABLE IF NOT EXISTS products (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	category_id bigint NOT NULL,
+	name text NOT NULL,
+	sku varchar(64),
+	description text NOT NULL,
+	price decimal NOT NULL,
+	compare_at_price decimal,
+	quantity bigint NOT NULL,
+	status varchar(16) NOT NULL DEFAULT 'published',
+	publish_at timestamptz,
+	unpublish_at timestamptz,
+	max_per_order bigint NOT NULL DEFAULT 0,
+	low_stock_threshold bigint NOT NULL DEFAULT 0,
+	low_stock_alerted boolean NOT NULL DEFAULT false,
+	stock_policy varchar(16) NOT NULL DEFAULT 'deny',
+	backorder_limit bigint NOT NULL DEFAULT 0,
+	restock_date timestamptz,
+	release_date timestamptz,
+	is_bundle boolean NOT NULL DEFAULT false,
+	bundle_pricing varchar(16) NOT NULL DEFAULT '',
+	bundle_discount decimal NOT NULL DEFAULT 0,
+	is_digital boolean NOT NULL DEFAULT false,
+	download_limit bigint NOT NULL DEFAULT 5,
+	image text,
+	rating bigint,
+	rating_average decimal NOT NULL DEFAULT 0,
+	rating_count bigint NOT NULL DEFAULT 0,
+	rating_star1 bigint NOT NULL DEFAULT 0,
+	rating_star2 bigint NOT NULL DEFAULT 0,
+	rating_star3 bigint NOT NULL DEFAULT 0,
+	rating_star4 bigint NOT NULL DEFAULT 0,
+	rating_star5 bigint NOT NULL DEFAULT 0,
+	CONSTRAINT fk_categories_products FOREIGN KEY (category_id) REFERENCES categories (id)
+);
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_products_sku ON products (sku);
+CREATE INDEX IF NOT EXISTS idx_products_status ON products (status);
+
+CREATE TABLE IF NOT EXISTS bundle_components (
+	id bigserial PRIMARY KEY,
+	bundle_id bigint NOT NULL,
+	component_id bigint NOT NULL,
+	quantity bigint NOT NULL,
+	CONSTRAINT fk_products_components FOREIGN KEY (bundle_id) REFERENCES products (id),
+	CONSTRAINT fk_bundle_components_component FOREIGN KEY (component_id) REFERENCES products (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bundle_components_bundle_component ON bundle_components (bundle_id, component_id);
+CREATE INDEX IF NOT EXISTS idx_bundle_components_component_id ON bundle_components (component_id);
+
+CREATE TABLE IF NOT EXISTS product_files (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	product_id bigint NOT NULL,
+	name text NOT NULL,
+	storage_key text NOT NULL,
+	content_type varchar(128),
+	size bigint NOT NULL,
+	CONSTRAINT fk_products_files FOREIGN KEY (product_id) REFERENCES products (id)
+);
+CREATE INDEX IF NOT EXISTS idx_product_files_deleted_at ON product_files (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_product_files_product_id ON product_files (product_id);
+
+CREATE TABLE IF NOT EXISTS product_images (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	product_id bigint NOT NULL,
+	position bigint NOT NULL DEFAULT 0,
+	alt_text text,
+	content_type varchar(64) NOT NULL,
+	width bigint NOT NULL,
+	height bigint NOT NULL,
+	original_key text NOT NULL,
+	thumbnail_key text NOT NULL,
+	medium_key text NOT NULL,
+	CONSTRAINT fk_products_images FOREIGN KEY (product_id) REFERENCES products (id)
+);
+CREATE INDEX IF NOT EXISTS idx_product_images_deleted_at ON product_images (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_product_images_product_id ON product_images (product_id);
+
+CREATE TABLE IF NOT EXISTS attribute_definitions (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	category_id bigint NOT NULL,
+	code varchar(64) NOT NULL,
+	name text NOT NULL,
+	type varchar(16) NOT NULL,
+	unit varchar(16),
+	options text,
+	required boolean NOT NULL DEFAULT false,
+	filterable boolean NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_attribute_definitions_deleted_at ON attribute_definitions (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_attribute_definitions_category_code ON attribute_definitions (category_id, code);
+
+CREATE TABLE IF NOT EXISTS product_attributes (
+	id bigserial PRIMARY KEY,
+	product_id bigint NOT NULL,
+	definition_id bigint NOT NULL,
+	text_value text,
+	number_value decimal,
+	bool_value boolean,
+	CONSTRAINT fk_products_attributes FOREIGN KEY (product_id) REFERENCES products (id),
+	CONSTRAINT fk_product_attributes_definition FOREIGN KEY (definition_id) REFERENCES attribute_definitions (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_attributes_product_definition ON product_attributes (product_id, definition_id);
+CREATE INDEX IF NOT EXISTS idx_product_attributes_definition_id ON product_attributes (definition_id);
+CREATE INDEX IF NOT EXISTS idx_product_attributes_text_value ON product_attributes (text_value);
+CREATE INDEX IF NOT EXISTS idx_product_attributes_number_value ON product_attributes (number_value);
+
+CREATE TABLE IF NOT EXISTS user_products (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	user_id bigint NOT NULL,
+	product_id bigint NOT NULL,
+	product_name text NOT NULL,
+	price decimal NOT NULL,
+	quantity bigint NOT NULL,
+	rating bigint,
+	image text,
+	CONSTRAINT fk_users_user_cart FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_user_products_deleted_at ON user_products (deleted_at);
+
+CREATE TABLE IF NOT EXISTS guest_carts (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	token text NOT NULL,
+	expires_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_guest_carts_deleted_at ON guest_carts (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_guest_carts_token ON guest_carts (token);
+CREATE INDEX IF NOT EXISTS idx_guest_carts_expires_at ON guest_carts (expires_at);
+
+CREATE TABLE IF NOT EXISTS guest_cart_items (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	guest_cart_id bigint NOT NULL,
+	product_id bigint NOT NULL,
+	product_name text NOT NULL,
+	price decimal NOT NULL,
+	quantity bigint NOT NULL,
+	image text,
+	CONSTRAINT fk_guest_carts_items FOREIGN KEY (guest_cart_id) REFERENCES guest_carts (id)
+);
+CREATE INDEX IF NOT EXISTS idx_guest_cart_items_deleted_at ON guest_cart_items (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_guest_cart_items_cart_product ON guest_cart_items (guest_cart_id, product_id);
+
+CREATE TABLE IF NOT EXISTS addresses (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	user_id bigint NOT NULL,
+	recipient_name text NOT NULL,
+	phone text,
+	street text NOT NULL,
+	city text NOT NULL,
+	state text,
+	postal_code text,
+	country text NOT NULL,
+	is_default_shipping boolean NOT NULL DEFAULT false,
+	is_default_billing boolean NOT NULL DEFAULT false,
+	CONSTRAINT fk_users_address_detail FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_addresses_deleted_at ON addresses (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_addresses_user_id ON addresses (user_id);
+
+CREATE TABLE IF NOT EXISTS orders (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	user_id bigint NOT NULL,
+	address_id bigint,
+	billing_address_id bigint,
+	shipping_recipient_name text,
+	shipping_phone text,
+	shipping_street text,
+	shipping_city text,
+	shipping_state text,
+	shipping_postal_code text,
+	shipping_country text,
+	billing_recipient_name text,
+	billing_phone text,
+	billing_street text,
+	billing_city text,
+	billing_state text,
+	billing_postal_code text,
+	billing_country text,
+	total_price decimal NOT NULL,
+	order_status text NOT NULL,
+	payment_method text NOT NULL,
+	backordered boolean NOT NULL DEFAULT false,
+	pre_order boolean NOT NULL DEFAULT false,
+	release_date timestamptz,
+	digital boolean NOT NULL DEFAULT false,
+	CONSTRAINT fk_users_orders FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders (deleted_at);
+
+CREATE TABLE IF NOT EXISTS order_items (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	user_id bigint NOT NULL,
+	order_id bigint NOT NULL,
+	product_id bigint NOT NULL,
+	product_name text NOT NULL DEFAULT '',
+	sku text,
+	image text,
+	quantity bigint,
+	price decimal,
+	backordered bigint NOT NULL DEFAULT 0,
+	pre_order boolean NOT NULL DEFAULT false,
+	is_bundle boolean NOT NULL DEFAULT false,
+	parent_item_id bigint,
+	is_digital boolean NOT NULL DEFAULT false,
+	download_limit bigint NOT NULL DEFAULT 0,
+	downloads bigint NOT NULL DEFAULT 0,
+	fulfilled_at timestamptz,
+	CONSTRAINT fk_users_order_items FOREIGN KEY (user_id) REFERENCES users (id),
+	CONSTRAINT fk_orders_items FOREIGN KEY (order_id) REFERENCES orders (id),
+	CONSTRAINT fk_products_order_items FOREIGN KEY (product_id) REFERENCES products (id),
+	CONSTRAINT fk_order_items_components FOREIGN KEY (parent_item_id) REFERENCES order_items (id)
+);
+CREATE INDEX IF NOT EXISTS idx_order_items_deleted_at ON order_items (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items (order_id);
+CREATE INDEX IF NOT EXISTS idx_order_items_parent_item_id ON order_items (parent_item_id);
+
+CREATE TABLE IF NOT EXISTS payments (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	order_id bigint NOT NULL,
+	payment_type text NOT NULL,
+	amount decimal NOT NULL,
+	status text NOT NULL DEFAULT 'captured',
+	captured_at timestamptz,
+	CONSTRAINT fk_payments_order FOREIGN KEY (order_id) REFERENCES orders (id)
+);
+CREATE INDEX IF NOT EXISTS idx_payments_deleted_at ON payments (deleted_at);
+
+CREATE TABLE IF NOT EXISTS reservations (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	order_id bigint NOT NULL,
+	product_id bigint NOT NULL,
+	sku varchar(64),
+	quantity bigint NOT NULL,
+	status text NOT NULL,
+	expires_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_reservations_deleted_at ON reservations (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_reservations_order_id ON reservations (order_id);
+CREATE INDEX IF NOT EXISTS idx_reservations_product_id ON reservations (product_id);
+CREATE INDEX IF NOT EXISTS idx_reservations_status ON reservations (status);
+CREATE INDEX IF NOT EXISTS idx_reservations_expires_at ON reservations (expires_at);
+
+CREATE TABLE IF NOT EXISTS warehouses (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	code varchar(32) NOT NULL,
+	name text NOT NULL,
+	priority bigint NOT NULL DEFAULT 0,
+	active boolean NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_warehouses_deleted_at ON warehouses (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_warehouses_code ON warehouses (code);
+
+CREATE TABLE IF NOT EXISTS stock_levels (
+	id bigserial PRIMARY KEY,
+	warehouse_id bigint NOT NULL,
+	product_id bigint NOT NULL,
+	quantity bigint NOT NULL DEFAULT 0,
+	updated_at timestamptz,
+	CONSTRAINT fk_stock_levels_warehouse FOREIGN KEY (warehouse_id) REFERENCES warehouses (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_levels_warehouse_product ON stock_levels (warehouse_id, product_id);
+CREATE INDEX IF NOT EXISTS idx_stock_levels_product_id ON stock_levels (product_id);
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+	id bigserial PRIMARY KEY,
+	warehouse_id bigint NOT NULL,
+	product_id bigint NOT NULL,
+	quantity bigint NOT NULL,
+	type varchar(16) NOT NULL,
+	reason text NOT NULL DEFAULT '',
+	reference varchar(64),
+	order_id bigint,
+	created_by bigint NOT NULL DEFAULT 0,
+	created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_warehouse_id ON stock_movements (warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product_id ON stock_movements (product_id);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_reference ON stock_movements (reference);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_order_id ON stock_movements (order_id);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_created_at ON stock_movements (created_at);
+
+CREATE TABLE IF NOT EXISTS price_changes (
+	id bigserial PRIMARY KEY,
+	product_id bigint NOT NULL,
+	old_price decimal NOT NULL,
+	new_price decimal NOT NULL,
+	reason varchar(16) NOT NULL,
+	changed_by bigint NOT NULL DEFAULT 0,
+	created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_price_changes_product_created ON price_changes (product_id, created_at);
+
+CREATE TABLE IF NOT EXISTS price_schedules (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	product_id bigint NOT NULL,
+	price decimal NOT NULL,
+	regular_price decimal NOT NULL DEFAULT 0,
+	starts_at timestamptz NOT NULL,
+	ends_at timestamptz NOT NULL,
+	status varchar(16) NOT NULL,
+	created_by bigint NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_price_schedules_deleted_at ON price_schedules (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_price_schedules_product_id ON price_schedules (product_id);
+CREATE INDEX IF NOT EXISTS idx_price_schedules_starts_at ON price_schedules (starts_at);
+CREATE INDEX IF NOT EXISTS idx_price_schedules_ends_at ON price_schedules (ends_at);
+CREATE INDEX IF NOT EXISTS idx_price_schedules_status ON price_schedules (status);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	user_id bigint,
+	key varchar(255),
+	request_hash varchar(64) NOT NULL,
+	status_code bigint NOT NULL DEFAULT 0,
+	content_type text,
+	response bytea,
+	locked_until timestamptz,
+	expires_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_deleted_at ON idempotency_keys (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_keys_user_key ON idempotency_keys (user_id, key);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+
+CREATE TABLE IF NOT EXISTS import_jobs (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	format varchar(8) NOT NULL,
+	dry_run boolean NOT NULL DEFAULT false,
+	status varchar(16) NOT NULL,
+	total_rows bigint NOT NULL DEFAULT 0,
+	processed_rows bigint NOT NULL DEFAULT 0,
+	created_rows bigint NOT NULL DEFAULT 0,
+	updated_rows bigint NOT NULL DEFAULT 0,
+	failed_rows bigint NOT NULL DEFAULT 0,
+	created_by bigint NOT NULL DEFAULT 0,
+	started_at timestamptz,
+	finished_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_import_jobs_deleted_at ON import_jobs (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_import_jobs_status ON import_jobs (status);
+
+CREATE TABLE IF NOT EXISTS import_job_errors (
+	id bigserial PRIMARY KEY,
+	import_job_id bigint NOT NULL,
+	line bigint NOT NULL,
+	sku varchar(64),
+	message text NOT NULL,
+	CONSTRAINT fk_import_jobs_errors FOREIGN KEY (import_job_id) REFERENCES import_jobs (id)
+);
+CREATE INDEX IF NOT EXISTS idx_import_job_errors_import_job_id ON import_job_errors (import_job_id);
+
+CREATE TABLE IF NOT EXISTS reviews (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	user_id bigint NOT NULL,
+	product_id bigint NOT NULL,
+	rating bigint NOT NULL,
+	title text,
+	comment text NOT NULL,
+	helpful_count bigint NOT NULL DEFAULT 0,
+	status text NOT NULL DEFAULT 'approved',
+	moderation_reason text,
+	moderated_by bigint,
+	moderated_at timestamptz,
+	report_count bigint NOT NULL DEFAULT 0,
+	CONSTRAINT fk_users_reviews FOREIGN KEY (user_id) REFERENCES users (id),
+	CONSTRAINT fk_reviews_product FOREIGN KEY (product_id) REFERENCES products (id)
+);
+CREATE INDEX IF NOT EXISTS idx_reviews_deleted_at ON reviews (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reviews_user_product ON reviews (user_id, product_id);
+CREATE INDEX IF NOT EXISTS idx_reviews_status ON reviews (status);
+
+CREATE TABLE IF NOT EXISTS review_votes (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	review_id bigint NOT NULL,
+	user_id bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_review_votes_deleted_at ON review_votes (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_review_votes_review_user ON review_votes (review_id, user_id);
+
+CREATE TABLE IF NOT EXISTS review_reports (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	review_id bigint NOT NULL,
+	user_id bigint NOT NULL,
+	reason text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_review_reports_deleted_at ON review_reports (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_review_reports_review_user ON review_reports (review_id, user_id);
+
+CREATE TABLE IF NOT EXISTS wishlists (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	user_id bigint NOT NULL,
+	name text NOT NULL,
+	share_token text
+);
+CREATE INDEX IF NOT EXISTS idx_wishlists_deleted_at ON wishlists (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_wishlists_user_id ON wishlists (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wishlists_share_token ON wishlists (share_token);
+
+CREATE TABLE IF NOT EXISTS wishlist_items (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	wishlist_id bigint NOT NULL,
+	product_id bigint NOT NULL,
+	notify_back_in_stock boolean NOT NULL DEFAULT false,
+	notify_price_drop boolean NOT NULL DEFAULT false,
+	last_seen_price decimal NOT NULL DEFAULT 0,
+	last_seen_in_stock boolean NOT NULL DEFAULT false,
+	CONSTRAINT fk_wishlists_items FOREIGN KEY (wishlist_id) REFERENCES wishlists (id),
+	CONSTRAINT fk_wishlist_items_product FOREIGN KEY (product_id) REFERENCES products (id)
+);
+CREATE INDEX IF NOT EXISTS idx_wishlist_items_deleted_at ON wishlist_items (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wishlist_items_wishlist_product ON wishlist_items (wishlist_id, product_id);
+
+-- Orders placed before addresses and products were copied onto them get
+-- their copies from the address book and the catalogue.
+UPDATE orders SET (
+	shipping_recipient_name, shipping_phone, shipping_street, shipping_city,
+	shipping_state, shipping_postal_code, shipping_country,
+	billing_recipient_name, billing_phone, billing_street, billing_city,
+	billing_state, billing_postal_code, billing_country
+) = (
+	SELECT s.recipient_name, s.phone, s.street, s.city, s.state, s.postal_code, s.country,
+		CASE WHEN b.id IS NULL THEN s.recipient_name ELSE b.recipient_name END,
+		CASE WHEN b.id IS NULL THEN s.phone ELSE b.phone END,
+		CASE WHEN b.id IS NULL THEN s.street ELSE b.street END,
+		CASE WHEN b.id IS NULL THEN s.city ELSE b.city END,
+		CASE WHEN b.id IS NULL THEN s.state ELSE b.state END,
+		CASE WHEN b.id IS NULL THEN s.postal_code ELSE b.postal_code END,
+		CASE WHEN b.id IS NULL THEN s.country ELSE b.country END
+	FROM addresses s
+	LEFT JOIN addresses b ON b.id = orders.billing_address_id
+	WHERE s.id = orders.address_id
+)
+WHERE (shipping_street IS NULL OR shipping_street = '')
+	AND EXISTS (SELECT 1 FROM addresses WHERE addresses.id = orders.address_id);
+
+UPDATE order_items SET product_name = products.name, sku = products.sku, image = products.image
+FROM products
+WHERE products.id = order_items.product_id AND order_items.product_name = '';
+
+-- Stock lives in warehouses: create the default one and book the stock of
+-- products that predate warehouses into it as opening balances.
+INSERT INTO warehouses (created_at, updated_at, code, name, priority, active)
+SELECT now(), now(), 'MAIN', 'Main warehouse', 0, true
+WHERE NOT EXISTS (SELECT 1 FROM warehouses);
+
+WITH main AS (
+	SELECT id FROM warehouses
+	WHERE active AND deleted_at IS NULL
+	ORDER BY priority, id
+	LIMIT 1
+), opening AS (
+	SELECT id, quantity FROM products
+	WHERE quantity > 0 AND deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM stock_levels WHERE stock_levels.product_id = products.id)
+), movements AS (
+	INSERT INTO stock_movements (warehouse_id, product_id, quantity, type, reason, created_at)
+	SELECT main.id, opening.id, opening.quantity, 'adjustment', 'opening balance', now()
+	FROM main, opening
+)
+INSERT INTO stock_levels (warehouse_id, product_id, quantity, updated_at)
+SELECT main.id, opening.id, opening.quantity, now()
+FROM main, opening;
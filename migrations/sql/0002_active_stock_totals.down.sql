@@ -0,0 +1,4 @@
+UPDATE products SET quantity = COALESCE((
+    SELECT SUM(stock_levels.quantity) FROM stock_levels
+    WHERE stock_levels.product_id = products.id
+), 0);
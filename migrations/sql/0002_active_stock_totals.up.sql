@@ -0,0 +1,6 @@
+-- Product totals count only the stock at active warehouses.
+UPDATE products SET quantity = COALESCE((
+    SELECT SUM(stock_levels.quantity) FROM stock_levels
+    JOIN warehouses ON warehouses.id = stock_levels.warehouse_id AND warehouses.active AND warehouses.deleted_at IS NULL
+    WHERE stock_levels.product_id = products.id
+), 0);
@@ -0,0 +1,2 @@
+ALTER TABLE price_schedules DROP COLUMN IF EXISTS regular_compare_at_price;
+ALTER TABLE price_schedules DROP COLUMN IF EXISTS compare_at_price;
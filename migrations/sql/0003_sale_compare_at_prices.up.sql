@@ -0,0 +1,5 @@
+ALTER TABLE price_schedules ADD COLUMN IF NOT EXISTS compare_at_price decimal;
+ALTER TABLE price_schedules ADD COLUMN IF NOT EXISTS regular_compare_at_price decimal;
+
+-- Running sales showed their regular price as the "was" price.
+UPDATE price_schedules SET compare_at_price = regular_price WHERE status = 'active';
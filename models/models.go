@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/dgrijalva/jwt-go"
 	"gorm.io/gorm"
 )
@@ -23,14 +25,32 @@ type User struct {
 	OrderItems    []OrderItem   `gorm:"foreignKey:UserID"`
 }
 
+// OrderItem keeps a copy of the product as it was sold, so renaming,
+// repricing or deleting the product never changes a past order.
 type OrderItem struct {
 	gorm.Model
-	ID        int64 `gorm:"primary_key"`
-	UserID    int64 `gorm:"not null"`
-	OrderID   int64 `gorm:"not null"`
-	ProductID int64 `gorm:"not null"`
-	Quantity  int
-	Price     float64
+	ID          int64  `gorm:"primary_key"`
+	UserID      int64  `gorm:"not null"`
+	OrderID     int64  `gorm:"not null;index"`
+	ProductID   int64  `gorm:"not null"`
+	ProductName string `gorm:"not null;default:''"`
+	SKU         string `gorm:"null"`
+	Image       string `gorm:"null"`
+	Quantity    int
+	Price       float64 // unit price at purchase time
+	Backordered int     `gorm:"not null;default:0"` // units still waiting for stock
+	PreOrder    bool    `gorm:"not null;default:false"`
+	// A bundle is sold as one priced item whose components are listed as
+	// zero-priced child items; stock is only taken from the components.
+	IsBundle     bool        `gorm:"not null;default:false"`
+	ParentItemID *int64      `gorm:"index"`
+	Components   []OrderItem `gorm:"foreignKey:ParentItemID"`
+	// Digital items are fulfilled when the payment is captured and then
+	// downloaded through signed links, up to DownloadLimit times.
+	IsDigital     bool       `gorm:"not null;default:false"`
+	DownloadLimit int        `gorm:"not null;default:0"`
+	Downloads     int        `gorm:"not null;default:0"`
+	FulfilledAt   *time.Time `gorm:"null"`
 }
 
 type Category struct {
@@ -43,16 +63,118 @@ type Category struct {
 
 type Product struct {
 	gorm.Model
-	ID          int64       `gorm:"primary_key"`
-	CategoryID  int64       `gorm:"not null"`
-	Category    Category    `gorm:"foreignKey:CategoryID"`
-	Name        string      `gorm:"not null"`
-	Description string      `gorm:"not null"`
-	Price       float64     `gorm:"not null"`
-	Quantity    int         `gorm:"not null"`
-	Image       string      `gorm:"null"`
-	Rating      int         `gorm:"null"`
-	OrderItems  []OrderItem `gorm:"foreignKey:ProductID"`
+	ID                int64              `gorm:"primary_key"`
+	CategoryID        int64              `gorm:"not null"`
+	Category          Category           `gorm:"foreignKey:CategoryID"`
+	Name              string             `gorm:"not null"`
+	SKU               string             `gorm:"size:64;index"`
+	Description       string             `gorm:"not null"`
+	Price             float64            `gorm:"not null"`
+	CompareAtPrice    *float64           `gorm:"null"` // the "was" price shown next to a reduced Price
+	Quantity          int                `gorm:"not null"`
+	Status            string             `gorm:"size:16;not null;default:'published';index"` // only published products are public
+	PublishAt         *time.Time         `gorm:"null"`                                       // when a scheduled product goes live
+	UnpublishAt       *time.Time         `gorm:"null"`                                       // when a published product is archived
+	MaxPerOrder       int                `gorm:"not null;default:0"`                         // 0 means no limit
+	LowStockThreshold int                `gorm:"not null;default:0"`                         // alert admins at or below this; 0 disables
+	LowStockAlerted   bool               `gorm:"not null;default:false"`                     // set until stock recovers above the threshold
+	StockPolicy       string             `gorm:"size:16;not null;default:'deny'"`
+	BackorderLimit    int                `gorm:"not null;default:0"` // units sellable beyond stock; 0 means no pre-order cap
+	RestockDate       *time.Time         `gorm:"null"`               // supplier's promised restock date for backorders
+	ReleaseDate       *time.Time         `gorm:"null"`               // pre-orders are taken until this date
+	IsBundle          bool               `gorm:"not null;default:false"`
+	BundlePricing     string             `gorm:"size:16;not null;default:''"` // fixed or discount
+	BundleDiscount    float64            `gorm:"not null;default:0"`          // percent off the components' total
+	Components        []BundleComponent  `gorm:"foreignKey:BundleID"`
+	IsDigital         bool               `gorm:"not null;default:false"` // delivered as downloads, never shipped
+	DownloadLimit     int                `gorm:"not null;default:5"`     // downloads per purchase; 0 means no limit
+	Files             []ProductFile      `gorm:"foreignKey:ProductID"`
+	Images            []ProductImage     `gorm:"foreignKey:ProductID"`
+	Attributes        []ProductAttribute `gorm:"foreignKey:ProductID"`
+	Image             string             `gorm:"null"`
+	Rating            int                `gorm:"null"` // average review rating, rounded
+	Ratings           RatingSummary      `gorm:"embedded;embeddedPrefix:rating_"`
+	OrderItems        []OrderItem        `gorm:"foreignKey:ProductID"`
+}
+
+// BundleComponent is a product contained in a bundle product. A bundle has
+// no stock of its own; it is available as long as its components are.
+type BundleComponent struct {
+	ID          int64   `gorm:"primary_key"`
+	BundleID    int64   `gorm:"not null;uniqueIndex:idx_bundle_components_bundle_component"`
+	ComponentID int64   `gorm:"not null;uniqueIndex:idx_bundle_components_bundle_component;index"`
+	Component   Product `gorm:"foreignKey:ComponentID"`
+	Quantity    int     `gorm:"not null"`
+}
+
+// AttributeDefinition is a typed specification the products of a category
+// carry, such as "RAM" in GB or "Material". Code names it in filters, e.g.
+// ?filter=ram_gb>=16.
+type AttributeDefinition struct {
+	gorm.Model
+	ID         int64    `gorm:"primary_key"`
+	CategoryID int64    `gorm:"not null;uniqueIndex:idx_attribute_definitions_category_code"`
+	Code       string   `gorm:"size:64;not null;uniqueIndex:idx_attribute_definitions_category_code"`
+	Name       string   `gorm:"not null"`
+	Type       string   `gorm:"size:16;not null"` // text, number, boolean or enum
+	Unit       string   `gorm:"size:16"`
+	Options    []string `gorm:"serializer:json"` // allowed values of an enum
+	Required   bool     `gorm:"not null;default:false"`
+	Filterable bool     `gorm:"not null"`
+}
+
+// ProductAttribute is a product's value for an attribute definition. Only
+// the column of the definition's type is set, so filters can compare
+// numbers as numbers.
+type ProductAttribute struct {
+	ID           int64               `gorm:"primary_key"`
+	ProductID    int64               `gorm:"not null;uniqueIndex:idx_product_attributes_product_definition"`
+	DefinitionID int64               `gorm:"not null;uniqueIndex:idx_product_attributes_product_definition;index"`
+	Definition   AttributeDefinition `gorm:"foreignKey:DefinitionID"`
+	TextValue    *string             `gorm:"index"`
+	NumberValue  *float64            `gorm:"index"`
+	BoolValue    *bool
+}
+
+// ProductFile is a file a digital product delivers. The content lives in
+// the blob store under StorageKey.
+type ProductFile struct {
+	gorm.Model
+	ID          int64  `gorm:"primary_key"`
+	ProductID   int64  `gorm:"not null;index"`
+	Name        string `gorm:"not null"`
+	StorageKey  string `gorm:"not null"`
+	ContentType string `gorm:"size:128"`
+	Size        int64  `gorm:"not null"`
+}
+
+// ProductImage is an uploaded product image, stored with a thumbnail and
+// a medium variant. Position orders a product's images; the first one is
+// mirrored into Product.Image.
+type ProductImage struct {
+	gorm.Model
+	ID           int64  `gorm:"primary_key"`
+	ProductID    int64  `gorm:"not null;index"`
+	Position     int    `gorm:"not null;default:0"`
+	AltText      string `gorm:"null"`
+	ContentType  string `gorm:"size:64;not null"`
+	Width        int    `gorm:"not null"`
+	Height       int    `gorm:"not null"`
+	OriginalKey  string `gorm:"not null"`
+	ThumbnailKey string `gorm:"not null"`
+	MediumKey    string `gorm:"not null"`
+}
+
+// RatingSummary aggregates a product's reviews. It is recomputed whenever a
+// review is written or removed.
+type RatingSummary struct {
+	Average float64 `gorm:"not null;default:0"`
+	Count   int     `gorm:"not null;default:0"`
+	Star1   int     `gorm:"not null;default:0"`
+	Star2   int     `gorm:"not null;default:0"`
+	Star3   int     `gorm:"not null;default:0"`
+	Star4   int     `gorm:"not null;default:0"`
+	Star5   int     `gorm:"not null;default:0"`
 }
 
 type UserProduct struct {
@@ -67,50 +189,402 @@ type UserProduct struct {
 	Image       string  `gorm:"null"`
 }
 
+// GuestCart is an anonymous visitor's cart, identified by the id inside
+// their signed cart token. It is merged into the user's cart on login.
+type GuestCart struct {
+	gorm.Model
+	ID        int64           `gorm:"primary_key"`
+	Token     string          `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time       `gorm:"not null;index"`
+	Items     []GuestCartItem `gorm:"foreignKey:GuestCartID"`
+}
+
+type GuestCartItem struct {
+	gorm.Model
+	ID          int64   `gorm:"primary_key"`
+	GuestCartID int64   `gorm:"not null;uniqueIndex:idx_guest_cart_items_cart_product"`
+	ProductID   int64   `gorm:"not null;uniqueIndex:idx_guest_cart_items_cart_product"`
+	ProductName string  `gorm:"not null"`
+	Price       float64 `gorm:"not null"`
+	Quantity    int     `gorm:"not null"`
+	Image       string  `gorm:"null"`
+}
+
 type Address struct {
 	gorm.Model
-	ID      int64  `gorm:"primary_key"`
-	UserID  int64  `gorm:"not null"`
-	User    User   `gorm:"foreignKey:UserID"`
-	Street  string `gorm:"not null"`
-	City    string `gorm:"not null"`
-	State   string `gorm:"not null"`
-	Country string `gorm:"not null"`
+	ID                int64  `gorm:"primary_key"`
+	UserID            int64  `gorm:"not null;index"`
+	User              User   `gorm:"foreignKey:UserID"`
+	RecipientName     string `gorm:"not null"`
+	Phone             string `gorm:"null"`
+	Street            string `gorm:"not null"`
+	City              string `gorm:"not null"`
+	State             string `gorm:"null"`
+	PostalCode        string `gorm:"null"`
+	Country           string `gorm:"not null"`
+	IsDefaultShipping bool   `gorm:"not null;default:false"`
+	IsDefaultBilling  bool   `gorm:"not null;default:false"`
+}
+
+// AddressSnapshot is a frozen copy of an Address taken when an order is
+// placed.
+type AddressSnapshot struct {
+	RecipientName string
+	Phone         string
+	Street        string
+	City          string
+	State         string
+	PostalCode    string
+	Country       string
 }
 
+// Order stores copies of its addresses; AddressID and BillingAddressID only
+// record which address book entries they were taken from.
 type Order struct {
 	gorm.Model
-	ID            int64   `gorm:"primary_key"`
-	UserID        int64   `gorm:"not null"`
-	User          User    `gorm:"foreignKey:UserID"`
-	AddressID     int64   `gorm:"not null"`
-	Address       Address `gorm:"foreignKey:AddressID"`
-	TotalPrice    float64 `gorm:"not null"`
-	OrderStatus   string  `gorm:"not null"`
-	PaymentMethod string  `gorm:"not null"`
+	ID               int64           `gorm:"primary_key"`
+	UserID           int64           `gorm:"not null"`
+	User             User            `gorm:"foreignKey:UserID"`
+	AddressID        int64           `gorm:"null"`
+	BillingAddressID int64           `gorm:"null"`
+	ShippingAddress  AddressSnapshot `gorm:"embedded;embeddedPrefix:shipping_"`
+	BillingAddress   AddressSnapshot `gorm:"embedded;embeddedPrefix:billing_"`
+	Items            []OrderItem     `gorm:"foreignKey:OrderID"`
+	TotalPrice       float64         `gorm:"not null"`
+	OrderStatus      string          `gorm:"not null"`
+	PaymentMethod    string          `gorm:"not null"`
+	Backordered      bool            `gorm:"not null;default:false"` // some items wait for stock
+	PreOrder         bool            `gorm:"not null;default:false"`
+	ReleaseDate      *time.Time      `gorm:"null"`                   // a pre-order's payment is captured from this date on
+	Digital          bool            `gorm:"not null;default:false"` // only digital items, nothing to ship
 }
 
 type Payment struct {
 	gorm.Model
-	ID          int64   `gorm:"primary_key"`
-	OrderID     int64   `gorm:"not null"`
-	Order       Order   `gorm:"foreignKey:OrderID"`
-	PaymentType string  `gorm:"not null"`
-	Amount      float64 `gorm:"not null"`
+	ID          int64      `gorm:"primary_key"`
+	OrderID     int64      `gorm:"not null"`
+	Order       Order      `gorm:"foreignKey:OrderID"`
+	PaymentType string     `gorm:"not null"`
+	Amount      float64    `gorm:"not null"`
+	Status      string     `gorm:"not null;default:'captured'"`
+	CapturedAt  *time.Time `gorm:"null"`
+}
+
+// Reservation holds stock for an order between checkout and payment capture.
+// Active reservations count against a product's available stock until they
+// are converted into a deduction or released when they expire.
+type Reservation struct {
+	gorm.Model
+	ID        int64     `gorm:"primary_key"`
+	OrderID   int64     `gorm:"not null;index"`
+	ProductID int64     `gorm:"not null;index"`
+	SKU       string    `gorm:"size:64"`
+	Quantity  int       `gorm:"not null"`
+	Status    string    `gorm:"not null;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
 }
 
+// Warehouse is a location stock is kept and shipped from. Lower Priority
+// values are preferred when allocating orders.
+type Warehouse struct {
+	gorm.Model
+	ID       int64  `gorm:"primary_key"`
+	Code     string `gorm:"size:32;uniqueIndex;not null"`
+	Name     string `gorm:"not null"`
+	Priority int    `gorm:"not null;default:0"`
+	Active   bool   `gorm:"not null"`
+}
+
+// StockLevel is the stock of a product at one warehouse. It is derived from
+// the stock movement ledger; Product.Quantity caches the sum over the active
+// warehouses, so stock held at inactive ones is never sold.
+type StockLevel struct {
+	ID          int64     `gorm:"primary_key"`
+	WarehouseID int64     `gorm:"not null;uniqueIndex:idx_stock_levels_warehouse_product"`
+	Warehouse   Warehouse `gorm:"foreignKey:WarehouseID"`
+	ProductID   int64     `gorm:"not null;uniqueIndex:idx_stock_levels_warehouse_product;index"`
+	Quantity    int       `gorm:"not null;default:0"`
+	UpdatedAt   time.Time
+}
+
+// StockMovement is an entry in the append-only stock ledger. Quantity is
+// positive for stock coming in and negative for stock going out; the two
+// sides of a transfer share a Reference.
+type StockMovement struct {
+	ID          int64     `gorm:"primary_key"`
+	WarehouseID int64     `gorm:"not null;index"`
+	ProductID   int64     `gorm:"not null;index"`
+	Quantity    int       `gorm:"not null"`
+	Type        string    `gorm:"size:16;not null"`
+	Reason      string    `gorm:"not null;default:''"`
+	Reference   string    `gorm:"size:64;index"`
+	OrderID     *int64    `gorm:"index"`
+	CreatedBy   int64     `gorm:"not null;default:0"`
+	CreatedAt   time.Time `gorm:"index"`
+}
+
+// IdempotencyKey remembers the response to a request sent with an
+// Idempotency-Key header so that retries replay it instead of running twice.
+// StatusCode stays zero while the first request is still being handled.
+type IdempotencyKey struct {
+	gorm.Model
+	ID          int64  `gorm:"primary_key"`
+	UserID      int64  `gorm:"uniqueIndex:idx_idempotency_keys_user_key"`
+	Key         string `gorm:"size:255;uniqueIndex:idx_idempotency_keys_user_key"`
+	RequestHash string `gorm:"size:64;not null"`
+	StatusCode  int    `gorm:"not null;default:0"`
+	ContentType string
+	Response    []byte
+	LockedUntil time.Time
+	ExpiresAt   time.Time `gorm:"index"`
+}
+
+// Review is limited to one per user and product; deleting a review removes
+// it for good so the customer can write a new one.
 type Review struct {
 	gorm.Model
-	ID        int64   `gorm:"primary_key"`
-	UserID    int64   `gorm:"not null"`
-	User      User    `gorm:"foreignKey:UserID"`
-	ProductID int64   `gorm:"not null"`
-	Product   Product `gorm:"foreignKey:ProductID"`
-	Rating    int     `gorm:"not null"`
-	Comment   string  `gorm:"not null"`
+	ID           int64   `gorm:"primary_key"`
+	UserID       int64   `gorm:"not null;uniqueIndex:idx_reviews_user_product"`
+	User         User    `gorm:"foreignKey:UserID"`
+	ProductID    int64   `gorm:"not null;uniqueIndex:idx_reviews_user_product"`
+	Product      Product `gorm:"foreignKey:ProductID"`
+	Rating       int     `gorm:"not null"`
+	Title        string  `gorm:"null"`
+	Comment      string  `gorm:"not null"`
+	HelpfulCount int     `gorm:"not null;default:0"`
+	// Only approved reviews are shown publicly and counted in the product's
+	// rating. Reviews written before moderation existed default to approved.
+	Status           string     `gorm:"not null;default:approved;index"`
+	ModerationReason string     `gorm:"null"`
+	ModeratedBy      int64      `gorm:"null"`
+	ModeratedAt      *time.Time `gorm:"null"`
+	ReportCount      int        `gorm:"not null;default:0"`
+}
+
+// ReviewReport is a customer's complaint about a review, one per user.
+type ReviewReport struct {
+	gorm.Model
+	ID       int64  `gorm:"primary_key"`
+	ReviewID int64  `gorm:"not null;uniqueIndex:idx_review_reports_review_user"`
+	UserID   int64  `gorm:"not null;uniqueIndex:idx_review_reports_review_user"`
+	Reason   string `gorm:"not null"`
+}
+
+// ReviewVote records that a user found a review helpful.
+type ReviewVote struct {
+	gorm.Model
+	ID       int64 `gorm:"primary_key"`
+	ReviewID int64 `gorm:"not null;uniqueIndex:idx_review_votes_review_user"`
+	UserID   int64 `gorm:"not null;uniqueIndex:idx_review_votes_review_user"`
+}
+
+// Wishlist is a named list of products a user wants to keep an eye on. A
+// non-nil ShareToken makes it readable by anyone holding the link.
+type Wishlist struct {
+	gorm.Model
+	ID         int64          `gorm:"primary_key"`
+	UserID     int64          `gorm:"not null;index"`
+	Name       string         `gorm:"not null"`
+	ShareToken *string        `gorm:"null;uniqueIndex"`
+	Items      []WishlistItem `gorm:"foreignKey:WishlistID"`
+}
+
+// WishlistItem remembers the price and stock state the customer last saw so
+// the alert job can tell when a product comes back or gets cheaper.
+type WishlistItem struct {
+	gorm.Model
+	ID                int64   `gorm:"primary_key"`
+	WishlistID        int64   `gorm:"not null;uniqueIndex:idx_wishlist_items_wishlist_product"`
+	ProductID         int64   `gorm:"not null;uniqueIndex:idx_wishlist_items_wishlist_product"`
+	Product           Product `gorm:"foreignKey:ProductID"`
+	NotifyBackInStock bool    `gorm:"not null;default:false"`
+	NotifyPriceDrop   bool    `gorm:"not null;default:false"`
+	LastSeenPrice     float64 `gorm:"not null;default:0"`
+	LastSeenInStock   bool    `gorm:"not null;default:false"`
+}
+
+// PriceChange is an entry in a product's price history, written whenever
+// its price changes. ChangedBy is zero for changes made by the system.
+type PriceChange struct {
+	ID        int64     `gorm:"primary_key"`
+	ProductID int64     `gorm:"not null;index:idx_price_changes_product_created"`
+	OldPrice  float64   `gorm:"not null"`
+	NewPrice  float64   `gorm:"not null"`
+	Reason    string    `gorm:"size:16;not null"`
+	ChangedBy int64     `gorm:"not null;default:0"`
+	CreatedAt time.Time `gorm:"index:idx_price_changes_product_created"`
+}
+
+// PriceSchedule is a sale price that replaces a product's price between
+// StartsAt and EndsAt. RegularPrice is the price it replaced, restored when
+// the sale ends. CompareAtPrice is the "was" price the sale showed, and
+// RegularCompareAtPrice the one it replaced.
+type PriceSchedule struct {
+	gorm.Model
+	ID                    int64     `gorm:"primary_key"`
+	ProductID             int64     `gorm:"not null;index"`
+	Price                 float64   `gorm:"not null"`
+	RegularPrice          float64   `gorm:"not null;default:0"`
+	CompareAtPrice        *float64  `gorm:"null"`
+	RegularCompareAtPrice *float64  `gorm:"null"`
+	StartsAt              time.Time `gorm:"not null;index"`
+	EndsAt                time.Time `gorm:"not null;index"`
+	Status                string    `gorm:"size:16;not null;index"`
+	CreatedBy             int64     `gorm:"not null;default:0"`
+}
+
+// ImportJob tracks a bulk product import running in the background. A dry
+// run validates and applies every row but rolls it back.
+type ImportJob struct {
+	gorm.Model
+	ID            int64            `gorm:"primary_key"`
+	Format        string           `gorm:"size:8;not null"`
+	DryRun        bool             `gorm:"not null;default:false"`
+	Status        string           `gorm:"size:16;not null;index"`
+	TotalRows     int              `gorm:"not null;default:0"`
+	ProcessedRows int              `gorm:"not null;default:0"`
+	CreatedRows   int              `gorm:"not null;default:0"`
+	UpdatedRows   int              `gorm:"not null;default:0"`
+	FailedRows    int              `gorm:"not null;default:0"`
+	CreatedBy     int64            `gorm:"not null;default:0"`
+	StartedAt     *time.Time       `gorm:"null"`
+	FinishedAt    *time.Time       `gorm:"null"`
+	Errors        []ImportJobError `gorm:"foreignKey:ImportJobID"`
 }
+
+// ImportJobError is the reason one row of an import was rejected.
+type ImportJobError struct {
+	ID          int64  `gorm:"primary_key"`
+	ImportJobID int64  `gorm:"not null;index"`
+	Line        int    `gorm:"not null"`
+	SKU         string `gorm:"size:64"`
+	Message     string `gorm:"not null"`
+}
+
+// All returns one value of every model stored in the database, for code
+// that creates the schema from the models or checks it against them.
+func All() []interface{} {
+	return []interface{}{
+		&User{},
+		&Category{},
+		&Product{},
+		&BundleComponent{},
+		&ProductFile{},
+		&ProductImage{},
+		&AttributeDefinition{},
+		&ProductAttribute{},
+		&UserProduct{},
+		&GuestCart{},
+		&GuestCartItem{},
+		&Address{},
+		&Order{},
+		&OrderItem{},
+		&Payment{},
+		&Reservation{},
+		&Warehouse{},
+		&StockLevel{},
+		&StockMovement{},
+		&PriceChange{},
+		&PriceSchedule{},
+		&IdempotencyKey{},
+		&ImportJob{},
+		&ImportJobError{},
+		&Review{},
+		&ReviewVote{},
+		&ReviewReport{},
+		&Wishlist{},
+		&WishlistItem{},
+	}
+}
+
+const (
+	OrderStatusPendingPayment = "pending_payment"
+	OrderStatusOrdered        = "ordered"
+	OrderStatusShipped        = "shipped"
+	OrderStatusDelivered      = "delivered"
+	OrderStatusCancelled      = "cancelled"
+)
+
+const (
+	ProductStatusDraft     = "draft"
+	ProductStatusScheduled = "scheduled"
+	ProductStatusPublished = "published"
+	ProductStatusArchived  = "archived"
+)
+
+const (
+	PriceChangeCreated   = "created"
+	PriceChangeManual    = "manual"
+	PriceChangeImport    = "import"
+	PriceChangeBundle    = "bundle"
+	PriceChangeSaleStart = "sale_start"
+	PriceChangeSaleEnd   = "sale_end"
+)
+
+const (
+	PriceScheduleStatusPending   = "pending"
+	PriceScheduleStatusActive    = "active"
+	PriceScheduleStatusEnded     = "ended"
+	PriceScheduleStatusCancelled = "cancelled"
+)
+
+const (
+	BundlePricingFixed    = "fixed"
+	BundlePricingDiscount = "discount"
+)
+
+const (
+	StockPolicyDeny      = "deny"
+	StockPolicyBackorder = "backorder"
+	StockPolicyPreOrder  = "preorder"
+)
+
+const (
+	StockMovementReceipt    = "receipt"
+	StockMovementSale       = "sale"
+	StockMovementReturn     = "return"
+	StockMovementAdjustment = "adjustment"
+	StockMovementTransfer   = "transfer"
+)
+
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusCaptured  = "captured"
+	PaymentStatusCancelled = "cancelled"
+	PaymentStatusRefunded  = "refunded"
+)
+
+const (
+	ReservationStatusActive    = "active"
+	ReservationStatusConverted = "converted"
+	ReservationStatusReleased  = "released"
+)
+
+const (
+	AttributeTypeText    = "text"
+	AttributeTypeNumber  = "number"
+	AttributeTypeBoolean = "boolean"
+	AttributeTypeEnum    = "enum"
+)
+
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected"
+	ReviewStatusHidden   = "hidden"
+)
+
 type SignedDetails struct {
+	Uid   int64
 	Email string
 	Name  string
+	Roles string
 	jwt.StandardClaims
 }
@@ -0,0 +1,81 @@
+// Package moderation decides whether a new or edited review can be published
+// straight away or has to wait in the admin moderation queue.
+package moderation
+
+import (
+	"regexp"
+	"strings"
+
+	"githum.com/muhammadAslam/ecommerce/config"
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+// Rules configures automatic review moderation.
+type Rules struct {
+	// BannedWords send a review to the queue when any of them appears as a
+	// whole word, ignoring case.
+	BannedWords []string
+	// BlockLinks sends reviews containing URLs or domain names to the queue.
+	BlockLinks bool
+	// AutoApprove publishes reviews that trip no rule. When false every
+	// review waits for an admin.
+	AutoApprove bool
+	// ReportThreshold is the number of customer reports after which an
+	// approved review is pulled back into the queue. Zero disables it.
+	ReportThreshold int
+}
+
+// Decision is the outcome of checking a review against the rules.
+type Decision struct {
+	Status string
+	Reason string
+}
+
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.|\b[a-z0-9-]+\.(com|net|org|io|co|info|biz|ru|xyz|pk|in|uk)\b)`)
+
+// NewRules builds the rules from the reviews configuration. By default
+// reviews are auto-approved unless they contain links, and three reports
+// send a review back to the queue.
+func NewRules(cfg config.Reviews) Rules {
+	return Rules{
+		BannedWords:     cfg.BannedWords,
+		BlockLinks:      cfg.BlockLinks,
+		AutoApprove:     cfg.AutoApprove,
+		ReportThreshold: cfg.ReportThreshold,
+	}
+}
+
+// Evaluate decides the initial status of a review from its title and text.
+func (r Rules) Evaluate(title string, comment string) Decision {
+	text := title + "\n" + comment
+	if word, ok := r.bannedWord(text); ok {
+		return Decision{Status: models.ReviewStatusPending, Reason: "contains banned word \"" + word + "\""}
+	}
+	if r.BlockLinks && linkPattern.MatchString(text) {
+		return Decision{Status: models.ReviewStatusPending, Reason: "contains a link"}
+	}
+	if !r.AutoApprove {
+		return Decision{Status: models.ReviewStatusPending, Reason: "awaiting review"}
+	}
+	return Decision{Status: models.ReviewStatusApproved}
+}
+
+// Reported decides the status of an approved review after its reports-th
+// customer report: it goes back to the queue once the threshold is reached.
+func (r Rules) Reported(reports int) Decision {
+	if r.ReportThreshold > 0 && reports >= r.ReportThreshold {
+		return Decision{Status: models.ReviewStatusPending, Reason: "reported by customers"}
+	}
+	return Decision{Status: models.ReviewStatusApproved}
+}
+
+func (r Rules) bannedWord(text string) (string, bool) {
+	lower := strings.ToLower(text)
+	for _, word := range r.BannedWords {
+		pattern := `\b` + regexp.QuoteMeta(strings.ToLower(word)) + `\b`
+		if matched, _ := regexp.MatchString(pattern, lower); matched {
+			return word, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,69 @@
+package moderation
+
+import (
+	"testing"
+
+	"githum.com/muhammadAslam/ecommerce/config"
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+func TestEvaluate(t *testing.T) {
+	strict := Rules{BannedWords: []string{"scam", "Rip-off"}, BlockLinks: true, AutoApprove: true}
+	tests := []struct {
+		name    string
+		rules   Rules
+		title   string
+		comment string
+		status  string
+		reason  string
+	}{
+		{"clean review", strict, "Great kettle", "Boils fast and looks nice.", models.ReviewStatusApproved, ""},
+		{"banned word in the comment", strict, "Kettle", "This is a scam.", models.ReviewStatusPending, `contains banned word "scam"`},
+		{"banned word in the title", strict, "SCAM", "Broke in a week.", models.ReviewStatusPending, `contains banned word "scam"`},
+		{"banned phrase with punctuation", strict, "Kettle", "A total rip-off!", models.ReviewStatusPending, `contains banned word "Rip-off"`},
+		{"banned word inside another word", strict, "Kettle", "Scampi tastes better.", models.ReviewStatusApproved, ""},
+		{"url", strict, "Kettle", "Cheaper at https://example.com/kettle", models.ReviewStatusPending, "contains a link"},
+		{"www address", strict, "Kettle", "See www.example.org", models.ReviewStatusPending, "contains a link"},
+		{"bare domain", strict, "Kettle", "Buy it from kettles.pk instead", models.ReviewStatusPending, "contains a link"},
+		{"sentence end is no domain", strict, "Kettle", "It works.Really well.", models.ReviewStatusApproved, ""},
+		{"links allowed", Rules{AutoApprove: true}, "Kettle", "See https://example.com", models.ReviewStatusApproved, ""},
+		{"no auto approval", Rules{BlockLinks: true}, "Kettle", "Boils fast.", models.ReviewStatusPending, "awaiting review"},
+		{"banned word before auto approval", Rules{BannedWords: []string{"scam"}}, "Kettle", "A scam.", models.ReviewStatusPending, `contains banned word "scam"`},
+		{"defaults approve clean reviews", NewRules(config.Default().Reviews), "Kettle", "Boils fast.", models.ReviewStatusApproved, ""},
+		{"defaults hold links", NewRules(config.Default().Reviews), "Kettle", "http://example.com", models.ReviewStatusPending, "contains a link"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decision := test.rules.Evaluate(test.title, test.comment)
+			if decision.Status != test.status || decision.Reason != test.reason {
+				t.Errorf("got %+v, want status %q and reason %q", decision, test.status, test.reason)
+			}
+		})
+	}
+}
+
+func TestReported(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		reports   int
+		status    string
+	}{
+		{"below the threshold", 3, 2, models.ReviewStatusApproved},
+		{"at the threshold", 3, 3, models.ReviewStatusPending},
+		{"above the threshold", 3, 5, models.ReviewStatusPending},
+		{"threshold of one", 1, 1, models.ReviewStatusPending},
+		{"disabled", 0, 100, models.ReviewStatusApproved},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decision := Rules{ReportThreshold: test.threshold}.Reported(test.reports)
+			if decision.Status != test.status {
+				t.Errorf("got %+v, want status %q", decision, test.status)
+			}
+			if decision.Status == models.ReviewStatusPending && decision.Reason == "" {
+				t.Error("a review sent back to the queue got no reason")
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+// Package notify delivers messages to customers and staff. The transport
+// (email, SMS, push...) is chosen by the Notifier implementation wired in
+// main.
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// Notification is a message addressed to a single user.
+type Notification struct {
+	UserID  int64
+	Email   string
+	Subject string
+	Body    string
+}
+
+// Notifier sends notifications.
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// LogNotifier writes notifications to the application log instead of
+// sending them. It is the default until a real transport is configured.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	log.Printf("notify user=%d email=%s subject=%q body=%q", notification.UserID, notification.Email, notification.Subject, notification.Body)
+	return nil
+}
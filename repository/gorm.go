@@ -0,0 +1,417 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/catalog"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/inventory"
+	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/moderation"
+	"gorm.io/gorm"
+)
+
+// NewGorm returns repositories backed by the database.
+func NewGorm(db *gorm.DB) Repositories {
+	return Repositories{
+		Products:     gormProducts{db},
+		Carts:        gormCarts{db},
+		Orders:       gormOrders{db},
+		Users:        gormUsers{db},
+		Addresses:    gormAddresses{db},
+		Attributes:   gormAttributes{db},
+		Prices:       gormPrices{db},
+		Imports:      gormImports{db},
+		ProductFiles: gormFiles{db},
+		Images:       gormImages{db},
+		Inventory:    gormInventory{db},
+		Reviews:      gormReviews{db},
+		Wishlists:    gormWishlists{db},
+		Idempotency:  gormIdempotency{db},
+	}
+}
+
+type gormProducts struct{ db *gorm.DB }
+
+func (r gormProducts) Get(ctx context.Context, productId int64, publishedOnly bool) (*models.Product, error) {
+	return database.GetProduct(ctx, r.db, productId, publishedOnly)
+}
+
+func (r gormProducts) List(ctx context.Context) ([]models.Product, error) {
+	return database.GetProducts(ctx, r.db)
+}
+
+func (r gormProducts) Search(ctx context.Context, query database.ProductQuery) ([]models.Product, []database.AttributeFacet, error) {
+	return database.SearchProducts(ctx, r.db, query)
+}
+
+func (r gormProducts) Create(ctx context.Context, product *models.Product, attributes map[string]interface{}, createdBy int64) error {
+	return database.CreateProduct(ctx, r.db, product, attributes, createdBy)
+}
+
+func (r gormProducts) Update(ctx context.Context, productId int64, changes database.ProductChanges, changedBy int64) (*models.Product, error) {
+	return database.UpdateProduct(ctx, r.db, productId, changes, changedBy)
+}
+
+func (r gormProducts) Delete(ctx context.Context, productId int64) error {
+	return database.DeleteProduct(ctx, r.db, productId)
+}
+
+type gormCarts struct{ db *gorm.DB }
+
+func (r gormCarts) Add(ctx context.Context, userId int64, productId int64, quantity int) error {
+	return database.AddProductToCart(ctx, r.db, productId, userId, quantity)
+}
+
+func (r gormCarts) Remove(ctx context.Context, userId int64, productId int64) error {
+	return database.RemoveProductFromCart(ctx, r.db, productId, userId)
+}
+
+func (r gormCarts) SetQuantity(ctx context.Context, userId int64, productId int64, quantity int) error {
+	return database.UpdateProductQuantity(ctx, r.db, userId, productId, quantity)
+}
+
+func (r gormCarts) View(ctx context.Context, userId int64) (*database.CartView, error) {
+	return database.GetCartView(ctx, r.db, userId)
+}
+
+func (r gormCarts) Checkout(ctx context.Context, userId int64, shippingId int64, billingId int64) (*models.Order, error) {
+	return database.CheckoutCart(ctx, r.db, userId, shippingId, billingId)
+}
+
+func (r gormCarts) InstantBuy(ctx context.Context, userId int64, productId int64, shippingId int64, billingId int64) (*models.Order, error) {
+	return database.GetInstantBuyProduct(ctx, r.db, productId, userId, shippingId, billingId)
+}
+
+func (r gormCarts) AddGuest(ctx context.Context, cartId string, productId int64, quantity int) error {
+	return database.AddProductToGuestCart(ctx, r.db, cartId, productId, quantity)
+}
+
+func (r gormCarts) RemoveGuest(ctx context.Context, cartId string, productId int64) error {
+	return database.RemoveProductFromGuestCart(ctx, r.db, cartId, productId)
+}
+
+func (r gormCarts) SetGuestQuantity(ctx context.Context, cartId string, productId int64, quantity int) error {
+	return database.UpdateGuestCartQuantity(ctx, r.db, cartId, productId, quantity)
+}
+
+func (r gormCarts) ViewGuest(ctx context.Context, cartId string) (*database.CartView, error) {
+	return database.GetGuestCartView(ctx, r.db, cartId)
+}
+
+func (r gormCarts) MergeGuest(ctx context.Context, cartId string, userId int64) error {
+	return database.MergeGuestCart(ctx, r.db, cartId, userId)
+}
+
+type gormOrders struct{ db *gorm.DB }
+
+func (r gormOrders) List(ctx context.Context, userId int64) ([]models.Order, error) {
+	return database.GetOrders(ctx, r.db, userId)
+}
+
+func (r gormOrders) Get(ctx context.Context, userId int64, orderId int64) (*models.Order, error) {
+	return database.GetOrder(ctx, r.db, userId, orderId)
+}
+
+func (r gormOrders) UpdateStatus(ctx context.Context, orderId int64, status string) (*models.Order, error) {
+	return database.UpdateOrderStatus(ctx, r.db, orderId, status)
+}
+
+func (r gormOrders) CapturePayment(ctx context.Context, strategy inventory.Strategy, preOrderWindow time.Duration, userId int64, orderId int64) (*models.Order, error) {
+	return database.CapturePayment(ctx, r.db, strategy, preOrderWindow, userId, orderId)
+}
+
+type gormUsers struct{ db *gorm.DB }
+
+func (r gormUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return database.GetUserByEmail(ctx, r.db, email)
+}
+
+func (r gormUsers) Exists(ctx context.Context, column string, value string) (bool, error) {
+	return database.UserExists(ctx, r.db, column, value)
+}
+
+func (r gormUsers) Create(ctx context.Context, user *models.User) error {
+	return database.CreateUser(ctx, r.db, user)
+}
+
+func (r gormUsers) UpdateTokens(ctx context.Context, userId int64, token string, refreshToken string) error {
+	return database.UpdateUserTokens(ctx, r.db, userId, token, refreshToken)
+}
+
+type gormAddresses struct{ db *gorm.DB }
+
+func (r gormAddresses) Add(ctx context.Context, userId int64, address *models.Address) error {
+	return database.AddAddress(ctx, r.db, userId, address)
+}
+
+func (r gormAddresses) List(ctx context.Context, userId int64) ([]models.Address, error) {
+	return database.GetAddresses(ctx, r.db, userId)
+}
+
+func (r gormAddresses) Get(ctx context.Context, userId int64, addressId int64) (*models.Address, error) {
+	return database.GetAddress(ctx, r.db, userId, addressId)
+}
+
+func (r gormAddresses) Update(ctx context.Context, userId int64, addressId int64, data models.Address) (*models.Address, error) {
+	return database.UpdateAddress(ctx, r.db, userId, addressId, data)
+}
+
+func (r gormAddresses) SetDefault(ctx context.Context, userId int64, addressId int64, kind string) (*models.Address, error) {
+	return database.SetDefaultAddress(ctx, r.db, userId, addressId, kind)
+}
+
+func (r gormAddresses) Delete(ctx context.Context, userId int64, addressId int64) error {
+	return database.DeleteAddress(ctx, r.db, userId, addressId)
+}
+
+func (r gormProducts) Deleted(ctx context.Context) ([]models.Product, error) {
+	return database.GetDeletedProducts(ctx, r.db)
+}
+
+func (r gormProducts) Restore(ctx context.Context, productId int64) (*models.Product, error) {
+	return database.RestoreProduct(ctx, r.db, productId)
+}
+
+func (r gormProducts) SetStatus(ctx context.Context, productId int64, status string, publishAt *time.Time, unpublishAt *time.Time) (*models.Product, error) {
+	return database.SetProductStatus(ctx, r.db, productId, status, publishAt, unpublishAt)
+}
+
+func (r gormProducts) SetBundle(ctx context.Context, bundleId int64, pricing string, discount float64, components []models.BundleComponent) (*models.Product, error) {
+	return database.SetBundleComponents(ctx, r.db, bundleId, pricing, discount, components)
+}
+
+type gormAttributes struct{ db *gorm.DB }
+
+func (r gormAttributes) List(ctx context.Context, categoryId int64) ([]models.AttributeDefinition, error) {
+	return database.GetAttributeDefinitions(ctx, r.db, categoryId)
+}
+
+func (r gormAttributes) Create(ctx context.Context, definition *models.AttributeDefinition) error {
+	return database.CreateAttributeDefinition(ctx, r.db, definition)
+}
+
+func (r gormAttributes) Update(ctx context.Context, definitionId int64, changes models.AttributeDefinition) (*models.AttributeDefinition, error) {
+	return database.UpdateAttributeDefinition(ctx, r.db, definitionId, changes)
+}
+
+func (r gormAttributes) Delete(ctx context.Context, definitionId int64) error {
+	return database.DeleteAttributeDefinition(ctx, r.db, definitionId)
+}
+
+type gormPrices struct{ db *gorm.DB }
+
+func (r gormPrices) Timeline(ctx context.Context, productId int64) (*database.PriceTimeline, error) {
+	return database.GetPriceTimeline(ctx, r.db, productId)
+}
+
+func (r gormPrices) CreateSchedule(ctx context.Context, schedule *models.PriceSchedule) error {
+	return database.CreatePriceSchedule(ctx, r.db, schedule)
+}
+
+func (r gormPrices) CancelSchedule(ctx context.Context, scheduleId int64, cancelledBy int64) (*models.PriceSchedule, error) {
+	return database.CancelPriceSchedule(ctx, r.db, scheduleId, cancelledBy)
+}
+
+type gormImports struct{ db *gorm.DB }
+
+func (r gormImports) Create(ctx context.Context, job *models.ImportJob, rows []catalog.Row, rowErrors []catalog.RowError) error {
+	return database.CreateImportJob(ctx, r.db, job, rows, rowErrors)
+}
+
+func (r gormImports) Run(ctx context.Context, job *models.ImportJob, rows []catalog.Row) error {
+	return database.RunImport(ctx, r.db, job, rows)
+}
+
+func (r gormImports) Get(ctx context.Context, jobId int64) (*models.ImportJob, error) {
+	return database.GetImportJob(ctx, r.db, jobId)
+}
+
+func (r gormImports) Export(ctx context.Context, fn func(product *models.Product) error) error {
+	return database.ExportProducts(ctx, r.db, fn)
+}
+
+type gormFiles struct{ db *gorm.DB }
+
+func (r gormFiles) List(ctx context.Context, productId int64) ([]models.ProductFile, error) {
+	return database.GetProductFiles(ctx, r.db, productId)
+}
+
+func (r gormFiles) Add(ctx context.Context, file *models.ProductFile) error {
+	return database.AddProductFile(ctx, r.db, file)
+}
+
+func (r gormFiles) Delete(ctx context.Context, productId int64, fileId int64) (*models.ProductFile, error) {
+	return database.DeleteProductFile(ctx, r.db, productId, fileId)
+}
+
+func (r gormFiles) Downloads(ctx context.Context, userId int64, orderId int64) ([]database.Download, error) {
+	return database.GetDownloads(ctx, r.db, userId, orderId)
+}
+
+func (r gormFiles) ClaimDownload(ctx context.Context, orderItemId int64, fileId int64, open func(file *models.ProductFile) error) (*models.ProductFile, error) {
+	return database.ClaimDownload(ctx, r.db, orderItemId, fileId, open)
+}
+
+type gormImages struct{ db *gorm.DB }
+
+func (r gormImages) Add(ctx context.Context, productId int64, images []models.ProductImage) error {
+	return database.AddProductImages(ctx, r.db, productId, images)
+}
+
+func (r gormImages) List(ctx context.Context, productId int64) ([]models.ProductImage, error) {
+	return database.GetProductImages(ctx, r.db, productId)
+}
+
+func (r gormImages) Get(ctx context.Context, productId int64, imageId int64) (*models.ProductImage, error) {
+	return database.GetProductImage(ctx, r.db, productId, imageId)
+}
+
+func (r gormImages) Reorder(ctx context.Context, productId int64, imageIds []int64) ([]models.ProductImage, error) {
+	return database.ReorderProductImages(ctx, r.db, productId, imageIds)
+}
+
+func (r gormImages) Delete(ctx context.Context, productId int64, imageId int64) (*models.ProductImage, error) {
+	return database.DeleteProductImage(ctx, r.db, productId, imageId)
+}
+
+type gormInventory struct{ db *gorm.DB }
+
+func (r gormInventory) Warehouses(ctx context.Context) ([]models.Warehouse, error) {
+	return database.GetWarehouses(ctx, r.db)
+}
+
+func (r gormInventory) CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	return database.CreateWarehouse(ctx, r.db, warehouse)
+}
+
+func (r gormInventory) UpdateWarehouse(ctx context.Context, warehouseId int64, changes models.Warehouse) (*models.Warehouse, error) {
+	return database.UpdateWarehouse(ctx, r.db, warehouseId, changes)
+}
+
+func (r gormInventory) StockLevels(ctx context.Context, productId int64) ([]models.StockLevel, error) {
+	return database.GetStockLevels(ctx, r.db, productId)
+}
+
+func (r gormInventory) Movements(ctx context.Context, productId int64, warehouseId int64, limit int) ([]models.StockMovement, error) {
+	return database.GetStockMovements(ctx, r.db, productId, warehouseId, limit)
+}
+
+func (r gormInventory) Adjust(ctx context.Context, adminId int64, warehouseId int64, productId int64, quantity int, movementType string, reason string) (*models.StockMovement, error) {
+	return database.AdjustStock(ctx, r.db, adminId, warehouseId, productId, quantity, movementType, reason)
+}
+
+func (r gormInventory) Transfer(ctx context.Context, adminId int64, fromId int64, toId int64, productId int64, quantity int, reason string) ([]models.StockMovement, error) {
+	return database.TransferStock(ctx, r.db, adminId, fromId, toId, productId, quantity, reason)
+}
+
+func (r gormInventory) Rebuild(ctx context.Context) error {
+	return database.RebuildStockLevels(ctx, r.db)
+}
+
+func (r gormInventory) ReorderReport(ctx context.Context, options database.ReorderOptions) ([]database.ReorderSuggestion, error) {
+	return database.GetReorderReport(ctx, r.db, options)
+}
+
+type gormReviews struct{ db *gorm.DB }
+
+func (r gormReviews) List(ctx context.Context, productId int64, limit int, offset int) ([]models.Review, error) {
+	return database.GetProductReviews(ctx, r.db, productId, limit, offset)
+}
+
+func (r gormReviews) Create(ctx context.Context, userId int64, productId int64, review *models.Review) error {
+	return database.CreateReview(ctx, r.db, userId, productId, review)
+}
+
+func (r gormReviews) Update(ctx context.Context, userId int64, reviewId int64, data models.Review) (*models.Review, error) {
+	return database.UpdateReview(ctx, r.db, userId, reviewId, data)
+}
+
+func (r gormReviews) Delete(ctx context.Context, userId int64, reviewId int64) error {
+	return database.DeleteReview(ctx, r.db, userId, reviewId)
+}
+
+func (r gormReviews) VoteHelpful(ctx context.Context, userId int64, reviewId int64) (*models.Review, error) {
+	return database.VoteReviewHelpful(ctx, r.db, userId, reviewId)
+}
+
+func (r gormReviews) Report(ctx context.Context, userId int64, reviewId int64, reason string, rules moderation.Rules) (*models.Review, error) {
+	return database.ReportReview(ctx, r.db, userId, reviewId, reason, rules)
+}
+
+func (r gormReviews) ModerationQueue(ctx context.Context, status string, limit int, offset int) ([]models.Review, error) {
+	return database.GetModerationQueue(ctx, r.db, status, limit, offset)
+}
+
+func (r gormReviews) Moderate(ctx context.Context, adminId int64, reviewId int64, action string, reason string) (*models.Review, error) {
+	return database.ModerateReview(ctx, r.db, adminId, reviewId, action, reason)
+}
+
+type gormWishlists struct{ db *gorm.DB }
+
+func (r gormWishlists) List(ctx context.Context, userId int64) ([]models.Wishlist, error) {
+	return database.GetWishlists(ctx, r.db, userId)
+}
+
+func (r gormWishlists) Get(ctx context.Context, userId int64, wishlistId int64) (*models.Wishlist, error) {
+	return database.GetWishlist(ctx, r.db, userId, wishlistId)
+}
+
+func (r gormWishlists) Create(ctx context.Context, userId int64, name string) (*models.Wishlist, error) {
+	return database.CreateWishlist(ctx, r.db, userId, name)
+}
+
+func (r gormWishlists) Rename(ctx context.Context, userId int64, wishlistId int64, name string) (*models.Wishlist, error) {
+	return database.RenameWishlist(ctx, r.db, userId, wishlistId, name)
+}
+
+func (r gormWishlists) Delete(ctx context.Context, userId int64, wishlistId int64) error {
+	return database.DeleteWishlist(ctx, r.db, userId, wishlistId)
+}
+
+func (r gormWishlists) AddItem(ctx context.Context, userId int64, wishlistId int64, item models.WishlistItem) (*models.WishlistItem, error) {
+	return database.AddWishlistItem(ctx, r.db, userId, wishlistId, item)
+}
+
+func (r gormWishlists) SetItemAlerts(ctx context.Context, userId int64, wishlistId int64, productId int64, backInStock bool, priceDrop bool) (*models.WishlistItem, error) {
+	return database.UpdateWishlistItemAlerts(ctx, r.db, userId, wishlistId, productId, backInStock, priceDrop)
+}
+
+func (r gormWishlists) RemoveItem(ctx context.Context, userId int64, wishlistId int64, productId int64) error {
+	return database.RemoveWishlistItem(ctx, r.db, userId, wishlistId, productId)
+}
+
+func (r gormWishlists) MoveItemToCart(ctx context.Context, userId int64, wishlistId int64, productId int64) error {
+	return database.MoveWishlistItemToCart(ctx, r.db, userId, wishlistId, productId)
+}
+
+func (r gormWishlists) Share(ctx context.Context, userId int64, wishlistId int64) (*models.Wishlist, error) {
+	return database.ShareWishlist(ctx, r.db, userId, wishlistId)
+}
+
+func (r gormWishlists) Unshare(ctx context.Context, userId int64, wishlistId int64) error {
+	return database.UnshareWishlist(ctx, r.db, userId, wishlistId)
+}
+
+func (r gormWishlists) GetShared(ctx context.Context, token string) (*models.Wishlist, error) {
+	return database.GetSharedWishlist(ctx, r.db, token)
+}
+
+type gormIdempotency struct{ db *gorm.DB }
+
+func (r gormIdempotency) Claim(ctx context.Context, userId int64, key string, hash string) (*models.IdempotencyKey, bool, error) {
+	return database.ClaimIdempotencyKey(ctx, r.db, userId, key, hash)
+}
+
+func (r gormIdempotency) Extend(ctx context.Context, record *models.IdempotencyKey) error {
+	return database.ExtendIdempotencyKey(ctx, r.db, record)
+}
+
+func (r gormIdempotency) Release(ctx context.Context, record *models.IdempotencyKey) error {
+	return database.ReleaseIdempotencyKey(ctx, r.db, record)
+}
+
+func (r gormIdempotency) Complete(ctx context.Context, record *models.IdempotencyKey, statusCode int, contentType string, response []byte) error {
+	return database.CompleteIdempotencyKey(ctx, r.db, record, statusCode, contentType, response)
+}
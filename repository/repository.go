@@ -0,0 +1,204 @@
+// Package repository defines the storage the HTTP handlers depend on, so
+// they are wired to the database in one place. The GORM implementations
+// delegate to the database package; tests run them over SQLite.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/catalog"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/inventory"
+	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/moderation"
+)
+
+// ProductRepository reads and edits the catalog. Lookups of missing
+// products fail with database.ErrCanNotFindProduct.
+type ProductRepository interface {
+	Get(ctx context.Context, productId int64, publishedOnly bool) (*models.Product, error)
+	List(ctx context.Context) ([]models.Product, error)
+	Search(ctx context.Context, query database.ProductQuery) ([]models.Product, []database.AttributeFacet, error)
+	Create(ctx context.Context, product *models.Product, attributes map[string]interface{}, createdBy int64) error
+	Update(ctx context.Context, productId int64, changes database.ProductChanges, changedBy int64) (*models.Product, error)
+	Delete(ctx context.Context, productId int64) error
+	// Deleted lists the products in the trash; Restore takes one out.
+	Deleted(ctx context.Context) ([]models.Product, error)
+	Restore(ctx context.Context, productId int64) (*models.Product, error)
+	SetStatus(ctx context.Context, productId int64, status string, publishAt *time.Time, unpublishAt *time.Time) (*models.Product, error)
+	// SetBundle makes the product a bundle of the components.
+	SetBundle(ctx context.Context, bundleId int64, pricing string, discount float64, components []models.BundleComponent) (*models.Product, error)
+}
+
+// CartRepository keeps the carts of users and of guests, identified by the
+// id in their cart token, and checks carts out into orders.
+type CartRepository interface {
+	Add(ctx context.Context, userId int64, productId int64, quantity int) error
+	Remove(ctx context.Context, userId int64, productId int64) error
+	SetQuantity(ctx context.Context, userId int64, productId int64, quantity int) error
+	View(ctx context.Context, userId int64) (*database.CartView, error)
+	Checkout(ctx context.Context, userId int64, shippingId int64, billingId int64) (*models.Order, error)
+	InstantBuy(ctx context.Context, userId int64, productId int64, shippingId int64, billingId int64) (*models.Order, error)
+
+	AddGuest(ctx context.Context, cartId string, productId int64, quantity int) error
+	RemoveGuest(ctx context.Context, cartId string, productId int64) error
+	SetGuestQuantity(ctx context.Context, cartId string, productId int64, quantity int) error
+	ViewGuest(ctx context.Context, cartId string) (*database.CartView, error)
+	// MergeGuest moves a guest cart into the user's cart after login.
+	MergeGuest(ctx context.Context, cartId string, userId int64) error
+}
+
+// OrderRepository reads orders and moves them through payment and
+// fulfilment.
+type OrderRepository interface {
+	List(ctx context.Context, userId int64) ([]models.Order, error)
+	Get(ctx context.Context, userId int64, orderId int64) (*models.Order, error)
+	UpdateStatus(ctx context.Context, orderId int64, status string) (*models.Order, error)
+	CapturePayment(ctx context.Context, strategy inventory.Strategy, preOrderWindow time.Duration, userId int64, orderId int64) (*models.Order, error)
+}
+
+// UserRepository stores accounts. Lookups of missing users fail with
+// database.ErrCantFindUser.
+type UserRepository interface {
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	// Exists reports whether a user has the value in the email or phone
+	// column.
+	Exists(ctx context.Context, column string, value string) (bool, error)
+	Create(ctx context.Context, user *models.User) error
+	UpdateTokens(ctx context.Context, userId int64, token string, refreshToken string) error
+}
+
+// AddressRepository is a user's address book; every method is scoped to
+// the user.
+type AddressRepository interface {
+	Add(ctx context.Context, userId int64, address *models.Address) error
+	List(ctx context.Context, userId int64) ([]models.Address, error)
+	Get(ctx context.Context, userId int64, addressId int64) (*models.Address, error)
+	Update(ctx context.Context, userId int64, addressId int64, data models.Address) (*models.Address, error)
+	SetDefault(ctx context.Context, userId int64, addressId int64, kind string) (*models.Address, error)
+	Delete(ctx context.Context, userId int64, addressId int64) error
+}
+
+// AttributeRepository keeps the attribute definitions of categories.
+type AttributeRepository interface {
+	List(ctx context.Context, categoryId int64) ([]models.AttributeDefinition, error)
+	Create(ctx context.Context, definition *models.AttributeDefinition) error
+	Update(ctx context.Context, definitionId int64, changes models.AttributeDefinition) (*models.AttributeDefinition, error)
+	Delete(ctx context.Context, definitionId int64) error
+}
+
+// PriceRepository reads price history and schedules sale prices.
+type PriceRepository interface {
+	Timeline(ctx context.Context, productId int64) (*database.PriceTimeline, error)
+	CreateSchedule(ctx context.Context, schedule *models.PriceSchedule) error
+	CancelSchedule(ctx context.Context, scheduleId int64, cancelledBy int64) (*models.PriceSchedule, error)
+}
+
+// ImportRepository runs bulk product imports and exports.
+type ImportRepository interface {
+	// Create stores the job and the rows that failed to parse; Run applies
+	// the remaining rows and records the outcome on the job.
+	Create(ctx context.Context, job *models.ImportJob, rows []catalog.Row, rowErrors []catalog.RowError) error
+	Run(ctx context.Context, job *models.ImportJob, rows []catalog.Row) error
+	Get(ctx context.Context, jobId int64) (*models.ImportJob, error)
+	Export(ctx context.Context, fn func(product *models.Product) error) error
+}
+
+// FileRepository keeps the files of digital products and counts their
+// downloads. The content lives in the blob store.
+type FileRepository interface {
+	List(ctx context.Context, productId int64) ([]models.ProductFile, error)
+	Add(ctx context.Context, file *models.ProductFile) error
+	Delete(ctx context.Context, productId int64, fileId int64) (*models.ProductFile, error)
+	Downloads(ctx context.Context, userId int64, orderId int64) ([]database.Download, error)
+	ClaimDownload(ctx context.Context, orderItemId int64, fileId int64, open func(file *models.ProductFile) error) (*models.ProductFile, error)
+}
+
+// ImageRepository keeps the records of product images; the variants live
+// in the blob store.
+type ImageRepository interface {
+	Add(ctx context.Context, productId int64, images []models.ProductImage) error
+	List(ctx context.Context, productId int64) ([]models.ProductImage, error)
+	Get(ctx context.Context, productId int64, imageId int64) (*models.ProductImage, error)
+	Reorder(ctx context.Context, productId int64, imageIds []int64) ([]models.ProductImage, error)
+	Delete(ctx context.Context, productId int64, imageId int64) (*models.ProductImage, error)
+}
+
+// InventoryRepository manages warehouses and the stock movement ledger.
+type InventoryRepository interface {
+	Warehouses(ctx context.Context) ([]models.Warehouse, error)
+	CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error
+	UpdateWarehouse(ctx context.Context, warehouseId int64, changes models.Warehouse) (*models.Warehouse, error)
+	StockLevels(ctx context.Context, productId int64) ([]models.StockLevel, error)
+	Movements(ctx context.Context, productId int64, warehouseId int64, limit int) ([]models.StockMovement, error)
+	Adjust(ctx context.Context, adminId int64, warehouseId int64, productId int64, quantity int, movementType string, reason string) (*models.StockMovement, error)
+	Transfer(ctx context.Context, adminId int64, fromId int64, toId int64, productId int64, quantity int, reason string) ([]models.StockMovement, error)
+	// Rebuild recomputes the stock levels and product totals from the
+	// ledger.
+	Rebuild(ctx context.Context) error
+	ReorderReport(ctx context.Context, options database.ReorderOptions) ([]database.ReorderSuggestion, error)
+}
+
+// ReviewRepository keeps product reviews and their moderation.
+type ReviewRepository interface {
+	List(ctx context.Context, productId int64, limit int, offset int) ([]models.Review, error)
+	Create(ctx context.Context, userId int64, productId int64, review *models.Review) error
+	Update(ctx context.Context, userId int64, reviewId int64, data models.Review) (*models.Review, error)
+	Delete(ctx context.Context, userId int64, reviewId int64) error
+	VoteHelpful(ctx context.Context, userId int64, reviewId int64) (*models.Review, error)
+	// Report sends the review back to moderation once the rules say it
+	// was reported too often.
+	Report(ctx context.Context, userId int64, reviewId int64, reason string, rules moderation.Rules) (*models.Review, error)
+	ModerationQueue(ctx context.Context, status string, limit int, offset int) ([]models.Review, error)
+	Moderate(ctx context.Context, adminId int64, reviewId int64, action string, reason string) (*models.Review, error)
+}
+
+// WishlistRepository keeps a user's wishlists; every method but GetShared
+// is scoped to the user.
+type WishlistRepository interface {
+	List(ctx context.Context, userId int64) ([]models.Wishlist, error)
+	Get(ctx context.Context, userId int64, wishlistId int64) (*models.Wishlist, error)
+	Create(ctx context.Context, userId int64, name string) (*models.Wishlist, error)
+	Rename(ctx context.Context, userId int64, wishlistId int64, name string) (*models.Wishlist, error)
+	Delete(ctx context.Context, userId int64, wishlistId int64) error
+	AddItem(ctx context.Context, userId int64, wishlistId int64, item models.WishlistItem) (*models.WishlistItem, error)
+	SetItemAlerts(ctx context.Context, userId int64, wishlistId int64, productId int64, backInStock bool, priceDrop bool) (*models.WishlistItem, error)
+	RemoveItem(ctx context.Context, userId int64, wishlistId int64, productId int64) error
+	MoveItemToCart(ctx context.Context, userId int64, wishlistId int64, productId int64) error
+	Share(ctx context.Context, userId int64, wishlistId int64) (*models.Wishlist, error)
+	Unshare(ctx context.Context, userId int64, wishlistId int64) error
+	// GetShared reads a wishlist by its share token, for anyone.
+	GetShared(ctx context.Context, token string) (*models.Wishlist, error)
+}
+
+// IdempotencyRepository remembers the responses to requests sent with an
+// Idempotency-Key header.
+type IdempotencyRepository interface {
+	// Claim reserves the user's key for a request. A claimed key must be
+	// completed or released; otherwise the record holds the response of an
+	// earlier request to replay.
+	Claim(ctx context.Context, userId int64, key string, hash string) (*models.IdempotencyKey, bool, error)
+	// Extend keeps a claimed key locked while its request is still running.
+	Extend(ctx context.Context, record *models.IdempotencyKey) error
+	Release(ctx context.Context, record *models.IdempotencyKey) error
+	Complete(ctx context.Context, record *models.IdempotencyKey, statusCode int, contentType string, response []byte) error
+}
+
+// Repositories bundles the repositories the application is built from.
+type Repositories struct {
+	Products     ProductRepository
+	Carts        CartRepository
+	Orders       OrderRepository
+	Users        UserRepository
+	Addresses    AddressRepository
+	Attributes   AttributeRepository
+	Prices       PriceRepository
+	Imports      ImportRepository
+	ProductFiles FileRepository
+	Images       ImageRepository
+	Inventory    InventoryRepository
+	Reviews      ReviewRepository
+	Wishlists    WishlistRepository
+	Idempotency  IdempotencyRepository
+}
@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"githum.com/muhammadAslam/ecommerce/controllers"
+	"githum.com/muhammadAslam/ecommerce/middleware"
+)
+
+// NewRouter builds the engine serving the whole API for the application.
+// Health probes, catalog, guest cart and account routes are public;
+// everything registered after them needs an access token.
+func NewRouter(app *controllers.Application, name string) *gin.Engine {
+	router := gin.New()
+
+	// Probes are registered before the logger so they don't flood the log.
+	router.GET("/healthz", app.Healthz())
+	router.GET("/readyz", app.Readyz())
+
+	// Use built-in Gin logger middleware
+	router.Use(gin.Logger())
+	router.GET("/get-products", app.GetProds())
+	router.GET("/get-product/:id", app.GetProductByID())
+	router.GET("/search-products", app.SearchProduct())
+	router.GET("/products/:id/reviews", app.GetProductReviews())
+	router.GET("/products/:id/images", app.GetProductImages())
+	router.GET("/products/:id/images/:imageId/:variant", app.ServeProductImage())
+	router.GET("/shared/wishlists/:token", app.GetSharedWishlist())
+	router.GET("/downloads/:item/:file", app.Download())
+	GuestCartRoutes(router, app)
+	router.POST("/signup", app.Signup())
+	router.POST("/login", app.Login())
+	router.GET("/", func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"message": name,
+		})
+	})
+
+	// Apply authentication middleware globally for all routes after this point
+	router.Use(middleware.Authentication(app.Tokens))
+	UserRoutes(router)
+	AdminRoutes(router, app)
+	AddressRoutes(router, app)
+	OrderRoutes(router, app)
+	ReviewRoutes(router, app)
+	WishlistRoutes(router, app)
+	CartRoutes(router, app)
+	return router
+}
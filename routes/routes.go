@@ -3,14 +3,49 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"githum.com/muhammadAslam/ecommerce/controllers"
+	"githum.com/muhammadAslam/ecommerce/middleware"
 )
 
-func AdminRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.POST("/admin/add-products", controllers.AddProduct())
-	incomingRoutes.GET("/admin/get-products", controllers.GetProducts())
-	incomingRoutes.GET("/admin/get-product/:id", controllers.GetProductByID())
-	incomingRoutes.PUT("/admin/update-product/:id", controllers.UpdateProduct())
-	incomingRoutes.DELETE("/admin/delete-product/:id", controllers.DeleteProduct())
+// AdminRoutes are only reachable with an admin token.
+func AdminRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	admin := incomingRoutes.Group("/admin", middleware.Admin())
+	admin.POST("/add-products", app.AddProduct())
+	admin.GET("/get-products", app.GetProducts())
+	admin.GET("/get-product/:id", app.GetAdminProductByID())
+	admin.PUT("/update-product/:id", app.UpdateProduct())
+	admin.DELETE("/delete-product/:id", app.DeleteProduct())
+	admin.PATCH("/orders/:id/status", middleware.Idempotency(app.Idempotency), app.UpdateOrderStatus())
+	admin.GET("/reviews", app.GetModerationQueue())
+	admin.PATCH("/reviews/:id/moderation", app.ModerateReview())
+	admin.GET("/warehouses", app.GetWarehouses())
+	admin.POST("/warehouses", app.CreateWarehouse())
+	admin.PUT("/warehouses/:id", app.UpdateWarehouse())
+	admin.PATCH("/products/:id/status", app.SetProductStatus())
+	admin.GET("/products/:id/prices", app.GetPriceTimeline())
+	admin.POST("/products/:id/price-schedules", app.CreatePriceSchedule())
+	admin.DELETE("/price-schedules/:id", app.CancelPriceSchedule())
+	admin.GET("/products/trash", app.GetDeletedProducts())
+	admin.POST("/products/:id/restore", app.RestoreProduct())
+	admin.POST("/products/import", app.ImportProducts())
+	admin.GET("/products/export", app.ExportProducts())
+	admin.GET("/imports/:id", app.GetImportJob())
+	admin.GET("/products/:id/stock", app.GetProductStock())
+	admin.GET("/categories/:id/attributes", app.GetCategoryAttributes())
+	admin.POST("/categories/:id/attributes", app.CreateCategoryAttribute())
+	admin.PUT("/attributes/:id", app.UpdateAttribute())
+	admin.DELETE("/attributes/:id", app.DeleteAttribute())
+	admin.PUT("/products/:id/components", app.SetBundleComponents())
+	admin.GET("/products/:id/files", app.GetProductFiles())
+	admin.POST("/products/:id/files", app.UploadProductFile())
+	admin.DELETE("/products/:id/files/:fileId", app.DeleteProductFile())
+	admin.POST("/products/:id/images", app.UploadProductImages())
+	admin.PUT("/products/:id/images/order", app.ReorderProductImages())
+	admin.DELETE("/products/:id/images/:imageId", app.DeleteProductImage())
+	admin.GET("/stock/movements", app.GetStockMovements())
+	admin.POST("/stock/adjustments", middleware.Idempotency(app.Idempotency), app.AdjustStock())
+	admin.POST("/stock/transfers", middleware.Idempotency(app.Idempotency), app.TransferStock())
+	admin.POST("/stock/rebuild", app.RebuildStockLevels())
+	admin.GET("/reports/reorder", app.GetReorderReport())
 }
 func UserRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/user", func(c *gin.Context) {
@@ -25,3 +60,67 @@ func UserRoutes(incomingRoutes *gin.Engine) {
 		})
 	})
 }
+
+// AddressRoutes exposes the logged-in user's address book. Every route is
+// scoped to the user in the auth token.
+func AddressRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	incomingRoutes.POST("/addresses", app.AddAddress())
+	incomingRoutes.GET("/addresses", app.GetAddresses())
+	incomingRoutes.GET("/addresses/:id", app.GetAddress())
+	incomingRoutes.PUT("/addresses/:id", app.UpdateAddress())
+	incomingRoutes.DELETE("/addresses/:id", app.DeleteAddress())
+	incomingRoutes.PUT("/addresses/:id/default/:kind", app.SetDefaultAddress())
+}
+
+// OrderRoutes exposes the logged-in user's order history and payment capture.
+func OrderRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	incomingRoutes.GET("/orders", app.GetOrders())
+	incomingRoutes.GET("/orders/:id", app.GetOrder())
+	incomingRoutes.GET("/orders/:id/downloads", app.GetDownloads())
+	incomingRoutes.POST("/orders/:id/capture", middleware.Idempotency(app.Idempotency), app.CapturePayment())
+}
+
+// ReviewRoutes lets customers review products they have received. Listing
+// reviews is public and registered alongside the other product routes.
+func ReviewRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	incomingRoutes.POST("/products/:id/reviews", app.AddReview())
+	incomingRoutes.PUT("/reviews/:id", app.UpdateReview())
+	incomingRoutes.DELETE("/reviews/:id", app.DeleteReview())
+	incomingRoutes.POST("/reviews/:id/helpful", app.VoteReviewHelpful())
+	incomingRoutes.POST("/reviews/:id/report", app.ReportReview())
+}
+
+// WishlistRoutes manage the logged-in user's wishlists. The public view of a
+// shared wishlist is registered with the other unauthenticated routes.
+func WishlistRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	incomingRoutes.GET("/wishlists", app.GetWishlists())
+	incomingRoutes.POST("/wishlists", app.CreateWishlist())
+	incomingRoutes.GET("/wishlists/:id", app.GetWishlist())
+	incomingRoutes.PUT("/wishlists/:id", app.RenameWishlist())
+	incomingRoutes.DELETE("/wishlists/:id", app.DeleteWishlist())
+	incomingRoutes.POST("/wishlists/:id/items", app.AddWishlistItem())
+	incomingRoutes.PATCH("/wishlists/:id/items/:productId", app.UpdateWishlistItem())
+	incomingRoutes.DELETE("/wishlists/:id/items/:productId", app.RemoveWishlistItem())
+	incomingRoutes.POST("/wishlists/:id/items/:productId/move-to-cart", app.MoveWishlistItemToCart())
+	incomingRoutes.POST("/wishlists/:id/share", app.ShareWishlist())
+	incomingRoutes.DELETE("/wishlists/:id/share", app.UnshareWishlist())
+}
+
+// GuestCartRoutes let anonymous visitors keep a cart, identified by a signed
+// cart token. They must be registered before the authentication middleware.
+func GuestCartRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	incomingRoutes.GET("/guest/cart", app.GetGuestCart())
+	incomingRoutes.POST("/guest/cart/items", app.AddToGuestCart())
+	incomingRoutes.PATCH("/guest/cart/items/:productId", app.UpdateGuestCartItem())
+	incomingRoutes.DELETE("/guest/cart/items/:productId", app.RemoveFromGuestCart())
+}
+
+// CartRoutes manage the logged-in user's cart and turn it into orders.
+func CartRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	incomingRoutes.GET("/addtocart", app.AddToCart())
+	incomingRoutes.GET("/removefromcart", app.RemoveFromCart())
+	incomingRoutes.GET("/cart", app.GetCart())
+	incomingRoutes.PATCH("/cart/items/:id", app.UpdateCartItem())
+	incomingRoutes.POST("/cartcheckout", middleware.Idempotency(app.Idempotency), app.Checkout())
+	incomingRoutes.POST("/instantbuy", middleware.Idempotency(app.Idempotency), app.GetInstantBuy())
+}
@@ -0,0 +1,1024 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"githum.com/muhammadAslam/ecommerce/controllers"
+	"githum.com/muhammadAslam/ecommerce/database"
+	"githum.com/muhammadAslam/ecommerce/middleware"
+	"githum.com/muhammadAslam/ecommerce/migrations"
+	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/notify"
+	"githum.com/muhammadAslam/ecommerce/repository"
+)
+
+type cartResponse struct {
+	CartItems []database.CartLine    `json:"cartItems"`
+	Warnings  []database.CartWarning `json:"warnings"`
+	Totals    database.CartTotals    `json:"totals"`
+}
+
+func TestSignupAndLogin(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup()
+	if user.ID == 0 || user.Token == "" || user.Roles != "user" {
+		t.Fatalf("signup returned %+v, want an id, a token and the user role", user)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		body   map[string]string
+		status int
+	}{
+		{"signup with a taken email", "/signup", map[string]string{"Name": "Other", "Email": user.Email, "Phone": "+15550000001", "Password": "secret"}, http.StatusConflict},
+		{"signup with a taken phone", "/signup", map[string]string{"Name": "Other", "Email": "other@example.com", "Phone": user.Phone, "Password": "secret"}, http.StatusBadRequest},
+		{"login", "/login", map[string]string{"Email": user.Email, "Password": "secret-password"}, http.StatusOK},
+		{"login with a wrong password", "/login", map[string]string{"Email": user.Email, "Password": "wrong"}, http.StatusUnauthorized},
+		{"login with an unknown email", "/login", map[string]string{"Email": "nobody@example.com", "Password": "secret-password"}, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := s.request("POST", test.path, "", test.body, nil); status != test.status {
+				t.Errorf("got status %d, want %d", status, test.status)
+			}
+		})
+	}
+
+	var login struct{ Data models.User }
+	s.expect(http.StatusOK, "POST", "/login", "", map[string]string{"Email": user.Email, "Password": "secret-password"}, &login)
+	if login.Data.ID != user.ID {
+		t.Fatalf("login returned user %d, want %d", login.Data.ID, user.ID)
+	}
+	s.expect(http.StatusOK, "GET", "/addresses", login.Data.Token, nil, nil)
+}
+
+func TestAuthentication(t *testing.T) {
+	s := newTestServer(t)
+	user := s.signup()
+	adminToken := s.admin()
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"public route without a token", "/get-products", "", http.StatusOK},
+		{"missing token", "/cart", "", http.StatusUnauthorized},
+		{"invalid token", "/cart", "not-a-token", http.StatusUnauthorized},
+		{"customer token", "/cart", user.Token, http.StatusOK},
+		{"customer on an admin route", "/admin/get-products", user.Token, http.StatusForbidden},
+		{"admin on an admin route", "/admin/get-products", adminToken, http.StatusNoContent},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := s.request("GET", test.path, test.token, nil, nil); status != test.status {
+				t.Errorf("got status %d, want %d", status, test.status)
+			}
+		})
+	}
+}
+
+func TestHealth(t *testing.T) {
+	s := newTestServer(t)
+	var ready error
+	s.app.Ready = func(ctx context.Context) error { return ready }
+
+	tests := []struct {
+		name   string
+		setup  func()
+		path   string
+		status int
+	}{
+		{"liveness", func() {}, "/healthz", http.StatusOK},
+		{"ready", func() {}, "/readyz", http.StatusOK},
+		{"schema behind", func() { ready = migrations.ErrSchemaBehind }, "/readyz", http.StatusServiceUnavailable},
+		{"ready again", func() { ready = nil }, "/readyz", http.StatusOK},
+		{"draining", s.app.Drain, "/readyz", http.StatusServiceUnavailable},
+		{"alive while draining", func() {}, "/healthz", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.setup()
+			if status := s.request("GET", test.path, "", nil, nil); status != test.status {
+				t.Errorf("got status %d, want %d", status, test.status)
+			}
+		})
+	}
+}
+
+func TestCart(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	user := s.signup()
+	other := s.signup()
+	phone := s.addProduct(adminToken, map[string]interface{}{"Name": "Phone", "Price": 199.99, "Quantity": 5, "MaxPerOrder": 3, "Status": "published"})
+	draft := s.addProduct(adminToken, map[string]interface{}{"Name": "Unreleased", "Price": 10, "Quantity": 5})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   interface{}
+		status int
+	}{
+		{"add a product", "GET", fmt.Sprintf("/addtocart?id=%d", phone), user.Token, nil, http.StatusOK},
+		{"add without a product id", "GET", "/addtocart", user.Token, nil, http.StatusBadRequest},
+		{"add an invalid product id", "GET", "/addtocart?id=phone", user.Token, nil, http.StatusBadRequest},
+		{"add a missing product", "GET", "/addtocart?id=9999", user.Token, nil, http.StatusNotFound},
+		{"add a draft product", "GET", fmt.Sprintf("/addtocart?id=%d", draft), user.Token, nil, http.StatusNotFound},
+		{"add beyond the per-order limit", "GET", fmt.Sprintf("/addtocart?id=%d&quantity=3", phone), user.Token, nil, http.StatusConflict},
+		{"add a non-positive quantity", "GET", fmt.Sprintf("/addtocart?id=%d&quantity=0", phone), user.Token, nil, http.StatusBadRequest},
+		{"set the quantity", "PATCH", fmt.Sprintf("/cart/items/%d", phone), user.Token, map[string]int{"quantity": 2}, http.StatusOK},
+		{"set the quantity beyond the limit", "PATCH", fmt.Sprintf("/cart/items/%d", phone), user.Token, map[string]int{"quantity": 4}, http.StatusConflict},
+		{"set the quantity of a product not in the cart", "PATCH", fmt.Sprintf("/cart/items/%d", draft), user.Token, map[string]int{"quantity": 1}, http.StatusNotFound},
+		{"remove a product not in the cart", "GET", fmt.Sprintf("/removefromcart?id=%d", phone), other.Token, nil, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := s.request(test.method, test.path, test.token, test.body, nil); status != test.status {
+				t.Errorf("got status %d, want %d", status, test.status)
+			}
+		})
+	}
+
+	// ?id= names the product; the cart always belongs to the token's user.
+	var cart cartResponse
+	s.expect(http.StatusOK, "GET", "/cart", user.Token, nil, &cart)
+	if len(cart.CartItems) != 1 || cart.CartItems[0].ProductID != phone || cart.CartItems[0].Quantity != 2 {
+		t.Fatalf("cart items = %+v, want 2 of product %d", cart.CartItems, phone)
+	}
+	if cart.Totals.Total != 399.98 {
+		t.Errorf("cart total = %v, want 399.98", cart.Totals.Total)
+	}
+	s.expect(http.StatusOK, "GET", "/cart", other.Token, nil, &cart)
+	if len(cart.CartItems) != 0 {
+		t.Errorf("other user's cart = %+v, want it empty", cart.CartItems)
+	}
+
+	// A repriced product is flagged and the cart moves to the new price.
+	s.expect(http.StatusOK, "PUT", fmt.Sprintf("/admin/update-product/%d", phone), adminToken, map[string]float64{"Price": 149.99}, nil)
+	s.expect(http.StatusOK, "GET", "/cart", user.Token, nil, &cart)
+	if len(cart.Warnings) != 1 || cart.Warnings[0].Code != database.CartWarningPriceChanged || cart.Totals.Total != 299.98 {
+		t.Errorf("cart after repricing = %+v, want a price warning and a total of 299.98", cart)
+	}
+
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/removefromcart?id=%d", phone), user.Token, nil, nil)
+	s.expect(http.StatusOK, "GET", "/cart", user.Token, nil, &cart)
+	if len(cart.CartItems) != 0 {
+		t.Errorf("cart after removal = %+v, want it empty", cart.CartItems)
+	}
+}
+
+func TestGuestCartMergesOnLogin(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	user := s.signup()
+	book := s.addProduct(adminToken, map[string]interface{}{"Name": "Book", "Price": 12.5, "Quantity": 10, "Status": "published"})
+
+	cartId, cartToken, err := s.tokens.GenerateCartToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repos.Carts.AddGuest(context.Background(), cartId, book, 2); err != nil {
+		t.Fatal(err)
+	}
+	login := map[string]string{"Email": user.Email, "Password": "secret-password"}
+	if status := s.send("POST", "/login", map[string]string{controllers.CartHeader: cartToken}, login, nil); status != http.StatusOK {
+		t.Fatalf("login got status %d", status)
+	}
+	var cart cartResponse
+	s.expect(http.StatusOK, "GET", "/cart", user.Token, nil, &cart)
+	if len(cart.CartItems) != 1 || cart.CartItems[0].ProductID != book || cart.CartItems[0].Quantity != 2 {
+		t.Errorf("cart after login = %+v, want the guest cart's 2 books", cart.CartItems)
+	}
+
+	// Merged lines are capped like the cart handlers cap them: backorders
+	// count as sellable and the per-order limit applies to the summed line.
+	mug := s.addProduct(adminToken, map[string]interface{}{"Name": "Mug", "Price": 8, "Quantity": 10, "MaxPerOrder": 3, "Status": "published"})
+	kettle := s.addProduct(adminToken, map[string]interface{}{"Name": "Kettle", "Price": 30, "Quantity": 0, "StockPolicy": "backorder", "BackorderLimit": 5, "Status": "published"})
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=2", mug), user.Token, nil, nil)
+	cartId, cartToken, err = s.tokens.GenerateCartToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repos.Carts.AddGuest(context.Background(), cartId, mug, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repos.Carts.AddGuest(context.Background(), cartId, kettle, 2); err != nil {
+		t.Fatal(err)
+	}
+	if status := s.send("POST", "/login", map[string]string{controllers.CartHeader: cartToken}, login, nil); status != http.StatusOK {
+		t.Fatalf("login got status %d", status)
+	}
+	s.expect(http.StatusOK, "GET", "/cart", user.Token, nil, &cart)
+	quantities := map[int64]int{}
+	for _, line := range cart.CartItems {
+		quantities[line.ProductID] = line.Quantity
+	}
+	if quantities[mug] != 3 || quantities[kettle] != 2 {
+		t.Errorf("cart after second login = %+v, want 3 mugs and 2 backordered kettles", cart.CartItems)
+	}
+}
+
+func TestExpiredGuestCartStartsOver(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	book := s.addProduct(adminToken, map[string]interface{}{"Name": "Book", "Price": 12.5, "Quantity": 10, "Status": "published"})
+	pen := s.addProduct(adminToken, map[string]interface{}{"Name": "Pen", "Price": 2, "Quantity": 10, "Status": "published"})
+	cartId, cartToken, err := s.tokens.GenerateCartToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repos.Carts.AddGuest(context.Background(), cartId, book, 1); err != nil {
+		t.Fatal(err)
+	}
+	// The cart expired, but the cleanup job hasn't removed it yet.
+	if err := s.db.Model(&models.GuestCart{}).Where("token = ?", cartId).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := json.Marshal(map[string]int64{"product_id": pen})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/guest/cart/items", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: controllers.CartCookie, Value: cartToken})
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("adding to an expired cart got status %d: %s", rec.Code, rec.Body)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != cartToken || cookies[0].MaxAge != int(database.GuestCartLifetime.Seconds()) {
+		t.Errorf("response cookies %+v, want the cart token for another %s", cookies, database.GuestCartLifetime)
+	}
+
+	var cart cartResponse
+	s.send("GET", "/guest/cart", map[string]string{controllers.CartHeader: cartToken}, nil, &cart)
+	if len(cart.CartItems) != 1 || cart.CartItems[0].ProductID != pen {
+		t.Errorf("revived cart holds %+v, want only the pen", cart.CartItems)
+	}
+}
+
+func TestCheckout(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	user := s.signup()
+	other := s.signup()
+	lamp := s.addProduct(adminToken, map[string]interface{}{"Name": "Lamp", "Price": 25, "Quantity": 3, "Status": "published"})
+
+	// Checking out needs a cart and a shipping address.
+	s.expect(http.StatusBadRequest, "POST", "/cartcheckout", user.Token, nil, nil)
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=2", lamp), user.Token, nil, nil)
+	s.expect(http.StatusBadRequest, "POST", "/cartcheckout", user.Token, nil, nil)
+	s.addAddress(user.Token)
+
+	var checkout struct{ Data models.Order }
+	s.expect(http.StatusOK, "POST", "/cartcheckout", user.Token, nil, &checkout)
+	order := checkout.Data
+	if order.OrderStatus != models.OrderStatusPendingPayment || order.TotalPrice != 50 || order.ShippingAddress.City != "San Francisco" {
+		t.Fatalf("checkout placed %+v, want a 50.00 order awaiting payment shipped to San Francisco", order)
+	}
+	var cart cartResponse
+	s.expect(http.StatusOK, "GET", "/cart", user.Token, nil, &cart)
+	if len(cart.CartItems) != 0 {
+		t.Errorf("cart after checkout = %+v, want it empty", cart.CartItems)
+	}
+
+	// The order holds its stock, so only one lamp is left for others.
+	s.expect(http.StatusConflict, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=2", lamp), other.Token, nil, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   interface{}
+		status int
+	}{
+		{"get the order", "GET", fmt.Sprintf("/orders/%d", order.ID), user.Token, nil, http.StatusOK},
+		{"get another user's order", "GET", fmt.Sprintf("/orders/%d", order.ID), other.Token, nil, http.StatusNotFound},
+		{"ship before payment", "PATCH", fmt.Sprintf("/admin/orders/%d/status", order.ID), adminToken, map[string]string{"status": "shipped"}, http.StatusConflict},
+		{"capture another user's order", "POST", fmt.Sprintf("/orders/%d/capture", order.ID), other.Token, nil, http.StatusNotFound},
+		{"capture the payment", "POST", fmt.Sprintf("/orders/%d/capture", order.ID), user.Token, nil, http.StatusOK},
+		{"capture it twice", "POST", fmt.Sprintf("/orders/%d/capture", order.ID), user.Token, nil, http.StatusConflict},
+		{"ship after payment", "PATCH", fmt.Sprintf("/admin/orders/%d/status", order.ID), adminToken, map[string]string{"status": "shipped"}, http.StatusOK},
+		{"set an unknown status", "PATCH", fmt.Sprintf("/admin/orders/%d/status", order.ID), adminToken, map[string]string{"status": "lost"}, http.StatusBadRequest},
+		{"update a missing order", "PATCH", "/admin/orders/9999/status", adminToken, map[string]string{"status": "shipped"}, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := s.request(test.method, test.path, test.token, test.body, nil); status != test.status {
+				t.Errorf("got status %d, want %d", status, test.status)
+			}
+		})
+	}
+
+	var orders struct{ Orders []models.Order }
+	s.expect(http.StatusOK, "GET", "/orders", user.Token, nil, &orders)
+	if len(orders.Orders) != 1 || orders.Orders[0].OrderStatus != models.OrderStatusShipped {
+		t.Errorf("orders = %+v, want the shipped order", orders.Orders)
+	}
+	var product struct{ Product models.Product }
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/get-product/%d", lamp), "", nil, &product)
+	if product.Product.Quantity != 1 {
+		t.Errorf("lamp stock after capture = %d, want 1", product.Product.Quantity)
+	}
+}
+
+func TestOrderStatusTransitions(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	user := s.signup()
+	other := s.signup()
+	s.addAddress(user.Token)
+	lamp := s.addProduct(adminToken, map[string]interface{}{"Name": "Lamp", "Price": 25, "Quantity": 3, "Status": "published"})
+	kettle := s.addProduct(adminToken, map[string]interface{}{"Name": "Kettle", "Price": 30, "Quantity": 1, "StockPolicy": "backorder", "BackorderLimit": 5, "Status": "published"})
+
+	// The first order takes two lamps and the only kettle, and waits for two more.
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=2", lamp), user.Token, nil, nil)
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=3", kettle), user.Token, nil, nil)
+	var checkout struct{ Data models.Order }
+	s.expect(http.StatusOK, "POST", "/cartcheckout", user.Token, nil, &checkout)
+	cancelled := checkout.Data.ID
+	s.expect(http.StatusOK, "POST", fmt.Sprintf("/orders/%d/capture", cancelled), user.Token, nil, nil)
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=1", lamp), user.Token, nil, nil)
+	s.expect(http.StatusOK, "POST", "/cartcheckout", user.Token, nil, &checkout)
+	delivered := checkout.Data.ID
+	s.expect(http.StatusOK, "POST", fmt.Sprintf("/orders/%d/capture", delivered), user.Token, nil, nil)
+
+	status := func(order int64) string { return fmt.Sprintf("/admin/orders/%d/status", order) }
+	tests := []struct {
+		name   string
+		order  int64
+		status string
+		want   int
+	}{
+		{"ship", delivered, models.OrderStatusShipped, http.StatusOK},
+		{"cancel a shipped order", delivered, models.OrderStatusCancelled, http.StatusConflict},
+		{"move a shipped order back", delivered, models.OrderStatusOrdered, http.StatusConflict},
+		{"deliver", delivered, models.OrderStatusDelivered, http.StatusOK},
+		{"ship a delivered order", delivered, models.OrderStatusShipped, http.StatusConflict},
+		{"cancel a delivered order", delivered, models.OrderStatusCancelled, http.StatusConflict},
+		{"cancel a paid order", cancelled, models.OrderStatusCancelled, http.StatusOK},
+		{"cancel it twice", cancelled, models.OrderStatusCancelled, http.StatusConflict},
+		{"reopen a cancelled order", cancelled, models.OrderStatusOrdered, http.StatusConflict},
+		{"ship a cancelled order", cancelled, models.OrderStatusShipped, http.StatusConflict},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := s.request("PATCH", status(test.order), adminToken, map[string]string{"status": test.status}, nil); got != test.want {
+				t.Errorf("got status %d, want %d", got, test.want)
+			}
+		})
+	}
+
+	// The cancelled order's lamps and kettle are back on the shelf, and its
+	// backordered kettles no longer count against the backorder limit.
+	for _, want := range []struct {
+		id       int64
+		quantity int
+	}{{lamp, 2}, {kettle, 1}} {
+		var product struct{ Product models.Product }
+		s.expect(http.StatusOK, "GET", fmt.Sprintf("/get-product/%d", want.id), "", nil, &product)
+		if product.Product.Quantity != want.quantity {
+			t.Errorf("%s stock after the cancellation = %d, want %d", product.Product.Name, product.Product.Quantity, want.quantity)
+		}
+	}
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=6", kettle), other.Token, nil, nil)
+	var returned int64
+	if err := s.db.Model(&models.StockMovement{}).
+		Where("order_id = ? AND type = ?", cancelled, models.StockMovementReturn).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&returned).Error; err != nil {
+		t.Fatal(err)
+	}
+	if returned != 3 {
+		t.Errorf("cancellation returned %d units to the ledger, want 3", returned)
+	}
+	for order, want := range map[int64]string{cancelled: models.PaymentStatusRefunded, delivered: models.PaymentStatusCaptured} {
+		var payment models.Payment
+		if err := s.db.First(&payment, "order_id = ?", order).Error; err != nil {
+			t.Fatal(err)
+		}
+		if payment.Status != want {
+			t.Errorf("payment of order %d is %q, want %q", order, payment.Status, want)
+		}
+	}
+}
+
+func TestInstantBuy(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	user := s.signup()
+	mug := s.addProduct(adminToken, map[string]interface{}{"Name": "Mug", "Price": 8, "Quantity": 10, "Status": "published"})
+	plate := s.addProduct(adminToken, map[string]interface{}{"Name": "Plate", "Price": 6, "Quantity": 10, "Status": "published"})
+	s.addAddress(user.Token)
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d", mug), user.Token, nil, nil)
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d", plate), user.Token, nil, nil)
+
+	s.expect(http.StatusNotFound, "POST", "/instantbuy?id=9999", user.Token, nil, nil)
+	var response struct{ Data models.Order }
+	s.expect(http.StatusOK, "POST", fmt.Sprintf("/instantbuy?id=%d", mug), user.Token, nil, &response)
+	if len(response.Data.Items) != 1 || response.Data.Items[0].ProductID != mug {
+		t.Fatalf("instant buy ordered %+v, want only the mug", response.Data.Items)
+	}
+	var cart cartResponse
+	s.expect(http.StatusOK, "GET", "/cart", user.Token, nil, &cart)
+	if len(cart.CartItems) != 1 || cart.CartItems[0].ProductID != plate {
+		t.Errorf("cart after instant buy = %+v, want only the plate", cart.CartItems)
+	}
+}
+
+func TestAdminProducts(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	desk := s.addProduct(adminToken, map[string]interface{}{"Name": "Standing Desk", "Price": 300, "Quantity": 4, "Status": "published"})
+	draft := s.addProduct(adminToken, map[string]interface{}{"Name": "Desk Lamp", "Price": 40, "Quantity": 4})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   interface{}
+		status int
+	}{
+		{"create with an unknown status", "POST", "/admin/add-products", adminToken, map[string]interface{}{"Name": "Chair", "CategoryID": s.category, "Status": "sold"}, http.StatusBadRequest},
+		{"create with a negative price", "POST", "/admin/add-products", adminToken, map[string]interface{}{"Name": "Chair", "CategoryID": s.category, "Price": -5, "Quantity": 1}, http.StatusBadRequest},
+		{"create with negative stock", "POST", "/admin/add-products", adminToken, map[string]interface{}{"Name": "Chair", "CategoryID": s.category, "Price": 5, "Quantity": -1}, http.StatusBadRequest},
+		{"create with a bad stock policy", "POST", "/admin/add-products", adminToken, map[string]interface{}{"Name": "Chair", "CategoryID": s.category, "StockPolicy": "maybe"}, http.StatusBadRequest},
+		{"get a published product", "GET", fmt.Sprintf("/get-product/%d", desk), "", nil, http.StatusOK},
+		{"get a draft publicly", "GET", fmt.Sprintf("/get-product/%d", draft), "", nil, http.StatusNotFound},
+		{"get a draft as admin", "GET", fmt.Sprintf("/admin/get-product/%d", draft), adminToken, nil, http.StatusOK},
+		{"get an invalid id", "GET", "/get-product/desk", "", nil, http.StatusBadRequest},
+		{"update a negative price", "PUT", fmt.Sprintf("/admin/update-product/%d", desk), adminToken, map[string]float64{"Price": -1}, http.StatusBadRequest},
+		{"update a missing product", "PUT", "/admin/update-product/9999", adminToken, map[string]string{"Name": "Desk"}, http.StatusNotFound},
+		{"set the stock", "PUT", fmt.Sprintf("/admin/update-product/%d", desk), adminToken, map[string]int{"Quantity": 7}, http.StatusOK},
+		{"delete a missing product", "DELETE", "/admin/delete-product/9999", adminToken, nil, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := s.request(test.method, test.path, test.token, test.body, nil); status != test.status {
+				t.Errorf("got status %d, want %d", status, test.status)
+			}
+		})
+	}
+
+	var listing struct{ Data []models.Product }
+	s.expect(http.StatusOK, "GET", "/search-products?product=Desk", "", nil, &listing)
+	if len(listing.Data) != 1 || listing.Data[0].ID != desk || listing.Data[0].Quantity != 7 {
+		t.Errorf("search found %+v, want only the published desk with 7 in stock", listing.Data)
+	}
+	var all struct{ Products []models.Product }
+	s.expect(http.StatusOK, "GET", "/admin/get-products", adminToken, nil, &all)
+	if len(all.Products) != 2 {
+		t.Errorf("admin listing has %d products, want 2", len(all.Products))
+	}
+
+	s.expect(http.StatusOK, "DELETE", fmt.Sprintf("/admin/delete-product/%d", desk), adminToken, nil, nil)
+	s.expect(http.StatusNotFound, "GET", fmt.Sprintf("/get-product/%d", desk), "", nil, nil)
+}
+
+func TestPurgeKeepsProductHistory(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	lamp := s.addProduct(adminToken, map[string]interface{}{"Name": "Lamp", "Price": 40, "Quantity": 3, "Status": "published"})
+	s.expect(http.StatusOK, "PUT", fmt.Sprintf("/admin/update-product/%d", lamp), adminToken, map[string]interface{}{"Price": 35, "Quantity": 5}, nil)
+	s.expect(http.StatusOK, "DELETE", fmt.Sprintf("/admin/delete-product/%d", lamp), adminToken, nil, nil)
+
+	if err := database.PurgeDeletedProducts(context.Background(), s.db, s.app.Files, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	var products, levels, movements, prices int64
+	s.db.Unscoped().Model(&models.Product{}).Where("id = ?", lamp).Count(&products)
+	s.db.Model(&models.StockLevel{}).Where("product_id = ?", lamp).Count(&levels)
+	s.db.Model(&models.StockMovement{}).Where("product_id = ?", lamp).Count(&movements)
+	s.db.Model(&models.PriceChange{}).Where("product_id = ?", lamp).Count(&prices)
+	if products != 0 || levels != 0 {
+		t.Errorf("after the purge %d products and %d stock levels are left, want none", products, levels)
+	}
+	if movements == 0 || prices == 0 {
+		t.Errorf("after the purge %d stock movements and %d price changes are left, want the history kept", movements, prices)
+	}
+}
+
+func TestIdempotentCheckout(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	user := s.signup()
+	kettle := s.addProduct(adminToken, map[string]interface{}{"Name": "Kettle", "Price": 30, "Quantity": 5, "Status": "published"})
+	s.addAddress(user.Token)
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d", kettle), user.Token, nil, nil)
+
+	headers := map[string]string{"token": user.Token, middleware.IdempotencyHeader: "checkout-1"}
+	var first, retry struct{ Data models.Order }
+	if status := s.send("POST", "/cartcheckout", headers, nil, &first); status != http.StatusOK {
+		t.Fatalf("checkout got status %d", status)
+	}
+	if status := s.send("POST", "/cartcheckout", headers, nil, &retry); status != http.StatusOK {
+		t.Fatalf("retried checkout got status %d", status)
+	}
+	if retry.Data.ID != first.Data.ID {
+		t.Errorf("retry returned order %d, want the replayed order %d", retry.Data.ID, first.Data.ID)
+	}
+	if status := s.send("POST", fmt.Sprintf("/instantbuy?id=%d", kettle), headers, nil, nil); status != http.StatusUnprocessableEntity {
+		t.Errorf("key reused for another request got status %d, want %d", status, http.StatusUnprocessableEntity)
+	}
+	var orders struct{ Orders []models.Order }
+	s.expect(http.StatusOK, "GET", "/orders", user.Token, nil, &orders)
+	if len(orders.Orders) != 1 {
+		t.Errorf("user has %d orders, want 1", len(orders.Orders))
+	}
+}
+
+// slowCheckout holds each checkout until release is closed.
+type slowCheckout struct {
+	repository.CartRepository
+	started  chan struct{}
+	release  chan struct{}
+	checkout atomic.Int32
+}
+
+func (r *slowCheckout) Checkout(ctx context.Context, userId int64, shippingId int64, billingId int64) (*models.Order, error) {
+	if r.checkout.Add(1) == 1 {
+		close(r.started)
+	}
+	<-r.release
+	return r.CartRepository.Checkout(ctx, userId, shippingId, billingId)
+}
+
+func TestIdempotencyKeyStaysLockedWhileRunning(t *testing.T) {
+	lockTimeout := database.IdempotencyLockTimeout
+	database.IdempotencyLockTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { database.IdempotencyLockTimeout = lockTimeout })
+
+	s := newTestServer(t)
+	adminToken := s.admin()
+	user := s.signup()
+	kettle := s.addProduct(adminToken, map[string]interface{}{"Name": "Kettle", "Price": 30, "Quantity": 5, "Status": "published"})
+	s.addAddress(user.Token)
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d", kettle), user.Token, nil, nil)
+	// SQLite fails a transaction that upgrades to writing while another
+	// connection writes, so serialize the connections as Postgres would
+	// serialize the rows.
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	carts := &slowCheckout{CartRepository: s.app.Carts, started: make(chan struct{}), release: make(chan struct{})}
+	s.app.Carts = carts
+
+	headers := map[string]string{"token": user.Token, middleware.IdempotencyHeader: "checkout-1"}
+	var first, retry struct{ Data models.Order }
+	var firstStatus, retryStatus int
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		firstStatus = s.send("POST", "/cartcheckout", headers, nil, &first)
+	}()
+	<-carts.started
+	// Outlive the lock several times over, then retry while the first
+	// checkout is still running.
+	time.Sleep(3 * database.IdempotencyLockTimeout)
+	go func() {
+		defer wg.Done()
+		retryStatus = s.send("POST", "/cartcheckout", headers, nil, &retry)
+	}()
+	time.Sleep(3 * database.IdempotencyLockTimeout)
+	close(carts.release)
+	wg.Wait()
+
+	if firstStatus != http.StatusOK || retryStatus != http.StatusOK {
+		t.Fatalf("checkout got status %d and retry %d, want both %d", firstStatus, retryStatus, http.StatusOK)
+	}
+	if n := carts.checkout.Load(); n != 1 {
+		t.Errorf("checkout ran %d times, want once", n)
+	}
+	if retry.Data.ID != first.Data.ID {
+		t.Errorf("retry returned order %d, want the replayed order %d", retry.Data.ID, first.Data.ID)
+	}
+}
+
+func TestWishlists(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	user := s.signup()
+	other := s.signup()
+	tent := s.addProduct(adminToken, map[string]interface{}{"Name": "Tent", "Price": 120, "Quantity": 2, "Status": "published"})
+	lantern := s.addProduct(adminToken, map[string]interface{}{"Name": "Lantern", "Price": 25, "Quantity": 3, "Status": "published"})
+
+	var created struct{ Data models.Wishlist }
+	s.expect(http.StatusCreated, "POST", "/wishlists", user.Token, map[string]string{"name": "Camping"}, &created)
+	list := created.Data.ID
+	item := map[string]interface{}{"product_id": tent, "notify_price_drop": true}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   interface{}
+		status int
+	}{
+		{"add a product", "POST", fmt.Sprintf("/wishlists/%d/items", list), user.Token, item, http.StatusCreated},
+		{"add it twice", "POST", fmt.Sprintf("/wishlists/%d/items", list), user.Token, item, http.StatusConflict},
+		{"add a missing product", "POST", fmt.Sprintf("/wishlists/%d/items", list), user.Token, map[string]int64{"product_id": 9999}, http.StatusNotFound},
+		{"read another user's wishlist", "GET", fmt.Sprintf("/wishlists/%d", list), other.Token, nil, http.StatusNotFound},
+		{"read an unknown share link", "GET", "/shared/wishlists/nope", "", nil, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := s.request(test.method, test.path, test.token, test.body, nil); status != test.status {
+				t.Errorf("got status %d, want %d", status, test.status)
+			}
+		})
+	}
+
+	var share struct {
+		ShareToken string `json:"share_token"`
+	}
+	s.expect(http.StatusCreated, "POST", fmt.Sprintf("/wishlists/%d/items", list), user.Token, map[string]int64{"product_id": lantern}, nil)
+	s.expect(http.StatusOK, "POST", fmt.Sprintf("/wishlists/%d/share", list), user.Token, nil, &share)
+	s.expect(http.StatusOK, "PATCH", fmt.Sprintf("/admin/products/%d/status", lantern), adminToken, map[string]string{"status": "archived"}, nil)
+	var shared struct{ Products []models.Product }
+	s.expect(http.StatusOK, "GET", "/shared/wishlists/"+share.ShareToken, "", nil, &shared)
+	if len(shared.Products) != 1 || shared.Products[0].ID != tent {
+		t.Errorf("shared wishlist shows %+v, want only the tent", shared.Products)
+	}
+
+	s.expect(http.StatusOK, "POST", fmt.Sprintf("/wishlists/%d/items/%d/move-to-cart", list, tent), user.Token, nil, nil)
+	var cart cartResponse
+	s.expect(http.StatusOK, "GET", "/cart", user.Token, nil, &cart)
+	if len(cart.CartItems) != 1 || cart.CartItems[0].ProductID != tent {
+		t.Errorf("cart after moving the tent = %+v, want the tent", cart.CartItems)
+	}
+}
+
+func TestInactiveWarehouseStock(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	user := s.signup()
+	desk := s.addProduct(adminToken, map[string]interface{}{"Name": "Desk", "Price": 200, "Quantity": 0, "Status": "published"})
+
+	var created struct{ Data models.Warehouse }
+	s.expect(http.StatusCreated, "POST", "/admin/warehouses", adminToken, map[string]interface{}{"code": "EAST", "name": "East", "priority": 1}, &created)
+	east := created.Data.ID
+	receipt := map[string]interface{}{"warehouse_id": east, "product_id": desk, "quantity": 4, "type": models.StockMovementReceipt, "reason": "delivery"}
+	s.expect(http.StatusCreated, "POST", "/admin/stock/adjustments", adminToken, receipt, nil)
+
+	// Stock at an inactive warehouse can't be sold until it is active again.
+	s.expect(http.StatusOK, "PUT", fmt.Sprintf("/admin/warehouses/%d", east), adminToken, map[string]interface{}{"name": "East", "active": false}, nil)
+	s.expect(http.StatusConflict, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=1", desk), user.Token, nil, nil)
+	var stock struct {
+		Total         int `json:"total"`
+		InactiveTotal int `json:"inactive_total"`
+	}
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/admin/products/%d/stock", desk), adminToken, nil, &stock)
+	if stock.Total != 0 || stock.InactiveTotal != 4 {
+		t.Errorf("stock report totals %d active and %d inactive, want 0 and 4", stock.Total, stock.InactiveTotal)
+	}
+	s.expect(http.StatusOK, "PUT", fmt.Sprintf("/admin/warehouses/%d", east), adminToken, map[string]interface{}{"name": "East", "active": true}, nil)
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=4", desk), user.Token, nil, nil)
+}
+
+func TestUpdateProductStock(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	user := s.signup()
+	s.addAddress(user.Token)
+	kettle := s.addProduct(adminToken, map[string]interface{}{"Name": "Kettle", "Price": 30, "Quantity": 0, "StockPolicy": "backorder", "BackorderLimit": 5, "Status": "published"})
+
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=2", kettle), user.Token, nil, nil)
+	var checkout struct{ Data models.Order }
+	s.expect(http.StatusOK, "POST", "/cartcheckout", user.Token, nil, &checkout)
+	s.expect(http.StatusOK, "POST", fmt.Sprintf("/orders/%d/capture", checkout.Data.ID), user.Token, nil, nil)
+
+	// Incoming stock ships the backorder first; the response shows what is left.
+	var updated struct{ Data models.Product }
+	s.expect(http.StatusOK, "PUT", fmt.Sprintf("/admin/update-product/%d", kettle), adminToken, map[string]interface{}{"Price": 35, "Quantity": 5}, &updated)
+	if updated.Data.Quantity != 3 || updated.Data.Price != 35 {
+		t.Errorf("updated kettle has %d in stock at %.2f, want 3 at 35.00", updated.Data.Quantity, updated.Data.Price)
+	}
+
+	// A stock change that fails leaves the rest of the update undone.
+	var created struct{ Data models.Warehouse }
+	s.expect(http.StatusCreated, "POST", "/admin/warehouses", adminToken, map[string]interface{}{"code": "EAST", "name": "East", "priority": 1}, &created)
+	receipt := map[string]interface{}{"warehouse_id": created.Data.ID, "product_id": kettle, "quantity": 4, "type": models.StockMovementReceipt, "reason": "delivery"}
+	s.expect(http.StatusCreated, "POST", "/admin/stock/adjustments", adminToken, receipt, nil)
+	s.expect(http.StatusConflict, "PUT", fmt.Sprintf("/admin/update-product/%d", kettle), adminToken, map[string]interface{}{"Price": 99, "Quantity": 1}, nil)
+	var product struct{ Product models.Product }
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/get-product/%d", kettle), "", nil, &product)
+	if product.Product.Quantity != 7 || product.Product.Price != 35 {
+		t.Errorf("kettle after the failed update has %d in stock at %.2f, want 7 at 35.00", product.Product.Quantity, product.Product.Price)
+	}
+}
+
+func TestUnpaidBackorderIsCancelled(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	user := s.signup()
+	other := s.signup()
+	s.addAddress(user.Token)
+	kettle := s.addProduct(adminToken, map[string]interface{}{"Name": "Kettle", "Price": 30, "Quantity": 0, "StockPolicy": "backorder", "BackorderLimit": 2, "Status": "published"})
+
+	// The order holds no reservation, only the backorder limit.
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=2", kettle), user.Token, nil, nil)
+	var checkout struct{ Data models.Order }
+	s.expect(http.StatusOK, "POST", "/cartcheckout", user.Token, nil, &checkout)
+	s.expect(http.StatusConflict, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=1", kettle), other.Token, nil, nil)
+
+	if err := s.db.Model(&models.Order{}).Where("id = ?", checkout.Data.ID).
+		Update("created_at", time.Now().Add(-database.ReservationLifetime-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	s.expect(http.StatusConflict, "POST", fmt.Sprintf("/orders/%d/capture", checkout.Data.ID), user.Token, nil, nil)
+	if err := database.ReleaseExpiredReservations(context.Background(), s.db, s.app.PreOrderPaymentWindow); err != nil {
+		t.Fatal(err)
+	}
+	var order models.Order
+	if err := s.db.First(&order, checkout.Data.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if order.OrderStatus != models.OrderStatusCancelled {
+		t.Errorf("overdue order is %q, want cancelled", order.OrderStatus)
+	}
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=1", kettle), other.Token, nil, nil)
+}
+
+func TestReleasedPreOrderPaymentWindow(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	releaseDate := time.Now().Add(24 * time.Hour)
+	console := s.addProduct(adminToken, map[string]interface{}{"Name": "Console", "Price": 400, "Quantity": 0, "StockPolicy": "preorder", "ReleaseDate": releaseDate, "Status": "published"})
+	preOrder := func(released time.Time) (string, int64) {
+		user := s.signup()
+		s.addAddress(user.Token)
+		s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d", console), user.Token, nil, nil)
+		var checkout struct{ Data models.Order }
+		s.expect(http.StatusOK, "POST", "/cartcheckout", user.Token, nil, &checkout)
+		if !checkout.Data.PreOrder {
+			t.Fatalf("order %+v is not a pre-order", checkout.Data)
+		}
+		if err := s.db.Model(&models.Order{}).Where("id = ?", checkout.Data.ID).Update("release_date", released).Error; err != nil {
+			t.Fatal(err)
+		}
+		return user.Token, checkout.Data.ID
+	}
+	// Released an hour ago: well past the checkout reservation lifetime,
+	// but inside the pre-order payment window.
+	recentToken, recent := preOrder(time.Now().Add(-time.Hour))
+	_, lapsed := preOrder(time.Now().Add(-s.app.PreOrderPaymentWindow - time.Minute))
+
+	if err := database.ReleaseExpiredReservations(context.Background(), s.db, s.app.PreOrderPaymentWindow); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []struct {
+		id     int64
+		status string
+	}{
+		{recent, models.OrderStatusPendingPayment},
+		{lapsed, models.OrderStatusCancelled},
+	} {
+		var order models.Order
+		if err := s.db.First(&order, want.id).Error; err != nil {
+			t.Fatal(err)
+		}
+		if order.OrderStatus != want.status {
+			t.Errorf("order %d is %q after the sweep, want %q", want.id, order.OrderStatus, want.status)
+		}
+	}
+	s.expect(http.StatusOK, "POST", fmt.Sprintf("/orders/%d/capture", recent), recentToken, nil, nil)
+}
+
+func TestSaleCompareAtPrice(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	phone := s.addProduct(adminToken, map[string]interface{}{"Name": "Phone", "Price": 100, "Quantity": 5, "Status": "published"})
+	update := fmt.Sprintf("/admin/update-product/%d", phone)
+	s.expect(http.StatusOK, "PUT", update, adminToken, map[string]float64{"Price": 80}, nil)
+	s.expect(http.StatusOK, "PUT", update, adminToken, map[string]float64{"Price": 100, "CompareAtPrice": 150}, nil)
+
+	startSale := func(price float64) int64 {
+		t.Helper()
+		var created struct{ Data models.PriceSchedule }
+		sale := map[string]interface{}{"price": price, "starts_at": time.Now().Add(-time.Minute), "ends_at": time.Now().Add(time.Hour)}
+		s.expect(http.StatusCreated, "POST", fmt.Sprintf("/admin/products/%d/price-schedules", phone), adminToken, sale, &created)
+		if err := database.ApplyPriceSchedules(context.Background(), s.db); err != nil {
+			t.Fatal(err)
+		}
+		return created.Data.ID
+	}
+	check := func(when string, price float64, compareAt *float64) {
+		t.Helper()
+		var product struct{ Product models.Product }
+		s.expect(http.StatusOK, "GET", fmt.Sprintf("/get-product/%d", phone), "", nil, &product)
+		got := product.Product
+		if got.Price != price || (got.CompareAtPrice == nil) != (compareAt == nil) || compareAt != nil && *got.CompareAtPrice != *compareAt {
+			t.Errorf("%s: price %.2f, compare-at %v; want %.2f, %v", when, got.Price, got.CompareAtPrice, price, compareAt)
+		}
+	}
+	price := func(value float64) *float64 { return &value }
+
+	// The sale shows the lowest price of the last 30 days as the "was"
+	// price and gives the admin's one back when it ends.
+	sale := startSale(70)
+	check("during the sale", 70, price(80))
+	s.expect(http.StatusOK, "DELETE", fmt.Sprintf("/admin/price-schedules/%d", sale), adminToken, nil, nil)
+	check("after the sale", 100, price(150))
+
+	// A compare-at price set during the sale is kept.
+	sale = startSale(60)
+	s.expect(http.StatusOK, "PUT", update, adminToken, map[string]float64{"CompareAtPrice": 140}, nil)
+	s.expect(http.StatusOK, "DELETE", fmt.Sprintf("/admin/price-schedules/%d", sale), adminToken, nil, nil)
+	check("after the second sale", 100, price(140))
+}
+
+func TestImportJobs(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+
+	csv := fmt.Sprintf("sku,name,description,category_id,price,quantity,status\nMUG-1,Mug,A mug,%d,8,5,published\n", s.category)
+	status, body := s.upload("/admin/products/import", adminToken, "file", "products.csv", csv)
+	if status != http.StatusAccepted {
+		t.Fatalf("import got status %d: %s", status, body)
+	}
+	var queued struct{ Data models.ImportJob }
+	if err := json.Unmarshal(body, &queued); err != nil {
+		t.Fatal(err)
+	}
+
+	// Imports run as background jobs that can be waited for.
+	s.app.Jobs.Wait()
+	var job struct{ Data models.ImportJob }
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/admin/imports/%d", queued.Data.ID), adminToken, nil, &job)
+	if job.Data.Status != models.ImportStatusCompleted || job.Data.CreatedRows != 1 {
+		t.Errorf("import job = %+v, want completed with 1 created row", job.Data)
+	}
+
+	// A job the previous process left running is failed on startup.
+	interrupted := models.ImportJob{Format: "csv", Status: models.ImportStatusRunning}
+	if err := s.db.Create(&interrupted).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.FailInterruptedImports(context.Background(), s.db); err != nil {
+		t.Fatal(err)
+	}
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/admin/imports/%d", interrupted.ID), adminToken, nil, &job)
+	if job.Data.Status != models.ImportStatusFailed {
+		t.Errorf("interrupted job is %q, want failed", job.Data.Status)
+	}
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/admin/imports/%d", queued.Data.ID), adminToken, nil, &job)
+	if job.Data.Status != models.ImportStatusCompleted {
+		t.Errorf("finished job is %q after the startup sweep, want completed", job.Data.Status)
+	}
+}
+
+// recordingNotifier keeps the notifications it is asked to send.
+type recordingNotifier struct{ sent []notify.Notification }
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification notify.Notification) error {
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+func TestWishlistAlerts(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	user := s.signup()
+	lantern := s.addProduct(adminToken, map[string]interface{}{"Name": "Lantern", "Price": 50, "Quantity": 0, "Status": "published"})
+	var created struct{ Data models.Wishlist }
+	s.expect(http.StatusCreated, "POST", "/wishlists", user.Token, map[string]string{"name": "Camping"}, &created)
+	stove := s.addProduct(adminToken, map[string]interface{}{"Name": "Stove", "Price": 80, "Quantity": 0, "Status": "published"})
+	tarp := s.addProduct(adminToken, map[string]interface{}{"Name": "Tarp", "Price": 20, "Quantity": 0, "Status": "published"})
+	for _, product := range []int64{lantern, stove, tarp} {
+		item := map[string]interface{}{"product_id": product, "notify_back_in_stock": true, "notify_price_drop": true}
+		s.expect(http.StatusCreated, "POST", fmt.Sprintf("/wishlists/%d/items", created.Data.ID), user.Token, item, nil)
+	}
+
+	// Back in stock and cheaper at once makes one message with both.
+	s.expect(http.StatusOK, "PUT", fmt.Sprintf("/admin/update-product/%d", lantern), adminToken, map[string]interface{}{"Price": 40, "Quantity": 3}, nil)
+	// Restocked units that are already reserved can't be bought.
+	s.expect(http.StatusOK, "PUT", fmt.Sprintf("/admin/update-product/%d", stove), adminToken, map[string]interface{}{"Quantity": 1}, nil)
+	buyer := s.signup()
+	s.addAddress(buyer.Token)
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d", stove), buyer.Token, nil, nil)
+	s.expect(http.StatusOK, "POST", "/cartcheckout", buyer.Token, nil, nil)
+	// Neither can a product taken off the shop.
+	s.expect(http.StatusOK, "PUT", fmt.Sprintf("/admin/update-product/%d", tarp), adminToken, map[string]interface{}{"Quantity": 5}, nil)
+	s.expect(http.StatusOK, "PATCH", fmt.Sprintf("/admin/products/%d/status", tarp), adminToken, map[string]string{"status": "archived"}, nil)
+	notifier := &recordingNotifier{}
+	for range 2 {
+		if err := database.CheckWishlistAlerts(context.Background(), s.db, notifier); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(notifier.sent) != 1 {
+		t.Fatalf("sent %d alerts, want only the lantern's: %+v", len(notifier.sent), notifier.sent)
+	}
+	body := notifier.sent[0].Body
+	if !strings.Contains(body, "back in stock") || !strings.Contains(body, "dropped from 50.00 to 40.00") {
+		t.Errorf("alert %q doesn't mention both the restock and the price drop", body)
+	}
+}
+
+func TestDigitalDownloads(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	user := s.signup()
+	s.addAddress(user.Token)
+	ebook := s.addProduct(adminToken, map[string]interface{}{"Name": "Ebook", "Price": 10, "IsDigital": true, "DownloadLimit": 2, "Status": "published"})
+	if status, body := s.upload(fmt.Sprintf("/admin/products/%d/files", ebook), adminToken, "file", "book.pdf", "%PDF-1.7"); status != http.StatusCreated {
+		t.Fatalf("upload got status %d: %s", status, body)
+	}
+	var files struct{ Data []models.ProductFile }
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/admin/products/%d/files", ebook), adminToken, nil, &files)
+
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/addtocart?id=%d&quantity=1", ebook), user.Token, nil, nil)
+	var checkout struct{ Data models.Order }
+	s.expect(http.StatusOK, "POST", "/cartcheckout", user.Token, nil, &checkout)
+	s.expect(http.StatusOK, "POST", fmt.Sprintf("/orders/%d/capture", checkout.Data.ID), user.Token, nil, nil)
+	var downloads struct{ Data []struct{ URL string } }
+	s.expect(http.StatusOK, "GET", fmt.Sprintf("/orders/%d/downloads", checkout.Data.ID), user.Token, nil, &downloads)
+	if len(downloads.Data) != 1 {
+		t.Fatalf("got %d downloads, want 1", len(downloads.Data))
+	}
+	link := downloads.Data[0].URL
+
+	// A file that can't be opened doesn't use up the download limit.
+	ctx := context.Background()
+	key := files.Data[0].StorageKey
+	if err := s.app.Files.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	s.expect(http.StatusNotFound, "GET", link, "", nil, nil)
+	if _, err := s.app.Files.Put(ctx, key, strings.NewReader("%PDF-1.7")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if status := s.send("GET", link, nil, nil, nil); status != http.StatusOK {
+			t.Fatalf("download %d got status %d", i+1, status)
+		}
+	}
+	s.expect(http.StatusForbidden, "GET", link, "", nil, nil)
+}
+
+func TestProductImageCaching(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.admin()
+	lamp := s.addProduct(adminToken, map[string]interface{}{"Name": "Lamp", "Price": 25, "Quantity": 3, "Status": "published"})
+	var picture bytes.Buffer
+	if err := png.Encode(&picture, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	status, body := s.upload(fmt.Sprintf("/admin/products/%d/images", lamp), adminToken, "images", "lamp.png", picture.String())
+	if status != http.StatusCreated {
+		t.Fatalf("upload got status %d: %s", status, body)
+	}
+	var uploaded struct {
+		Data []struct {
+			Image models.ProductImage
+			URLs  map[string]string `json:"urls"`
+		}
+	}
+	if err := json.Unmarshal(body, &uploaded); err != nil {
+		t.Fatal(err)
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		return rec
+	}
+
+	rec := get(uploaded.Data[0].URLs["medium"])
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Cache-Control"), "immutable") || rec.Header().Get("ETag") == "" {
+		t.Errorf("medium variant got status %d with Cache-Control %q and ETag %q, want a cacheable 200",
+			rec.Code, rec.Header().Get("Cache-Control"), rec.Header().Get("ETag"))
+	}
+	// A variant that can't be read must not be cached as if it were final.
+	if err := s.app.Files.Delete(context.Background(), uploaded.Data[0].Image.OriginalKey); err != nil {
+		t.Fatal(err)
+	}
+	rec = get(uploaded.Data[0].URLs["original"])
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Cache-Control") != "no-store" || rec.Header().Get("ETag") != "" {
+		t.Errorf("missing original got status %d with Cache-Control %q and ETag %q, want an uncached 404",
+			rec.Code, rec.Header().Get("Cache-Control"), rec.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,212 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"githum.com/muhammadAslam/ecommerce/config"
+	"githum.com/muhammadAslam/ecommerce/controllers"
+	"githum.com/muhammadAslam/ecommerce/models"
+	"githum.com/muhammadAslam/ecommerce/repository"
+	"githum.com/muhammadAslam/ecommerce/routes"
+	"githum.com/muhammadAslam/ecommerce/storage"
+	"githum.com/muhammadAslam/ecommerce/tokens"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	os.Exit(m.Run())
+}
+
+// testServer is the whole API served from the GORM repositories over a
+// fresh SQLite database, so the tests run the same database code as the
+// server.
+type testServer struct {
+	t        *testing.T
+	router   *gin.Engine
+	app      *controllers.Application
+	db       *gorm.DB
+	repos    repository.Repositories
+	tokens   *tokens.Manager
+	users    int
+	category int64
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Auth.SecretKey = "a-secret-key-for-tests-only-0123456789"
+	db := newTestDB(t)
+	repos := repository.NewGorm(db)
+	manager := tokens.NewManager(cfg.Auth)
+	app := controllers.NewApplication(repos, manager)
+	app.Files = storage.NewLocalStore(t.TempDir())
+	category := models.Category{Name: "General", Slug: "general"}
+	if err := db.Create(&category).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &testServer{t: t, router: routes.NewRouter(app, "test"), app: app, db: db, repos: repos, tokens: manager, category: category.ID}
+}
+
+// newTestDB creates the schema from the models in a SQLite file that is
+// removed with the test, plus the default warehouse the first migration
+// adds. The migrations are checked against the same models in the
+// migrations package.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Warehouse{Code: "MAIN", Name: "Main warehouse", Active: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// request sends body as JSON with the access token, if any, and decodes
+// the JSON response into out when it is given. It returns the status.
+func (s *testServer) request(method string, path string, token string, body interface{}, out interface{}) int {
+	s.t.Helper()
+	return s.send(method, path, map[string]string{"token": token}, body, out)
+}
+
+// send is request with arbitrary headers; empty values are not sent.
+func (s *testServer) send(method string, path string, headers map[string]string, body interface{}, out interface{}) int {
+	s.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("encoding %s %s: %v", method, path, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		if value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if out != nil && rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("decoding %s %s: %v: %s", method, path, err, rec.Body)
+		}
+	}
+	return rec.Code
+}
+
+// upload posts content as the multipart file field and returns the status
+// and body of the response.
+func (s *testServer) upload(path string, token string, field string, filename string, content string) (int, []byte) {
+	s.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile(field, filename)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	io.WriteString(file, content)
+	form.Close()
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("token", token)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.Bytes()
+}
+
+// expect is request for calls that must succeed with the given status.
+func (s *testServer) expect(status int, method string, path string, token string, body interface{}, out interface{}) {
+	s.t.Helper()
+	var raw json.RawMessage
+	if code := s.request(method, path, token, body, &raw); code != status {
+		s.t.Fatalf("%s %s: got status %d, want %d: %s", method, path, code, status, raw)
+	}
+	if out != nil && len(raw) > 0 {
+		if err := json.Unmarshal(raw, out); err != nil {
+			s.t.Fatalf("decoding %s %s: %v", method, path, err)
+		}
+	}
+}
+
+// signup registers a customer with a unique email and phone and returns
+// the user with their access token.
+func (s *testServer) signup() models.User {
+	s.t.Helper()
+	s.users++
+	var response struct{ Data models.User }
+	s.expect(201, "POST", "/signup", "", map[string]string{
+		"Name":     fmt.Sprintf("Customer %d", s.users),
+		"Email":    fmt.Sprintf("customer%d@example.com", s.users),
+		"Phone":    fmt.Sprintf("+100000000%02d", s.users),
+		"Password": "secret-password",
+	}, &response)
+	return response.Data
+}
+
+// admin stores an admin user and returns their access token.
+func (s *testServer) admin() string {
+	s.t.Helper()
+	admin := models.User{Name: "Admin", Email: "admin@example.com", Phone: "+19999999999", Roles: "admin"}
+	if err := s.repos.Users.Create(context.Background(), &admin); err != nil {
+		s.t.Fatal(err)
+	}
+	token, _, err := s.tokens.GenerateAllTokens(admin.Email, admin.Name, admin.ID, admin.Roles)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return token
+}
+
+// addProduct creates a product through the admin API and returns its id.
+// Products go into the test category unless they name one.
+func (s *testServer) addProduct(adminToken string, product map[string]interface{}) int64 {
+	s.t.Helper()
+	if _, ok := product["CategoryID"]; !ok {
+		product["CategoryID"] = s.category
+	}
+	var response struct{ Data models.Product }
+	s.expect(201, "POST", "/admin/add-products", adminToken, product, &response)
+	return response.Data.ID
+}
+
+// addAddress adds a valid address to the user's address book.
+func (s *testServer) addAddress(token string) int64 {
+	s.t.Helper()
+	var response struct{ Data models.Address }
+	s.expect(201, "POST", "/addresses", token, map[string]string{
+		"RecipientName": "Jane Doe",
+		"Street":        "1 Market Street",
+		"City":          "San Francisco",
+		"State":         "CA",
+		"PostalCode":    "94103",
+		"Country":       "US",
+	}, &response)
+	return response.Data.ID
+}
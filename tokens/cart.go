@@ -0,0 +1,43 @@
+package tokens
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidCartToken = errors.New("invalid cart token")
+
+// GenerateCartToken creates a new guest cart id and the signed token handed
+// to the browser. Only the id is stored; the signature proves the token was
+// issued by us.
+func (m *Manager) GenerateCartToken() (cartId string, signedToken string, err error) {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	cartId = base64.RawURLEncoding.EncodeToString(buf)
+	return cartId, cartId + "." + m.signCartId(cartId), nil
+}
+
+// ValidateCartToken checks the signature of a guest cart token and returns
+// the cart id it carries.
+func (m *Manager) ValidateCartToken(signedToken string) (string, error) {
+	cartId, signature, ok := strings.Cut(signedToken, ".")
+	if !ok || cartId == "" {
+		return "", ErrInvalidCartToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(m.signCartId(cartId))) {
+		return "", ErrInvalidCartToken
+	}
+	return cartId, nil
+}
+
+func (m *Manager) signCartId(cartId string) string {
+	mac := hmac.New(sha256.New, append([]byte("cart:"), m.secret...))
+	mac.Write([]byte(cartId))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,38 @@
+package tokens
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidDownloadLink = errors.New("invalid download link")
+	ErrDownloadLinkExpired = errors.New("download link expired")
+)
+
+// SignDownload signs a link to a file of a purchased order item that is
+// valid until expires.
+func (m *Manager) SignDownload(orderItemId int64, fileId int64, expires time.Time) string {
+	return m.signDownload(orderItemId, fileId, expires.Unix())
+}
+
+// ValidateDownload checks the signature and expiry of a download link.
+func (m *Manager) ValidateDownload(orderItemId int64, fileId int64, expires int64, signature string) error {
+	if !hmac.Equal([]byte(signature), []byte(m.signDownload(orderItemId, fileId, expires))) {
+		return ErrInvalidDownloadLink
+	}
+	if time.Now().Unix() > expires {
+		return ErrDownloadLinkExpired
+	}
+	return nil
+}
+
+func (m *Manager) signDownload(orderItemId int64, fileId int64, expires int64) string {
+	mac := hmac.New(sha256.New, append([]byte("download:"), m.secret...))
+	mac.Write([]byte(strconv.FormatInt(orderItemId, 10) + ":" + strconv.FormatInt(fileId, 10) + ":" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -2,33 +2,49 @@ package tokens
 
 import (
 	"errors"
-	"os"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"githum.com/muhammadAslam/ecommerce/config"
 	"githum.com/muhammadAslam/ecommerce/models"
-	"gorm.io/gorm"
 )
 
-var SECRET_KEY string = os.Getenv("SECRET_KEY")
+// Manager issues and checks the tokens the API hands out: access and
+// refresh tokens, guest cart tokens and download links, all signed with
+// the configured secret key.
+type Manager struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewManager(cfg config.Auth) *Manager {
+	return &Manager{
+		secret:     []byte(cfg.SecretKey.Value()),
+		accessTTL:  time.Duration(cfg.AccessTokenTTL),
+		refreshTTL: time.Duration(cfg.RefreshTokenTTL),
+	}
+}
 
-func GenerateAllTokens(db *gorm.DB, email string, name string) (signedToken string, signedRefreshToken string, err error) {
+func (m *Manager) GenerateAllTokens(email string, name string, uid int64, roles string) (signedToken string, signedRefreshToken string, err error) {
 	claims := &models.SignedDetails{
+		Uid:   uid,
 		Email: email,
 		Name:  name,
+		Roles: roles,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
+			ExpiresAt: time.Now().Add(m.accessTTL).Unix(),
 		},
 	}
 
 	refreshClaims := &models.SignedDetails{
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(168 * time.Hour).Unix(),
+			ExpiresAt: time.Now().Add(m.refreshTTL).Unix(),
 		},
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(SECRET_KEY))
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString(m.secret)
 
 	if err != nil {
 		return "", "", err
@@ -37,20 +53,7 @@ func GenerateAllTokens(db *gorm.DB, email string, name string) (signedToken stri
 	return token, refreshToken, nil
 }
 
-func UpdateAllTokens(db *gorm.DB, signedToken string, signedRefreshToken string, userId int64) error {
-	user := models.User{}
-	result := db.First(&user, userId)
-	if result.Error != nil {
-		return result.Error
-	}
-
-	user.Token = signedToken
-	user.RefreshToken = signedRefreshToken
-	user.UpdatedAt = time.Now()
-
-	return db.Save(&user).Error
-}
-func ValidateToken(signedToken string) (*models.SignedDetails, error) {
+func (m *Manager) ValidateToken(signedToken string) (*models.SignedDetails, error) {
 	// Parse the token
 	token, err := jwt.ParseWithClaims(
 		signedToken,
@@ -60,7 +63,7 @@ func ValidateToken(signedToken string) (*models.SignedDetails, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, errors.New("unexpected signing method")
 			}
-			return []byte(SECRET_KEY), nil
+			return m.secret, nil
 		},
 	)
 
@@ -0,0 +1,176 @@
+// Package validation checks and normalizes customer addresses against
+// per-country rules before they are stored or used for an order.
+package validation
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+// FieldError describes a single invalid address field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors is returned by ValidateAddress when one or more fields are invalid.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldError := range e {
+		messages[i] = fieldError.Field + ": " + fieldError.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+var phonePattern = regexp.MustCompile(`^\+?\d{6,15}$`)
+
+// NormalizeAddress cleans up an address in place: whitespace is collapsed,
+// countries and subdivisions become ISO codes where they can be recognized,
+// postal codes are upper-cased and spaced the way the country writes them,
+// and all-upper or all-lower names are title-cased.
+func NormalizeAddress(address *models.Address) {
+	address.RecipientName = fixCase(collapseSpaces(address.RecipientName))
+	address.Street = fixCase(collapseSpaces(address.Street))
+	address.City = fixCase(collapseSpaces(address.City))
+	address.Country = normalizeCountry(address.Country)
+	address.State = normalizeState(address.Country, collapseSpaces(address.State))
+	address.PostalCode = normalizePostalCode(address.Country, address.PostalCode)
+	address.Phone = normalizePhone(address.Phone)
+}
+
+// ValidateAddress checks a normalized address against the rules of its
+// country. It returns nil or an Errors value.
+func ValidateAddress(address models.Address) error {
+	var errs Errors
+	if address.RecipientName == "" {
+		errs = append(errs, FieldError{"recipient_name", "is required"})
+	}
+	if address.Street == "" {
+		errs = append(errs, FieldError{"street", "is required"})
+	}
+	if address.City == "" {
+		errs = append(errs, FieldError{"city", "is required"})
+	}
+	if address.Phone != "" && !phonePattern.MatchString(address.Phone) {
+		errs = append(errs, FieldError{"phone", "must be 6 to 15 digits, optionally starting with +"})
+	}
+	if !IsCountryCode(address.Country) {
+		errs = append(errs, FieldError{"country", "must be an ISO 3166-1 alpha-2 country code"})
+		return errs
+	}
+
+	rule := RuleFor(address.Country)
+	switch {
+	case address.State == "" && rule.StateRequired:
+		errs = append(errs, FieldError{"state", "is required in " + CountryName(address.Country)})
+	case address.State != "" && rule.Subdivisions != nil:
+		if _, ok := rule.Subdivisions[address.State]; !ok {
+			errs = append(errs, FieldError{"state", "is not a subdivision of " + CountryName(address.Country)})
+		}
+	}
+	switch {
+	case address.PostalCode == "" && rule.PostalCodeRequired:
+		errs = append(errs, FieldError{"postal_code", "is required in " + CountryName(address.Country)})
+	case address.PostalCode != "" && rule.PostalCode != nil && !rule.PostalCode.MatchString(address.PostalCode):
+		errs = append(errs, FieldError{"postal_code", "is not a valid postal code for " + CountryName(address.Country)})
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func collapseSpaces(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// fixCase title-cases text typed entirely in one case ("LAHORE", "new york")
+// and leaves mixed-case text such as "McDonald St" alone.
+func fixCase(value string) string {
+	if value != strings.ToUpper(value) && value != strings.ToLower(value) {
+		return value
+	}
+	runes := []rune(strings.ToLower(value))
+	start := true
+	for i, r := range runes {
+		if start && unicode.IsLetter(r) {
+			runes[i] = unicode.ToUpper(r)
+		}
+		start = unicode.IsSpace(r) || r == '-' || r == '/'
+	}
+	return string(runes)
+}
+
+func normalizeCountry(value string) string {
+	value = strings.ToUpper(collapseSpaces(value))
+	if IsCountryCode(value) {
+		return value
+	}
+	if code, ok := countryAliases[value]; ok {
+		return code
+	}
+	for code, name := range countryNames {
+		if strings.ToUpper(name) == value {
+			return code
+		}
+	}
+	return value
+}
+
+// normalizeState maps "US-CA", "ca" or "California" to "CA" for countries
+// whose subdivisions are known. Other values are returned as given.
+func normalizeState(country string, value string) string {
+	rule := RuleFor(country)
+	if rule.Subdivisions == nil || value == "" {
+		return value
+	}
+	code := strings.TrimPrefix(strings.ToUpper(value), country+"-")
+	if _, ok := rule.Subdivisions[code]; ok {
+		return code
+	}
+	for code, name := range rule.Subdivisions {
+		if strings.EqualFold(name, value) {
+			return code
+		}
+	}
+	return value
+}
+
+func normalizePostalCode(country string, value string) string {
+	value = strings.ToUpper(collapseSpaces(value))
+	compact := strings.ReplaceAll(value, " ", "")
+	switch country {
+	case "CA", "GB":
+		// Inward code is always the last three characters.
+		if len(compact) > 3 {
+			return compact[:len(compact)-3] + " " + compact[len(compact)-3:]
+		}
+	case "NL":
+		if len(compact) == 6 {
+			return compact[:4] + " " + compact[4:]
+		}
+	case "SE":
+		if len(compact) == 5 {
+			return compact[:3] + " " + compact[3:]
+		}
+	case "US", "DE", "FR", "IT", "ES", "PK", "IN", "AU", "CN", "BE", "AT", "CH":
+		return compact
+	}
+	return value
+}
+
+func normalizePhone(value string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(value) {
+		if unicode.IsDigit(r) || (r == '+' && i == 0) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,117 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+// addressFields are the parts of an address normalization touches.
+func addressFields(a models.Address) [7]string {
+	return [7]string{a.RecipientName, a.Street, a.City, a.State, a.PostalCode, a.Country, a.Phone}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		in   models.Address
+		want models.Address
+	}{
+		{
+			name: "us address",
+			in:   models.Address{RecipientName: "  JANE   DOE ", Street: "1 market st", City: "SAN FRANCISCO", State: "california", PostalCode: "94103 ", Country: "usa", Phone: " +1 (415) 555-0100 "},
+			want: models.Address{RecipientName: "Jane Doe", Street: "1 Market St", City: "San Francisco", State: "CA", PostalCode: "94103", Country: "US", Phone: "+14155550100"},
+		},
+		{
+			name: "state with country prefix",
+			in:   models.Address{RecipientName: "Jean Tremblay", Street: "Rue Saint-Paul", City: "Montréal", State: "ca-qc", PostalCode: "h2y1z1", Country: "Canada"},
+			want: models.Address{RecipientName: "Jean Tremblay", Street: "Rue Saint-Paul", City: "Montréal", State: "QC", PostalCode: "H2Y 1Z1", Country: "CA"},
+		},
+		{
+			name: "mixed case is kept",
+			in:   models.Address{RecipientName: "Ian McDonald", Street: "10 Downing St", City: "London", PostalCode: "sw1a2aa", Country: "UK"},
+			want: models.Address{RecipientName: "Ian McDonald", Street: "10 Downing St", City: "London", PostalCode: "SW1A 2AA", Country: "GB"},
+		},
+		{
+			name: "hyphenated names",
+			in:   models.Address{RecipientName: "anna o'neil-smith", Street: "keizersgracht 1", City: "amsterdam", PostalCode: "1015cj", Country: "holland"},
+			want: models.Address{RecipientName: "Anna O'neil-Smith", Street: "Keizersgracht 1", City: "Amsterdam", PostalCode: "1015 CJ", Country: "NL"},
+		},
+		{
+			name: "swedish postal code",
+			in:   models.Address{RecipientName: "Sven", Street: "Drottninggatan 1", City: "Stockholm", PostalCode: "11151", Country: "se"},
+			want: models.Address{RecipientName: "Sven", Street: "Drottninggatan 1", City: "Stockholm", PostalCode: "111 51", Country: "SE"},
+		},
+		{
+			name: "unknown subdivision and country",
+			in:   models.Address{RecipientName: "Lucy", Street: "Lamp Post", City: "Cair Paravel", State: "Lantern Waste", PostalCode: "n 1", Country: "narnia"},
+			want: models.Address{RecipientName: "Lucy", Street: "Lamp Post", City: "Cair Paravel", State: "Lantern Waste", PostalCode: "N 1", Country: "NARNIA"},
+		},
+		{
+			name: "subdivision name for a country without a list",
+			in:   models.Address{RecipientName: "Max", Street: "Unter den Linden 1", City: "Berlin", State: "berlin", PostalCode: "10 117", Country: "Germany"},
+			want: models.Address{RecipientName: "Max", Street: "Unter den Linden 1", City: "Berlin", State: "berlin", PostalCode: "10117", Country: "DE"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.in
+			NormalizeAddress(&got)
+			if addressFields(got) != addressFields(test.want) {
+				t.Errorf("got %q, want %q", addressFields(got), addressFields(test.want))
+			}
+		})
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	valid := models.Address{RecipientName: "Jane Doe", Street: "1 Market St", City: "San Francisco", State: "CA", PostalCode: "94103", Country: "US"}
+	with := func(change func(address *models.Address)) models.Address {
+		address := valid
+		change(&address)
+		return address
+	}
+	tests := []struct {
+		name    string
+		address models.Address
+		fields  []string
+	}{
+		{"valid", valid, nil},
+		{"zip+4", with(func(a *models.Address) { a.PostalCode = "94103-1234" }), nil},
+		{"missing required fields", with(func(a *models.Address) { a.RecipientName, a.Street, a.City = "", "", "" }), []string{"recipient_name", "street", "city"}},
+		{"bad phone", with(func(a *models.Address) { a.Phone = "12-34" }), []string{"phone"}},
+		{"unknown country", with(func(a *models.Address) { a.Country, a.State = "XX", "" }), []string{"country"}},
+		{"state required", with(func(a *models.Address) { a.State = "" }), []string{"state"}},
+		{"unknown state", with(func(a *models.Address) { a.State = "ZZ" }), []string{"state"}},
+		{"postal code required", with(func(a *models.Address) { a.PostalCode = "" }), []string{"postal_code"}},
+		{"bad postal code", with(func(a *models.Address) { a.PostalCode = "9410" }), []string{"postal_code"}},
+		{"pakistan without postal code", models.Address{RecipientName: "Ali", Street: "Mall Road", City: "Lahore", State: "PB", Country: "PK"}, nil},
+		{"canada", models.Address{RecipientName: "Jean", Street: "Rue Saint-Paul", City: "Montreal", State: "QC", PostalCode: "H2Y 1Z1", Country: "CA"}, nil},
+		{"canadian postal code letters", models.Address{RecipientName: "Jean", Street: "Rue Saint-Paul", City: "Montreal", State: "QC", PostalCode: "D2Y 1Z1", Country: "CA"}, []string{"postal_code"}},
+		{"country without rules", models.Address{RecipientName: "Aroha", Street: "Queen St", City: "Auckland", PostalCode: "anything", Country: "NZ"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateAddress(test.address)
+			if test.fields == nil {
+				if err != nil {
+					t.Fatalf("got error %v, want none", err)
+				}
+				return
+			}
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("got error %v, want Errors", err)
+			}
+			var fields []string
+			for _, fieldError := range errs {
+				fields = append(fields, fieldError.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(test.fields, ",") {
+				t.Errorf("got errors %v, want fields %v", errs, test.fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package validation
+
+import "regexp"
+
+// countryNames lists every ISO 3166-1 alpha-2 code with its short English name.
+var countryNames = map[string]string{
+	"AD": "Andorra", "AE": "United Arab Emirates", "AF": "Afghanistan", "AG": "Antigua and Barbuda",
+	"AI": "Anguilla", "AL": "Albania", "AM": "Armenia", "AO": "Angola", "AQ": "Antarctica",
+	"AR": "Argentina", "AS": "American Samoa", "AT": "Austria", "AU": "Australia", "AW": "Aruba",
+	"AX": "Aland Islands", "AZ": "Azerbaijan", "BA": "Bosnia and Herzegovina", "BB": "Barbados",
+	"BD": "Bangladesh", "BE": "Belgium", "BF": "Burkina Faso", "BG": "Bulgaria", "BH": "Bahrain",
+	"BI": "Burundi", "BJ": "Benin", "BL": "Saint Barthelemy", "BM": "Bermuda", "BN": "Brunei Darussalam",
+	"BO": "Bolivia", "BQ": "Bonaire, Sint Eustatius and Saba", "BR": "Brazil", "BS": "Bahamas",
+	"BT": "Bhutan", "BV": "Bouvet Island", "BW": "Botswana", "BY": "Belarus", "BZ": "Belize",
+	"CA": "Canada", "CC": "Cocos (Keeling) Islands", "CD": "Congo, Democratic Republic of the",
+	"CF": "Central African Republic", "CG": "Congo", "CH": "Switzerland", "CI": "Cote d'Ivoire",
+	"CK": "Cook Islands", "CL": "Chile", "CM": "Cameroon", "CN": "China", "CO": "Colombia",
+	"CR": "Costa Rica", "CU": "Cuba", "CV": "Cabo Verde", "CW": "Curacao", "CX": "Christmas Island",
+	"CY": "Cyprus", "CZ": "Czechia", "DE": "Germany", "DJ": "Djibouti", "DK": "Denmark",
+	"DM": "Dominica", "DO": "Dominican Republic", "DZ": "Algeria", "EC": "Ecuador", "EE": "Estonia",
+	"EG": "Egypt", "EH": "Western Sahara", "ER": "Eritrea", "ES": "Spain", "ET": "Ethiopia",
+	"FI": "Finland", "FJ": "Fiji", "FK": "Falkland Islands", "FM": "Micronesia", "FO": "Faroe Islands",
+	"FR": "France", "GA": "Gabon", "GB": "United Kingdom", "GD": "Grenada", "GE": "Georgia",
+	"GF": "French Guiana", "GG": "Guernsey", "GH": "Ghana", "GI": "Gibraltar", "GL": "Greenland",
+	"GM": "Gambia", "GN": "Guinea", "GP": "Guadeloupe", "GQ": "Equatorial Guinea", "GR": "Greece",
+	"GS": "South Georgia and the South Sandwich Islands", "GT": "Guatemala", "GU": "Guam",
+	"GW": "Guinea-Bissau", "GY": "Guyana", "HK": "Hong Kong", "HM": "Heard Island and McDonald Islands",
+	"HN": "Honduras", "HR": "Croatia", "HT": "Haiti", "HU": "Hungary", "ID": "Indonesia",
+	"IE": "Ireland", "IL": "Israel", "IM": "Isle of Man", "IN": "India",
+	"IO": "British Indian Ocean Territory", "IQ": "Iraq", "IR": "Iran", "IS": "Iceland", "IT": "Italy",
+	"JE": "Jersey", "JM": "Jamaica", "JO": "Jordan", "JP": "Japan", "KE": "Kenya", "KG": "Kyrgyzstan",
+	"KH": "Cambodia", "KI": "Kiribati", "KM": "Comoros", "KN": "Saint Kitts and Nevis",
+	"KP": "Korea, Democratic People's Republic of", "KR": "Korea, Republic of", "KW": "Kuwait",
+	"KY": "Cayman Islands", "KZ": "Kazakhstan", "LA": "Lao People's Democratic Republic",
+	"LB": "Lebanon", "LC": "Saint Lucia", "LI": "Liechtenstein", "LK": "Sri Lanka", "LR": "Liberia",
+	"LS": "Lesotho", "LT": "Lithuania", "LU": "Luxembourg", "LV": "Latvia", "LY": "Libya",
+	"MA": "Morocco", "MC": "Monaco", "MD": "Moldova", "ME": "Montenegro", "MF": "Saint Martin (French part)",
+	"MG": "Madagascar", "MH": "Marshall Islands", "MK": "North Macedonia", "ML": "Mali", "MM": "Myanmar",
+	"MN": "Mongolia", "MO": "Macao", "MP": "Northern Mariana Islands", "MQ": "Martinique",
+	"MR": "Mauritania", "MS": "Montserrat", "MT": "Malta", "MU": "Mauritius", "MV": "Maldives",
+	"MW": "Malawi", "MX": "Mexico", "MY": "Malaysia", "MZ": "Mozambique", "NA": "Namibia",
+	"NC": "New Caledonia", "NE": "Niger", "NF": "Norfolk Island", "NG": "Nigeria", "NI": "Nicaragua",
+	"NL": "Netherlands", "NO": "Norway", "NP": "Nepal", "NR": "Nauru", "NU": "Niue", "NZ": "New Zealand",
+	"OM": "Oman", "PA": "Panama", "PE": "Peru", "PF": "French Polynesia", "PG": "Papua New Guinea",
+	"PH": "Philippines", "PK": "Pakistan", "PL": "Poland", "PM": "Saint Pierre and Miquelon",
+	"PN": "Pitcairn", "PR": "Puerto Rico", "PS": "Palestine, State of", "PT": "Portugal", "PW": "Palau",
+	"PY": "Paraguay", "QA": "Qatar", "RE": "Reunion", "RO": "Romania", "RS": "Serbia",
+	"RU": "Russian Federation", "RW": "Rwanda", "SA": "Saudi Arabia", "SB": "Solomon Islands",
+	"SC": "Seychelles", "SD": "Sudan", "SE": "Sweden", "SG": "Singapore", "SH": "Saint Helena",
+	"SI": "Slovenia", "SJ": "Svalbard and Jan Mayen", "SK": "Slovakia", "SL": "Sierra Leone",
+	"SM": "San Marino", "SN": "Senegal", "SO": "Somalia", "SR": "Suriname", "SS": "South Sudan",
+	"ST": "Sao Tome and Principe", "SV": "El Salvador", "SX": "Sint Maarten (Dutch part)",
+	"SY": "Syrian Arab Republic", "SZ": "Eswatini", "TC": "Turks and Caicos Islands", "TD": "Chad",
+	"TF": "French Southern Territories", "TG": "Togo", "TH": "Thailand", "TJ": "Tajikistan",
+	"TK": "Tokelau", "TL": "Timor-Leste", "TM": "Turkmenistan", "TN": "Tunisia", "TO": "Tonga",
+	"TR": "Turkiye", "TT": "Trinidad and Tobago", "TV": "Tuvalu", "TW": "Taiwan", "TZ": "Tanzania",
+	"UA": "Ukraine", "UG": "Uganda", "UM": "United States Minor Outlying Islands", "US": "United States",
+	"UY": "Uruguay", "UZ": "Uzbekistan", "VA": "Holy See", "VC": "Saint Vincent and the Grenadines",
+	"VE": "Venezuela", "VG": "Virgin Islands (British)", "VI": "Virgin Islands (U.S.)", "VN": "Viet Nam",
+	"VU": "Vanuatu", "WF": "Wallis and Futuna", "WS": "Samoa", "YE": "Yemen", "YT": "Mayotte",
+	"ZA": "South Africa", "ZM": "Zambia", "ZW": "Zimbabwe",
+}
+
+// countryAliases maps common spellings that are not the ISO short name.
+var countryAliases = map[string]string{
+	"USA":                       "US",
+	"UNITED STATES OF AMERICA":  "US",
+	"AMERICA":                   "US",
+	"UK":                        "GB",
+	"GREAT BRITAIN":             "GB",
+	"ENGLAND":                   "GB",
+	"SCOTLAND":                  "GB",
+	"WALES":                     "GB",
+	"NORTHERN IRELAND":          "GB",
+	"UAE":                       "AE",
+	"KSA":                       "SA",
+	"SOUTH KOREA":               "KR",
+	"NORTH KOREA":               "KP",
+	"RUSSIA":                    "RU",
+	"TURKEY":                    "TR",
+	"VIETNAM":                   "VN",
+	"HOLLAND":                   "NL",
+	"THE NETHERLANDS":           "NL",
+	"CZECH REPUBLIC":            "CZ",
+	"IRAN, ISLAMIC REPUBLIC OF": "IR",
+}
+
+// CountryRule describes what a deliverable address looks like in a country.
+type CountryRule struct {
+	// StateRequired means the address must name a subdivision (state,
+	// province, territory...).
+	StateRequired bool
+	// PostalCodeRequired means the address must carry a postal code.
+	PostalCodeRequired bool
+	// PostalCode, when set, is the format a normalized postal code must match.
+	PostalCode *regexp.Regexp
+	// Subdivisions maps ISO 3166-2 subdivision codes (without the country
+	// prefix) to their names. When set, State must be one of them.
+	Subdivisions map[string]string
+}
+
+// defaultRule applies to countries without specific rules: a street and city
+// are enough, and postal codes are accepted as given.
+var defaultRule = CountryRule{}
+
+var countryRules = map[string]CountryRule{
+	"US": {
+		StateRequired:      true,
+		PostalCodeRequired: true,
+		PostalCode:         regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+		Subdivisions: map[string]string{
+			"AL": "Alabama", "AK": "Alaska", "AZ": "Arizona", "AR": "Arkansas", "CA": "California",
+			"CO": "Colorado", "CT": "Connecticut", "DE": "Delaware", "DC": "District of Columbia",
+			"FL": "Florida", "GA": "Georgia", "HI": "Hawaii", "ID": "Idaho", "IL": "Illinois",
+			"IN": "Indiana", "IA": "Iowa", "KS": "Kansas", "KY": "Kentucky", "LA": "Louisiana",
+			"ME": "Maine", "MD": "Maryland", "MA": "Massachusetts", "MI": "Michigan", "MN": "Minnesota",
+			"MS": "Mississippi", "MO": "Missouri", "MT": "Montana", "NE": "Nebraska", "NV": "Nevada",
+			"NH": "New Hampshire", "NJ": "New Jersey", "NM": "New Mexico", "NY": "New York",
+			"NC": "North Carolina", "ND": "North Dakota", "OH": "Ohio", "OK": "Oklahoma", "OR": "Oregon",
+			"PA": "Pennsylvania", "RI": "Rhode Island", "SC": "South Carolina", "SD": "South Dakota",
+			"TN": "Tennessee", "TX": "Texas", "UT": "Utah", "VT": "Vermont", "VA": "Virginia",
+			"WA": "Washington", "WV": "West Virginia", "WI": "Wisconsin", "WY": "Wyoming",
+			"AS": "American Samoa", "GU": "Guam", "MP": "Northern Mariana Islands", "PR": "Puerto Rico",
+			"UM": "United States Minor Outlying Islands", "VI": "Virgin Islands",
+		},
+	},
+	"CA": {
+		StateRequired:      true,
+		PostalCodeRequired: true,
+		PostalCode:         regexp.MustCompile(`^[ABCEGHJ-NPRSTVXY]\d[ABCEGHJ-NPRSTV-Z] \d[ABCEGHJ-NPRSTV-Z]\d$`),
+		Subdivisions: map[string]string{
+			"AB": "Alberta", "BC": "British Columbia", "MB": "Manitoba", "NB": "New Brunswick",
+			"NL": "Newfoundland and Labrador", "NS": "Nova Scotia", "NT": "Northwest Territories",
+			"NU": "Nunavut", "ON": "Ontario", "PE": "Prince Edward Island", "QC": "Quebec",
+			"SK": "Saskatchewan", "YT": "Yukon",
+		},
+	},
+	"PK": {
+		StateRequired: true,
+		PostalCode:    regexp.MustCompile(`^\d{5}$`),
+		Subdivisions: map[string]string{
+			"BA": "Balochistan", "GB": "Gilgit-Baltistan", "IS": "Islamabad", "JK": "Azad Jammu and Kashmir",
+			"KP": "Khyber Pakhtunkhwa", "PB": "Punjab", "SD": "Sindh",
+		},
+	},
+	"IN": {
+		StateRequired:      true,
+		PostalCodeRequired: true,
+		PostalCode:         regexp.MustCompile(`^[1-9]\d{5}$`),
+		Subdivisions: map[string]string{
+			"AN": "Andaman and Nicobar Islands", "AP": "Andhra Pradesh", "AR": "Arunachal Pradesh",
+			"AS": "Assam", "BR": "Bihar", "CH": "Chandigarh", "CT": "Chhattisgarh",
+			"DH": "Dadra and Nagar Haveli and Daman and Diu", "DL": "Delhi", "GA": "Goa", "GJ": "Gujarat",
+			"HP": "Himachal Pradesh", "HR": "Haryana", "JH": "Jharkhand", "JK": "Jammu and Kashmir",
+			"KA": "Karnataka", "KL": "Kerala", "LA": "Ladakh", "LD": "Lakshadweep", "MH": "Maharashtra",
+			"ML": "Meghalaya", "MN": "Manipur", "MP": "Madhya Pradesh", "MZ": "Mizoram", "NL": "Nagaland",
+			"OR": "Odisha", "PB": "Punjab", "PY": "Puducherry", "RJ": "Rajasthan", "SK": "Sikkim",
+			"TG": "Telangana", "TN": "Tamil Nadu", "TR": "Tripura", "UP": "Uttar Pradesh",
+			"UT": "Uttarakhand", "WB": "West Bengal",
+		},
+	},
+	"AU": {
+		StateRequired:      true,
+		PostalCodeRequired: true,
+		PostalCode:         regexp.MustCompile(`^\d{4}$`),
+		Subdivisions: map[string]string{
+			"ACT": "Australian Capital Territory", "NSW": "New South Wales", "NT": "Northern Territory",
+			"QLD": "Queensland", "SA": "South Australia", "TAS": "Tasmania", "VIC": "Victoria",
+			"WA": "Western Australia",
+		},
+	},
+	"GB": {
+		PostalCodeRequired: true,
+		PostalCode:         regexp.MustCompile(`^([A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}|GIR 0AA)$`),
+	},
+	"DE": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{5}$`)},
+	"FR": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{5}$`)},
+	"IT": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{5}$`)},
+	"ES": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{5}$`)},
+	"NL": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{4} [A-Z]{2}$`)},
+	"BE": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{4}$`)},
+	"AT": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{4}$`)},
+	"CH": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{4}$`)},
+	"SE": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{3} \d{2}$`)},
+	"PL": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{2}-\d{3}$`)},
+	"BR": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{5}-\d{3}$`)},
+	"CN": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{6}$`)},
+	"JP": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{3}-\d{4}$`)},
+	"SA": {PostalCodeRequired: true, PostalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`)},
+}
+
+// RuleFor returns the validation rule for an ISO 3166-1 alpha-2 code.
+func RuleFor(country string) CountryRule {
+	if rule, ok := countryRules[country]; ok {
+		return rule
+	}
+	return defaultRule
+}
+
+// IsCountryCode reports whether code is an assigned ISO 3166-1 alpha-2 code.
+func IsCountryCode(code string) bool {
+	_, ok := countryNames[code]
+	return ok
+}
+
+// CountryName returns the short English name for an ISO 3166-1 alpha-2 code.
+func CountryName(code string) string {
+	return countryNames[code]
+}
@@ -0,0 +1,68 @@
+package validation
+
+import "testing"
+
+func TestCountryRules(t *testing.T) {
+	for country, rule := range countryRules {
+		if !IsCountryCode(country) {
+			t.Errorf("rule for unknown country %q", country)
+		}
+		if rule.StateRequired && rule.Subdivisions == nil {
+			t.Errorf("%s requires a state but lists no subdivisions", country)
+		}
+	}
+	for alias, country := range countryAliases {
+		if !IsCountryCode(country) {
+			t.Errorf("alias %q maps to unknown country %q", alias, country)
+		}
+	}
+}
+
+func TestPostalCodes(t *testing.T) {
+	tests := []struct {
+		country string
+		code    string
+		valid   bool
+	}{
+		{"US", "94103", true},
+		{"US", "94103-1234", true},
+		{"US", "9410", false},
+		{"CA", "K1A 0B1", true},
+		{"CA", "K1A0B1", false},
+		{"GB", "SW1A 1AA", true},
+		{"GB", "M1 1AE", true},
+		{"GB", "GIR 0AA", true},
+		{"GB", "SW1A1AA", false},
+		{"NL", "1015 CJ", true},
+		{"NL", "1015CJ", false},
+		{"SE", "111 51", true},
+		{"PL", "00-950", true},
+		{"PL", "00950", false},
+		{"BR", "01310-100", true},
+		{"JP", "100-0001", true},
+		{"IN", "110001", true},
+		{"IN", "010001", false},
+		{"AU", "2000", true},
+		{"PK", "54000", true},
+	}
+	for _, test := range tests {
+		rule := RuleFor(test.country)
+		if rule.PostalCode == nil {
+			t.Errorf("%s has no postal code format", test.country)
+			continue
+		}
+		if got := rule.PostalCode.MatchString(test.code); got != test.valid {
+			t.Errorf("%s postal code %q: got valid %v, want %v", test.country, test.code, got, test.valid)
+		}
+	}
+}
+
+func TestRuleForUnknownCountry(t *testing.T) {
+	rule := RuleFor("NZ")
+	if rule.StateRequired || rule.PostalCodeRequired || rule.PostalCode != nil || rule.Subdivisions != nil {
+		t.Errorf("NZ got rule %+v, want the default rule", rule)
+	}
+	if CountryName("NZ") != "New Zealand" || CountryName("XX") != "" {
+		t.Errorf("CountryName got %q and %q", CountryName("NZ"), CountryName("XX"))
+	}
+}
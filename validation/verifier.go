@@ -0,0 +1,32 @@
+package validation
+
+import (
+	"context"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+// Verification is the outcome of checking an address with a provider.
+type Verification struct {
+	Deliverable bool            `json:"deliverable"`
+	Messages    []string        `json:"messages,omitempty"`
+	Suggestion  *models.Address `json:"suggestion,omitempty"`
+}
+
+// Verifier checks whether an address can actually be delivered to. Real
+// implementations call an external address-verification service; the
+// result may include a corrected address to offer the customer.
+type Verifier interface {
+	Verify(ctx context.Context, address models.Address) (*Verification, error)
+}
+
+// OfflineVerifier is a Verifier that never leaves the process. It accepts
+// every address that passes the local country rules.
+type OfflineVerifier struct{}
+
+func (OfflineVerifier) Verify(ctx context.Context, address models.Address) (*Verification, error) {
+	if err := ValidateAddress(address); err != nil {
+		return &Verification{Deliverable: false, Messages: []string{err.Error()}}, nil
+	}
+	return &Verification{Deliverable: true}, nil
+}
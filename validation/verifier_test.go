@@ -0,0 +1,36 @@
+package validation
+
+import (
+	"context"
+	"testing"
+
+	"githum.com/muhammadAslam/ecommerce/models"
+)
+
+func TestOfflineVerifier(t *testing.T) {
+	tests := []struct {
+		name        string
+		address     models.Address
+		deliverable bool
+	}{
+		{"valid", models.Address{RecipientName: "Jane", Street: "1 Market St", City: "San Francisco", State: "CA", PostalCode: "94103", Country: "US"}, true},
+		{"invalid", models.Address{RecipientName: "Jane", Street: "1 Market St", City: "San Francisco", Country: "US"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verification, err := OfflineVerifier{}.Verify(context.Background(), test.address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verification.Deliverable != test.deliverable {
+				t.Errorf("got deliverable %v, want %v", verification.Deliverable, test.deliverable)
+			}
+			if !test.deliverable && len(verification.Messages) == 0 {
+				t.Error("an undeliverable address got no messages")
+			}
+			if verification.Suggestion != nil {
+				t.Errorf("got suggestion %+v, want none", verification.Suggestion)
+			}
+		})
+	}
+}